@@ -15,8 +15,9 @@ func main() {
 	// 命令行参数
 	var (
 		serverURL = flag.String("server", "", "服务器 URL (必需)")
-		username  = flag.String("username", "", "用户名 (必需)")
-		password  = flag.String("password", "", "密码 (必需)")
+		apiKey    = flag.String("api-key", "", "API 密钥（需要 fetch:read、fetch:write 权限），与用户名和密码二选一")
+		username  = flag.String("username", "", "用户名")
+		password  = flag.String("password", "", "密码")
 		debug     = flag.Bool("debug", false, "调试模式")
 		headless  = flag.Bool("headless", true, "无头模式")
 		timeout   = flag.Int("timeout", 120, "超时时间（秒）")
@@ -27,6 +28,9 @@ func main() {
 	if *serverURL == "" {
 		*serverURL = os.Getenv("SERVER_URL")
 	}
+	if *apiKey == "" {
+		*apiKey = os.Getenv("API_KEY")
+	}
 	if *username == "" {
 		*username = os.Getenv("USERNAME")
 	}
@@ -42,14 +46,13 @@ func main() {
 	}
 
 	// 检查必需参数
-	if *serverURL == "" || *username == "" || *password == "" {
-		log.Fatal("❌ 缺少必需参数: --server, --username, --password，或环境变量 SERVER_URL、USERNAME、PASSWORD")
+	if *serverURL == "" || (*apiKey == "" && (*username == "" || *password == "")) {
+		log.Fatal("❌ 缺少必需参数: --server 以及 --api-key 或 --username、--password，或环境变量 SERVER_URL 以及 API_KEY 或 USERNAME、PASSWORD")
 	}
 
 	log.Printf("🐛 mini-catch-crawler 版本: %s", Version)
 	log.Println("🚀 启动 mini4k 爬虫")
 	log.Printf("📡 服务器: %s", *serverURL)
-	log.Printf("👤 用户名: %s", *username)
 	log.Printf("🐛 调试模式: %v", *debug)
 	log.Printf("👻 无头模式: %v", *headless)
 	log.Printf("⏰ 超时时间: %d 秒", *timeout)
@@ -57,6 +60,7 @@ func main() {
 	// 创建配置
 	config := &crawler.Config{
 		ServerURL: *serverURL,
+		APIKey:    *apiKey,
 		Username:  *username,
 		Password:  *password,
 		Debug:     *debug,
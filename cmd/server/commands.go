@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"mini-catch/internal/database"
+	"mini-catch/internal/library"
+	"mini-catch/internal/password"
+)
+
+// runCommand 执行子命令，没有匹配的子命令时返回 false
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "import-history":
+		runImportHistory(args[1:])
+	case "dedupe-series":
+		runDedupeSeries(args[1:])
+	case "hash-password":
+		runHashPassword(args[1:])
+	default:
+		return false
+	}
+	return true
+}
+
+// runImportHistory 从 Trakt 或 TV Time 导出文件导入观看记录
+func runImportHistory(args []string) {
+	fs := flag.NewFlagSet("import-history", flag.ExitOnError)
+	source := fs.String("source", "", "来源：trakt 或 tvtime (必需)")
+	file := fs.String("file", "", "导出文件路径，支持 JSON、CSV 或 zip 压缩包 (必需)")
+	mappingFile := fs.String("mapping", "", "外部剧名到剧集 ID 的 JSON 映射文件，如 {\"Breaking Bad\": 12}")
+	dryRun := fs.Bool("dry-run", false, "只输出匹配结果，不写入数据库")
+	dbPath := fs.String("db", DATABASE_PATH, "数据库路径")
+	username := fs.String("user", "", "导入到该用户的观看记录，默认为管理员")
+	fs.Parse(args)
+
+	if *source == "" || *file == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		log.Fatalf("读取导出文件失败: %v", err)
+	}
+
+	mapping := map[string]int64{}
+	if *mappingFile != "" {
+		content, err := os.ReadFile(*mappingFile)
+		if err != nil {
+			log.Fatalf("读取映射文件失败: %v", err)
+		}
+		if err := json.Unmarshal(content, &mapping); err != nil {
+			log.Fatalf("解析映射文件失败: %v", err)
+		}
+	}
+
+	shows, err := library.ParseWatchHistory(*source, data)
+	if err != nil {
+		log.Fatalf("解析导出文件失败: %v", err)
+	}
+
+	db, err := database.NewDatabase(*dbPath)
+	if err != nil {
+		log.Fatalf("初始化数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	var store database.Store = db
+	if *username != "" {
+		user, err := db.GetUserByName(*username)
+		if err != nil {
+			log.Fatalf("获取用户 %s 失败: %v", *username, err)
+		}
+		store = db.ForUser(user.ID)
+	}
+
+	report, err := library.ImportWatchHistory(store, *source, shows, mapping, *dryRun)
+	if err != nil {
+		log.Fatalf("导入观看记录失败: %v", err)
+	}
+
+	for _, show := range report.Shows {
+		switch show.Action {
+		case library.MatchMatched:
+			fmt.Printf("✅ %s -> [%d] %s (%s)，新标记已看 %d 集", show.Title, show.Series.ID, show.Series.Name, show.MatchedBy, show.NewlyWatched)
+			if len(show.Missing) > 0 {
+				fmt.Printf("，%d 集尚未被爬虫发现", len(show.Missing))
+			}
+			fmt.Println()
+		case library.MatchAmbiguous:
+			var candidates []string
+			for _, c := range show.Candidates {
+				candidates = append(candidates, fmt.Sprintf("[%d] %s", c.ID, c.Name))
+			}
+			fmt.Printf("⚠️ %s: %s：%s\n", show.Title, show.Reason, strings.Join(candidates, ", "))
+		default:
+			fmt.Printf("❓ %s: %s\n", show.Title, show.Reason)
+		}
+	}
+
+	mode := "已导入"
+	if report.DryRun {
+		mode = "预览（未写入）"
+	}
+	fmt.Printf("%s：匹配 %d 部，未匹配 %d 部，多个候选 %d 部，新标记已看 %d 集\n",
+		mode, report.Matched, report.Unmatched, report.Ambiguous, report.NewlyWatched)
+}
+
+// runDedupeSeries 合并规范 URL 相同的重复剧集，每组保留一个剧集并将其 URL 改为规范形式
+func runDedupeSeries(args []string) {
+	fs := flag.NewFlagSet("dedupe-series", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "只输出重复的剧集，不写入数据库")
+	dbPath := fs.String("db", DATABASE_PATH, "数据库路径")
+	fs.Parse(args)
+
+	db, err := database.NewDatabase(*dbPath)
+	if err != nil {
+		log.Fatalf("初始化数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	groups, err := findDuplicateSeries(db)
+	if err != nil {
+		log.Fatalf("查找重复剧集失败: %v", err)
+	}
+
+	merged := 0
+	for _, group := range groups {
+		keep := group[0]
+		var names []string
+		for _, dup := range group[1:] {
+			names = append(names, fmt.Sprintf("[%d] %s <%s>", dup.ID, dup.Name, dup.URL))
+		}
+		fmt.Printf("🔗 %s -> 保留 [%d] %s <%s>，合并 %s\n",
+			database.NormalizeSeriesURL(keep.URL), keep.ID, keep.Name, keep.URL, strings.Join(names, ", "))
+		if *dryRun {
+			continue
+		}
+
+		err := db.Transaction(func(tx database.Store) error {
+			for _, dup := range group[1:] {
+				if err := tx.MergeSeries(keep.ID, dup.ID); err != nil {
+					return fmt.Errorf("合并剧集 %d 失败: %w", dup.ID, err)
+				}
+			}
+			// 重复的剧集已删除，保留的剧集可以使用规范 URL
+			return tx.UpdateSeries(keep.ID, keep.Name, keep.URL)
+		})
+		if err != nil {
+			log.Fatalf("合并 [%d] %s 失败: %v", keep.ID, keep.Name, err)
+		}
+		merged += len(group) - 1
+	}
+
+	if *dryRun {
+		fmt.Printf("预览（未写入）：%d 组重复剧集\n", len(groups))
+		return
+	}
+	fmt.Printf("已合并：%d 组重复剧集，删除 %d 个重复的剧集\n", len(groups), merged)
+}
+
+// findDuplicateSeries 按规范 URL 分组查找重复的剧集（含回收站），每组第一个为保留的剧集：
+// 优先保留不在回收站中的、集数较多的、较早创建的剧集
+func findDuplicateSeries(db database.Store) ([][]database.Series, error) {
+	active, err := db.GetAllSeries()
+	if err != nil {
+		return nil, err
+	}
+	trashed, err := db.GetTrashedSeries()
+	if err != nil {
+		return nil, err
+	}
+
+	byURL := make(map[string][]database.Series)
+	var order []string
+	for _, s := range append(active, trashed...) {
+		url := database.NormalizeSeriesURL(s.URL)
+		if _, ok := byURL[url]; !ok {
+			order = append(order, url)
+		}
+		byURL[url] = append(byURL[url], s)
+	}
+
+	var groups [][]database.Series
+	for _, url := range order {
+		group := byURL[url]
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			a, b := group[i], group[j]
+			if (a.DeletedAt == nil) != (b.DeletedAt == nil) {
+				return a.DeletedAt == nil
+			}
+			if len(a.History) != len(b.History) {
+				return len(a.History) > len(b.History)
+			}
+			return a.ID < b.ID
+		})
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0].ID < groups[j][0].ID })
+	return groups, nil
+}
+
+// runHashPassword 生成 config.json 中 auth.password 可使用的 bcrypt 哈希
+// 未指定 -password 时从标准输入读取一行，避免密码出现在 shell 历史中
+func runHashPassword(args []string) {
+	fs := flag.NewFlagSet("hash-password", flag.ExitOnError)
+	pass := fs.String("password", "", "要生成哈希的密码，为空时从标准输入读取")
+	fs.Parse(args)
+
+	if *pass == "" {
+		fmt.Fprint(os.Stderr, "密码: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			log.Fatalf("读取密码失败: %v", err)
+		}
+		*pass = strings.TrimRight(line, "\r\n")
+	}
+
+	hash, err := password.Hash(*pass)
+	if err != nil {
+		log.Fatalf("生成哈希失败: %v", err)
+	}
+	fmt.Println(hash)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
@@ -9,9 +10,11 @@ import (
 	"syscall"
 	"time"
 
+	"mini-catch/internal/backup"
 	"mini-catch/internal/config"
 	handlers "mini-catch/internal/controller"
 	"mini-catch/internal/database"
+	"mini-catch/internal/password"
 	"mini-catch/internal/slack"
 
 	"git.mazhangjing.com/corkine/cls-client/data"
@@ -33,11 +36,31 @@ const (
 var Version = "dev"
 
 func main() {
+	// 子命令，如 import-history
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
+	restore := flag.String("restore", "", "启动前从备份恢复数据库（备份目录中的文件名或备份文件路径）")
+	flag.Parse()
+
 	// 加载配置
 	config, err := config.LoadConfig("config.json")
 	if err != nil {
 		log.Fatalf("加载配置失败: %v", err)
 	}
+	if !password.IsHash(config.Auth.Password) {
+		log.Println("⚠️ config.json 中的管理员密码为明文，建议使用 hash-password 命令生成 bcrypt 哈希")
+	}
+
+	// 从本地备份恢复，需在下载和打开数据库之前完成
+	if *restore != "" {
+		snapshot := backup.ResolveSnapshot(config.Backup.Dir, *restore)
+		if err := backup.Restore(snapshot, DATABASE_PATH); err != nil {
+			log.Fatalf("恢复备份失败: %v", err)
+		}
+		log.Printf("✅ 已从备份 %s 恢复数据库", snapshot)
+	}
 
 	var svc *data.CLSDataService
 	if config.CLS.ProjectURL != "" && config.CLS.ProjectToken != "" {
@@ -66,11 +89,26 @@ func main() {
 		log.Fatalf("初始化数据库失败: %v", err)
 	}
 
+	// 管理员用户名与 config.json 保持一致
+	if err := syncAdminUser(db, config.Auth.Username); err != nil {
+		log.Fatalf("同步管理员用户失败: %v", err)
+	}
+
 	// 初始化 Slack 通知器
 	notifier := &slack.Notifier{Db: db}
 
+	// 初始化本地备份
+	var backups *backup.Manager
+	if !config.Backup.Disabled {
+		backups = backup.NewManager(db, config.Backup.Dir, backup.Retention{
+			Hourly: config.Backup.Hourly,
+			Daily:  config.Backup.Daily,
+			Weekly: config.Backup.Weekly,
+		})
+	}
+
 	// 初始化处理器
-	handler := handlers.NewHandler(db, *config, notifier)
+	handler := handlers.NewHandler(db, *config, notifier, backups)
 
 	router := handlers.SetupRoutes(config, handler)
 
@@ -88,6 +126,16 @@ func main() {
 		},
 	}
 
+	// 定期清理回收站
+	stopPurge := make(chan struct{})
+	go purgeTrashLoop(db, stopPurge)
+
+	// 定时本地备份
+	stopBackup := make(chan struct{})
+	if backups != nil {
+		go backups.Run(time.Hour, stopBackup)
+	}
+
 	log.Printf("🚀 启动 mini-catch 服务器，端口: %s", config.Port)
 	log.Printf("📦 版本: %s", Version)
 
@@ -113,6 +161,9 @@ func main() {
 		log.Printf("服务器关闭错误: %v", err)
 	}
 
+	close(stopPurge)
+	close(stopBackup)
+
 	// 关闭数据库连接
 	if err := app.db.Close(); err != nil {
 		log.Printf("关闭数据库连接错误: %v", err)
@@ -125,3 +176,69 @@ func main() {
 
 	log.Println("✅ 服务器已关闭")
 }
+
+// syncAdminUser 将管理员用户的用户名更新为 config.json 中的用户名
+func syncAdminUser(db database.Store, username string) error {
+	admin, err := db.GetUserByID(database.AdminUserID)
+	if err != nil {
+		return err
+	}
+	if admin.Username == username && admin.IsAdmin {
+		return nil
+	}
+	admin.Username = username
+	admin.IsAdmin = true
+	return db.UpdateUser(admin)
+}
+
+// purgeTrashLoop 每小时彻底删除超过保留天数的回收站剧集和已过期的会话
+func purgeTrashLoop(db *database.Database, stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		purgeTrash(db)
+		purgeSessions(db)
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeTrash 按配置的保留天数清理回收站
+func purgeTrash(db *database.Database) {
+	settings, err := db.GetSettings()
+	if err != nil {
+		log.Printf("获取配置失败，跳过回收站清理: %v", err)
+		return
+	}
+
+	days := settings.TrashRetention()
+	if days == 0 {
+		return
+	}
+
+	purged, err := db.PurgeTrash(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("清理回收站失败: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("🗑️ 已彻底删除 %d 个超过 %d 天的回收站剧集", purged, days)
+	}
+}
+
+// purgeSessions 删除已过期的登录会话
+func purgeSessions(db *database.Database) {
+	purged, err := db.PurgeSessions(time.Now())
+	if err != nil {
+		log.Printf("清理过期会话失败: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("🔑 已删除 %d 个过期的会话", purged)
+	}
+}
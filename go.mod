@@ -7,6 +7,7 @@ require (
 	github.com/chromedp/chromedp v0.13.7
 	github.com/go-chi/chi/v5 v5.2.2
 	github.com/mattn/go-sqlite3 v1.14.28
+	golang.org/x/crypto v0.40.0
 )
 
 require (
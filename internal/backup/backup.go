@@ -0,0 +1,299 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mini-catch/internal/database"
+)
+
+// 备份文件名格式 mini-catch-20060102-150405.db，时间为 UTC
+const (
+	filePrefix = "mini-catch-"
+	fileSuffix = ".db"
+	timeLayout = "20060102-150405"
+)
+
+// Retention 备份保留策略：最近 Hourly 个小时、Daily 天、Weekly 周各保留该时段内最新的一个备份
+type Retention struct {
+	Hourly int
+	Daily  int
+	Weekly int
+}
+
+// Snapshot 备份文件
+type Snapshot struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Manager 管理本地数据库备份
+type Manager struct {
+	db        *database.Database
+	dir       string
+	retention Retention
+	mu        sync.Mutex
+}
+
+// NewManager 创建备份管理器
+func NewManager(db *database.Database, dir string, retention Retention) *Manager {
+	return &Manager{db: db, dir: dir, retention: retention}
+}
+
+// ErrInvalidName 备份文件名不合法
+var ErrInvalidName = errors.New("无效的备份文件名")
+
+// parseName 解析备份文件名中的时间，同时防止路径穿越
+func parseName(name string) (time.Time, error) {
+	if filepath.Base(name) != name || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+		return time.Time{}, ErrInvalidName
+	}
+	t, err := time.ParseInLocation(timeLayout, strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix), time.UTC)
+	if err != nil {
+		return time.Time{}, ErrInvalidName
+	}
+	return t, nil
+}
+
+// Create 立即创建一个备份并按保留策略清理旧备份
+func (m *Manager) Create() (*Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	name := filePrefix + now.Format(timeLayout) + fileSuffix
+	path := filepath.Join(m.dir, name)
+	if err := m.db.Backup(path); err != nil {
+		return nil, fmt.Errorf("备份数据库失败: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.rotate(); err != nil {
+		log.Printf("清理旧备份失败: %v", err)
+	}
+
+	return &Snapshot{Name: name, Size: info.Size(), CreatedAt: now.Truncate(time.Second)}, nil
+}
+
+// List 列出所有备份，按时间倒序
+func (m *Manager) List() ([]Snapshot, error) {
+	entries, err := os.ReadDir(m.dir)
+	if os.IsNotExist(err) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []Snapshot{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		createdAt, err := parseName(entry.Name())
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{Name: entry.Name(), Size: info.Size(), CreatedAt: createdAt})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Path 返回备份文件的完整路径，文件名不合法时返回 ErrInvalidName，不存在时返回 os.ErrNotExist
+func (m *Manager) Path(name string) (string, error) {
+	if _, err := parseName(name); err != nil {
+		return "", err
+	}
+	path := filepath.Join(m.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// rotate 删除保留策略之外的备份，调用方需持有锁
+func (m *Manager) rotate() error {
+	snapshots, err := m.List()
+	if err != nil {
+		return err
+	}
+
+	kept := selectKept(snapshots, m.retention)
+	for _, s := range snapshots {
+		if kept[s.Name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(m.dir, s.Name)); err != nil {
+			return err
+		}
+		log.Printf("🗑️ 已删除过期备份 %s", s.Name)
+	}
+	return nil
+}
+
+// selectKept 按保留策略选出需要保留的备份，snapshots 需按时间倒序
+func selectKept(snapshots []Snapshot, r Retention) map[string]bool {
+	tiers := []struct {
+		count  int
+		period func(t time.Time) string
+	}{
+		{r.Hourly, func(t time.Time) string { return t.Format("2006010215") }},
+		{r.Daily, func(t time.Time) string { return t.Format("20060102") }},
+		{r.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-%02d", year, week)
+		}},
+	}
+
+	kept := make(map[string]bool)
+	// 最新的备份始终保留
+	if len(snapshots) > 0 {
+		kept[snapshots[0].Name] = true
+	}
+	for _, tier := range tiers {
+		periods := make(map[string]bool)
+		for _, s := range snapshots {
+			if len(periods) >= tier.count {
+				break
+			}
+			// 按本地时间划分小时、天和周
+			period := tier.period(s.CreatedAt.Local())
+			if periods[period] {
+				continue
+			}
+			periods[period] = true
+			kept[s.Name] = true
+		}
+	}
+	return kept
+}
+
+// Run 每隔 interval 创建一次备份，启动时距离上次备份已超过 interval 也会立即备份
+func (m *Manager) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	if m.due(interval) {
+		m.createLogged()
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.createLogged()
+		}
+	}
+}
+
+// due 判断距离最近一次备份是否已超过 interval
+func (m *Manager) due(interval time.Duration) bool {
+	snapshots, err := m.List()
+	if err != nil || len(snapshots) == 0 {
+		return true
+	}
+	return time.Since(snapshots[0].CreatedAt) >= interval
+}
+
+func (m *Manager) createLogged() {
+	snapshot, err := m.Create()
+	if err != nil {
+		log.Printf("定时备份失败: %v", err)
+		return
+	}
+	log.Printf("💾 已创建备份 %s", snapshot.Name)
+}
+
+// Restore 在打开数据库之前用备份文件替换 dbPath，原数据库会被重命名保留
+func Restore(snapshotPath, dbPath string) error {
+	if err := database.CheckDatabaseFile(snapshotPath); err != nil {
+		return fmt.Errorf("备份文件不可用: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return err
+	}
+
+	// 旧数据库的日志文件不能与恢复后的数据库混用；WAL 中可能有尚未写回数据库文件的已提交事务，
+	// 与数据库一起移走，打开保留的数据库时 SQLite 会读取同名的 -wal 文件
+	logSuffixes := []string{"-wal", "-shm", "-journal"}
+	if _, err := os.Stat(dbPath); err == nil {
+		previous := dbPath + ".before-restore-" + time.Now().UTC().Format(timeLayout)
+		for _, suffix := range logSuffixes {
+			if err := os.Rename(dbPath+suffix, previous+suffix); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(dbPath, previous); err != nil {
+			return err
+		}
+		log.Printf("原数据库已保留为 %s", previous)
+	}
+	for _, suffix := range logSuffixes {
+		os.Remove(dbPath + suffix)
+	}
+
+	tmpPath := dbPath + ".tmp"
+	if err := copyFile(snapshotPath, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, dbPath)
+}
+
+// ResolveSnapshot 将备份目录中的文件名或完整路径解析为备份文件路径
+func ResolveSnapshot(dir, nameOrPath string) string {
+	if _, err := parseName(nameOrPath); err == nil {
+		return filepath.Join(dir, nameOrPath)
+	}
+	return nameOrPath
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
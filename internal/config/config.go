@@ -20,6 +20,16 @@ type Config struct {
 		ProjectURL   string `json:"project_url"`
 		ProjectToken string `json:"project_token"`
 	} `json:"cls"`
+	Backup struct {
+		Disabled bool   `json:"disabled"` // 关闭本地定时备份
+		Dir      string `json:"dir"`      // 备份目录，默认 data/backups
+		Hourly   int    `json:"hourly"`   // 保留最近多少个小时的备份，默认 24
+		Daily    int    `json:"daily"`    // 保留最近多少天的备份，默认 7
+		Weekly   int    `json:"weekly"`   // 保留最近多少周的备份，默认 4
+	} `json:"backup"`
+	// RealIPHeader 反向代理写入客户端 IP 的请求头（如 X-Real-IP、X-Forwarded-For），为空时使用连接的来源地址
+	// 只应在服务只能经由可信代理访问时配置，否则客户端可以伪造该请求头
+	RealIPHeader string `json:"real_ip_header"`
 }
 
 // loadConfig 加载配置
@@ -54,6 +64,23 @@ func LoadConfig(configPath string) (*Config, error) {
 	if envPort := os.Getenv("MINI_CATCH_PORT"); envPort != "" {
 		config.Port = envPort
 	}
+	if envBackupDir := os.Getenv("MINI_CATCH_BACKUP_DIR"); envBackupDir != "" {
+		config.Backup.Dir = envBackupDir
+	}
+
+	// 备份默认值
+	if config.Backup.Dir == "" {
+		config.Backup.Dir = "data/backups"
+	}
+	if config.Backup.Hourly <= 0 {
+		config.Backup.Hourly = 24
+	}
+	if config.Backup.Daily <= 0 {
+		config.Backup.Daily = 7
+	}
+	if config.Backup.Weekly <= 0 {
+		config.Backup.Weekly = 4
+	}
 
 	return config, nil
 }
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"mini-catch/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// API 密钥的权限范围，登录会话不受范围限制
+const (
+	ScopeFetchRead   = "fetch:read"   // 获取爬虫任务
+	ScopeFetchWrite  = "fetch:write"  // 上报爬虫结果
+	ScopeSeriesRead  = "series:read"  // 读取剧集、标签、事件、统计等
+	ScopeSeriesWrite = "series:write" // 修改剧集、标签、回收站，导入
+	ScopeAdmin       = "admin"        // 包含以上所有范围，以及用户、备份、配置和密钥管理
+)
+
+// apiKeyScopes 所有可用的权限范围
+var apiKeyScopes = []string{ScopeFetchRead, ScopeFetchWrite, ScopeSeriesRead, ScopeSeriesWrite, ScopeAdmin}
+
+const (
+	// apiKeyPrefix API 密钥的前缀，用于与会话令牌区分
+	apiKeyPrefix = "mck_"
+	// apiKeyDisplayLength 保存用于辨认的密钥开头字符数
+	apiKeyDisplayLength = 12
+	// apiKeyTouchInterval 距上次记录超过该时间才更新最近使用时间
+	apiKeyTouchInterval = time.Minute
+)
+
+// apiKeyContextKey 请求上下文中当前 API 密钥的键
+type apiKeyContextKey struct{}
+
+// currentAPIKey 返回当前请求使用的 API 密钥，使用登录会话或未认证的请求返回 nil
+func currentAPIKey(r *http.Request) *database.APIKey {
+	key, _ := r.Context().Value(apiKeyContextKey{}).(*database.APIKey)
+	return key
+}
+
+// isAPIKey 令牌是否为 API 密钥
+func isAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// newAPIKey 生成带前缀的 256 位随机密钥
+func newAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hasScope 密钥是否具有 scope 权限，admin 包含所有范围
+func hasScope(key *database.APIKey, scope string) bool {
+	return slices.Contains(key.Scopes, scope) || slices.Contains(key.Scopes, ScopeAdmin)
+}
+
+// validateAPIKey 校验 API 密钥，返回未吊销的密钥及其所属用户，并记录最近使用时间
+func validateAPIKey(db database.Store, token string, now time.Time) (*database.APIKey, *database.User, bool) {
+	key, err := db.GetAPIKeyByHash(hashToken(token))
+	if err != nil || key.RevokedAt != nil {
+		return nil, nil, false
+	}
+	user, err := db.GetUserByID(key.UserID)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := db.TouchAPIKey(key.ID, now); err == nil {
+			usedAt := now.UTC().Truncate(time.Second)
+			key.LastUsedAt = &usedAt
+		}
+	}
+	return key, user, true
+}
+
+// RequireScope 使用 API 密钥访问时要求密钥具有 scope 权限，登录会话不做限制
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := currentAPIKey(r); key != nil && !hasScope(key, scope) {
+				http.Error(w, "API 密钥没有 "+scope+" 权限", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession 只允许使用登录会话访问，如注销和修改密码
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currentSession(r) == nil {
+			http.Error(w, "该接口需要登录会话，不能使用 API 密钥", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiKeyRequest 创建 API 密钥请求
+type apiKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreatedAPIKey 新建的 API 密钥，Key 只在创建时返回一次
+type CreatedAPIKey struct {
+	database.APIKey
+	Key string `json:"key"`
+}
+
+// GetAPIKeys 获取所有 API 密钥
+func (h *Handler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.db.GetAPIKeys()
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取 API 密钥失败: "+err.Error())
+		return
+	}
+	h.successResponse(w, keys)
+}
+
+// CreateAPIKey 创建以当前用户身份访问的 API 密钥，名称和权限范围必填
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req apiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		h.errorResponse(w, http.StatusBadRequest, "名称不能为空")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		h.errorResponse(w, http.StatusBadRequest, "权限范围不能为空")
+		return
+	}
+	var scopes []string
+	for _, scope := range req.Scopes {
+		if !slices.Contains(apiKeyScopes, scope) {
+			h.errorResponse(w, http.StatusBadRequest, "无效的权限范围: "+scope)
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	token, err := newAPIKey()
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "生成密钥失败: "+err.Error())
+		return
+	}
+	key, err := h.db.CreateAPIKey(&database.APIKey{
+		UserID:  currentUser(r).ID,
+		Name:    req.Name,
+		Prefix:  token[:apiKeyDisplayLength],
+		KeyHash: hashToken(token),
+		Scopes:  scopes,
+	})
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "创建 API 密钥失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, CreatedAPIKey{APIKey: *key, Key: token})
+}
+
+// RevokeAPIKey 吊销 API 密钥
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的ID")
+		return
+	}
+
+	err = h.db.RevokeAPIKey(id, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "API 密钥不存在或已吊销")
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "吊销 API 密钥失败: "+err.Error())
+		return
+	}
+	h.successResponse(w, nil)
+}
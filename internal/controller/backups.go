@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	"mini-catch/internal/backup"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetBackups 列出本地备份
+func (h *Handler) GetBackups(w http.ResponseWriter, r *http.Request) {
+	if h.backups == nil {
+		h.errorResponse(w, http.StatusServiceUnavailable, "本地备份未启用")
+		return
+	}
+
+	snapshots, err := h.backups.List()
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取备份列表失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, snapshots)
+}
+
+// CreateBackup 立即创建一个备份
+func (h *Handler) CreateBackup(w http.ResponseWriter, r *http.Request) {
+	if h.backups == nil {
+		h.errorResponse(w, http.StatusServiceUnavailable, "本地备份未启用")
+		return
+	}
+
+	snapshot, err := h.backups.Create()
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "创建备份失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, snapshot)
+}
+
+// DownloadBackup 下载备份文件
+func (h *Handler) DownloadBackup(w http.ResponseWriter, r *http.Request) {
+	if h.backups == nil {
+		h.errorResponse(w, http.StatusServiceUnavailable, "本地备份未启用")
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	path, err := h.backups.Path(name)
+	if errors.Is(err, backup.ErrInvalidName) {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		h.errorResponse(w, http.StatusNotFound, "备份不存在")
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "读取备份失败: "+err.Error())
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "读取备份失败: "+err.Error())
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "读取备份失败: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	http.ServeContent(w, r, name, info.ModTime(), file)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// 爬虫记录列表默认和最大返回条数
+const (
+	defaultCrawlRunLimit = 50
+	maxCrawlRunLimit     = 500
+)
+
+// GetCrawlRuns 获取最近的爬虫记录，支持 ?limit=N
+func (h *Handler) GetCrawlRuns(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r, defaultCrawlRunLimit, maxCrawlRunLimit)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	runs, err := h.db.GetCrawlRuns(limit)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取爬虫记录失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, runs)
+}
+
+// GetCrawlRun 获取单次爬虫记录及每个 URL 的处理结果
+func (h *Handler) GetCrawlRun(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的ID")
+		return
+	}
+
+	run, err := h.db.GetCrawlRun(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "爬虫记录不存在")
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取爬虫记录失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, run)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mini-catch/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// seriesETag 剧集的 ETag，由 ID 和行版本组成，格式为 "<id>-<version>"
+func seriesETag(series *database.Series) string {
+	return `"` + strconv.FormatInt(series.ID, 10) + "-" + strconv.FormatInt(series.Version, 10) + `"`
+}
+
+// etagMatches 判断 If-Match / If-None-Match 中是否包含 etag
+// If-None-Match 使用弱比较，忽略 W/ 前缀；If-Match 使用强比较
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified 请求的 If-None-Match 包含 etag 时返回 304
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// seriesResponse 返回单个剧集并附带 ETag
+func (h *Handler) seriesResponse(w http.ResponseWriter, series *database.Series) {
+	w.Header().Set("ETag", seriesETag(series))
+	h.successResponse(w, series)
+}
+
+// txContextKey 请求上下文中事务存储的键，见 Handler.store
+type txContextKey struct{}
+
+// errRequestFailed 处理函数已返回错误响应，事务需要回滚
+var errRequestFailed = errors.New("请求处理失败")
+
+// bufferedResponse 缓存事务中写出的响应，提交后才发送给客户端
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+// failed 是否为错误响应
+func (b *bufferedResponse) failed() bool {
+	return b.status >= http.StatusBadRequest
+}
+
+// flush 将缓存的响应发送给客户端
+func (b *bufferedResponse) flush(w http.ResponseWriter) {
+	for key, values := range b.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(max(b.status, http.StatusOK))
+	w.Write(b.body.Bytes())
+}
+
+// SeriesPrecondition 修改剧集的接口校验 If-Match，剧集版本不一致时返回 412
+// 校验和修改在同一事务中完成，期间其他请求无法修改剧集；处理失败时回滚。
+// 未提供 If-Match 时返回 428，避免客户端忘记携带版本而覆盖他人的修改；确实不需要校验时可使用 If-Match: *
+func (h *Handler) SeriesPrecondition(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifMatch := r.Header.Get("If-Match")
+		if ifMatch == "" {
+			h.errorResponse(w, http.StatusPreconditionRequired, "缺少 If-Match 请求头，不校验版本时可使用 If-Match: *")
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			h.errorResponse(w, http.StatusBadRequest, "无效的ID")
+			return
+		}
+
+		buf := &bufferedResponse{header: make(http.Header)}
+		err = h.db.Transaction(func(tx database.Store) error {
+			r := r.WithContext(context.WithValue(r.Context(), txContextKey{}, tx))
+			series, err := h.store(r).GetSeriesByID(id)
+			if errors.Is(err, sql.ErrNoRows) {
+				h.errorResponse(buf, http.StatusPreconditionFailed, "剧集不存在")
+				return errRequestFailed
+			}
+			if err != nil {
+				return err
+			}
+			if etag := seriesETag(series); !etagMatches(ifMatch, etag, false) {
+				buf.Header().Set("ETag", etag)
+				h.errorResponse(buf, http.StatusPreconditionFailed, "剧集已被修改，请刷新后重试")
+				return errRequestFailed
+			}
+
+			next.ServeHTTP(buf, r)
+			if buf.failed() {
+				return errRequestFailed
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errRequestFailed) {
+			h.errorResponse(w, http.StatusInternalServerError, "处理请求失败: "+err.Error())
+			return
+		}
+		buf.flush(w)
+	})
+}
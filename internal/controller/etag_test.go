@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"mini-catch/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestSeriesPrecondition(t *testing.T) {
+	db := database.NewMemoryStore()
+	series, err := db.CreateSeries("漫长的季节", "https://example.com/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{db: db}
+
+	called := false
+	router := chi.NewRouter()
+	router.With(h.SeriesPrecondition).Post("/series/{id}", func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		h.successResponse(w, nil)
+	})
+
+	path := "/series/" + strconv.FormatInt(series.ID, 10)
+	tests := []struct {
+		name    string
+		path    string
+		ifMatch string
+		status  int
+		called  bool
+	}{
+		{"missing If-Match", path, "", http.StatusPreconditionRequired, false},
+		{"stale version", path, `"1-0"`, http.StatusPreconditionFailed, false},
+		{"current version", path, seriesETag(series), http.StatusOK, true},
+		{"any version", path, "*", http.StatusOK, true},
+		{"unknown series", "/series/100", "*", http.StatusPreconditionFailed, false},
+	}
+	for _, tt := range tests {
+		called = false
+		req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+		if tt.ifMatch != "" {
+			req.Header.Set("If-Match", tt.ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: 状态码 %d，期望 %d", tt.name, rec.Code, tt.status)
+		}
+		if called != tt.called {
+			t.Errorf("%s: 是否调用处理函数 %v，期望 %v", tt.name, called, tt.called)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mini-catch/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// 事件列表默认和最大返回条数
+const (
+	defaultEventLimit = 100
+	maxEventLimit     = 1000
+)
+
+// recordEvent 记录剧集事件，失败只记录日志，不影响主流程
+func (h *Handler) recordEvent(r *http.Request, seriesID int64, eventType, source string, detail interface{}) {
+	recordEventTo(h.store(r), seriesID, eventType, source, detail)
+}
+
+// recordEventTo 在指定的存储（如事务）中记录剧集事件，失败只记录日志
+func recordEventTo(store database.Store, seriesID int64, eventType, source string, detail interface{}) {
+	if err := store.CreateSeriesEvent(seriesID, eventType, source, detail); err != nil {
+		log.Printf("记录剧集事件失败 [%d %s]: %v", seriesID, eventType, err)
+	}
+}
+
+// GetSeriesEvents 获取剧集的事件时间线
+func (h *Handler) GetSeriesEvents(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的ID")
+		return
+	}
+
+	limit, err := parseLimit(r, defaultEventLimit, maxEventLimit)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, err := h.store(r).GetSeriesEvents(id, limit)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取事件失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, events)
+}
+
+// GetEvents 获取所有剧集的事件，支持 ?since=2006-01-02 或 RFC3339 时间，默认最近 7 天
+func (h *Handler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	since := time.Now().AddDate(0, 0, -7)
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		t, err := parseSince(sinceStr)
+		if err != nil {
+			h.errorResponse(w, http.StatusBadRequest, "无效的 since，请使用 2006-01-02 或 RFC3339 格式")
+			return
+		}
+		since = t
+	}
+
+	limit, err := parseLimit(r, defaultEventLimit, maxEventLimit)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, err := h.store(r).GetEvents(since, limit)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取事件失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, events)
+}
+
+// parseSince 解析 RFC3339 时间或按 Asia/Shanghai 解析的日期
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		loc = time.Local
+	}
+	return time.ParseInLocation("2006-01-02", s, loc)
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"mini-catch/internal/database"
+	"mini-catch/internal/slack"
+)
+
+// newFetchTestHandler 使用内存存储创建处理器，Slack 通知发送到测试服务器，返回收到的消息标题
+func newFetchTestHandler(t *testing.T) (*Handler, *database.MemoryStore, <-chan string) {
+	t.Helper()
+	titles := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message slack.SlackMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil || len(message.Attachments) == 0 {
+			t.Errorf("无效的 Slack 消息: %v", err)
+			return
+		}
+		titles <- message.Attachments[0].Title
+	}))
+	t.Cleanup(server.Close)
+
+	db := database.NewMemoryStore()
+	if _, err := db.UpdateSettings(map[string]string{database.SettingSlackWebhookURL: server.URL}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	return &Handler{db: db, notifier: &slack.Notifier{Db: db}}, db, titles
+}
+
+// postCallback 发送爬虫回调，返回响应状态码
+func postCallback(t *testing.T, h *Handler, callback database.FetchCallback) int {
+	t.Helper()
+	body, err := json.Marshal(callback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	h.HandleFetchTaskCallback(rec, httptest.NewRequest(http.MethodPost, "/api/fetch", bytes.NewReader(body)))
+	return rec.Code
+}
+
+// receiveTitles 等待 n 条通知，通知异步发送，顺序不确定，按标题排序返回
+func receiveTitles(t *testing.T, titles <-chan string, n int) []string {
+	t.Helper()
+	var got []string
+	for len(got) < n {
+		select {
+		case title := <-titles:
+			got = append(got, title)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("只收到 %d 条通知，期望 %d 条: %v", len(got), n, got)
+		}
+	}
+	// 确认没有多余的通知
+	select {
+	case title := <-titles:
+		t.Fatalf("收到多余的通知: %s", title)
+	case <-time.After(100 * time.Millisecond):
+	}
+	sort.Strings(got)
+	return got
+}
+
+// lastCrawlOutcomes 最近一次爬虫记录中各 URL 的处理结果
+func lastCrawlOutcomes(t *testing.T, db database.Store) map[string]string {
+	t.Helper()
+	runs, err := db.GetCrawlRuns(1)
+	if err != nil || len(runs) == 0 {
+		t.Fatalf("获取爬虫记录失败: %v", err)
+	}
+	run, err := db.GetCrawlRun(runs[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcomes := make(map[string]string)
+	for _, item := range run.Items {
+		outcomes[item.URL] = item.Outcome
+	}
+	return outcomes
+}
+
+// eventTypes 剧集的事件类型，按时间倒序
+func eventTypes(t *testing.T, db database.Store, seriesID int64) []string {
+	t.Helper()
+	events, err := db.GetSeriesEvents(seriesID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestHandleFetchTaskCallback(t *testing.T) {
+	h, db, titles := newFetchTestHandler(t)
+	series, err := db.CreateSeries("漫长的季节", "https://example.com/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := "https://example.com/2"
+
+	// 新集数：更新集数并通知追踪的用户，任务中没有结果的 URL 记为爬取失败
+	status := postCallback(t, h, database.FetchCallback{
+		Tasks: []string{series.URL, missing},
+		Results: []database.FetchResult{
+			{Name: series.Name, Update: "更新至 2 集", URL: series.URL, Series: []string{"S01E01", "S01E02"}},
+			{Name: "不存在", Update: "更新至 1 集", URL: "https://example.com/3", Series: []string{"S01E01"}},
+		},
+	})
+	if status != http.StatusOK {
+		t.Fatalf("状态码 %d", status)
+	}
+	got, err := db.GetSeriesByID(series.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.History) != 2 || got.Current != "更新至 2 集" {
+		t.Errorf("剧集未更新: %v %q", got.History, got.Current)
+	}
+	if titles := receiveTitles(t, titles, 1); titles[0] != "🎬 漫长的季节 有新集数更新！" {
+		t.Errorf("通知标题 %q", titles[0])
+	}
+	outcomes := lastCrawlOutcomes(t, db)
+	for url, want := range map[string]string{
+		series.URL:              database.CrawlOutcomeNewEpisodes,
+		missing:                 database.CrawlOutcomeMissing,
+		"https://example.com/3": database.CrawlOutcomeUnknown,
+	} {
+		if outcomes[url] != want {
+			t.Errorf("%s 的处理结果 %q，期望 %q", url, outcomes[url], want)
+		}
+	}
+
+	// 没有变化时不通知
+	unchanged := database.FetchCallback{
+		Tasks:   []string{series.URL},
+		Results: []database.FetchResult{{Name: series.Name, Update: "更新至 2 集", URL: series.URL, Series: []string{"S01E01", "S01E02"}}},
+	}
+	if status := postCallback(t, h, unchanged); status != http.StatusOK {
+		t.Fatalf("状态码 %d", status)
+	}
+	receiveTitles(t, titles, 0)
+	if outcome := lastCrawlOutcomes(t, db)[series.URL]; outcome != database.CrawlOutcomeUnchanged {
+		t.Errorf("处理结果 %q", outcome)
+	}
+
+	// 只有摘要变化时发送状态通知
+	unchanged.Results[0].Update = "更新至 2 集（花絮）"
+	if status := postCallback(t, h, unchanged); status != http.StatusOK {
+		t.Fatalf("状态码 %d", status)
+	}
+	if titles := receiveTitles(t, titles, 1); titles[0] != "🎬 漫长的季节 更新至 2 集（花絮）" {
+		t.Errorf("通知标题 %q", titles[0])
+	}
+
+	// 没有用户追踪时不通知
+	if err := db.SetSeriesState(series.ID, database.StatePaused); err != nil {
+		t.Fatal(err)
+	}
+	unchanged.Results[0].Series = []string{"S01E01", "S01E02", "S01E03"}
+	if status := postCallback(t, h, unchanged); status != http.StatusOK {
+		t.Fatalf("状态码 %d", status)
+	}
+	receiveTitles(t, titles, 0)
+
+	want := []string{database.EventNewEpisodes, database.EventStatusChanged, database.EventNewEpisodes}
+	if types := eventTypes(t, db, series.ID); !equalStrings(types, want) {
+		t.Errorf("事件 %v，期望 %v", types, want)
+	}
+
+	// 爬虫上报失败时只保存记录
+	if status := postCallback(t, h, database.FetchCallback{Status: -1, Message: "timeout"}); status != http.StatusBadRequest {
+		t.Errorf("失败回调的状态码 %d", status)
+	}
+	runs, err := db.GetCrawlRuns(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 5 || runs[0].Status != -1 {
+		t.Errorf("爬虫记录 %d 条，最近一次状态 %d", len(runs), runs[0].Status)
+	}
+}
+
+func TestHandleFetchTaskCallbackFinished(t *testing.T) {
+	h, db, titles := newFetchTestHandler(t)
+	series, err := db.CreateSeries("漫长的季节", "https://example.com/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 完结时同时发送新集数和完结通知
+	finished := database.FetchCallback{
+		Tasks:   []string{series.URL},
+		Results: []database.FetchResult{{Name: series.Name, Update: "已完结", URL: series.URL, Series: []string{"S01E01", "S01E02"}}},
+	}
+	if status := postCallback(t, h, finished); status != http.StatusOK {
+		t.Fatalf("状态码 %d", status)
+	}
+	want := []string{"🎬 漫长的季节 有新集数更新！", "🏁 漫长的季节 已完结"}
+	if got := receiveTitles(t, titles, 2); !equalStrings(got, want) {
+		t.Errorf("通知 %v，期望 %v", got, want)
+	}
+	got, err := db.GetSeriesByID(series.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Finished || got.FinishedAt == nil {
+		t.Error("剧集应为已完结")
+	}
+
+	// 已完结的剧集不再重复通知
+	if status := postCallback(t, h, finished); status != http.StatusOK {
+		t.Fatalf("状态码 %d", status)
+	}
+	receiveTitles(t, titles, 0)
+	types := eventTypes(t, db, series.ID)
+	if !equalStrings(types, []string{database.EventFinished, database.EventNewEpisodes}) {
+		t.Errorf("事件 %v", types)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,13 +1,21 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"mini-catch/internal/backup"
 	"mini-catch/internal/config"
 	"mini-catch/internal/database"
 	"mini-catch/internal/slack"
@@ -19,14 +27,16 @@ import (
 
 // Handler HTTP处理器
 type Handler struct {
-	db       *database.Database
+	db       database.Store
 	config   config.Config
 	notifier *slack.Notifier
 	cls      *auth.CLSAuthService
+	backups  *backup.Manager // 为空时备份接口不可用
+	logins   *loginLimiter
 }
 
 // NewHandler 创建新的处理器
-func NewHandler(db *database.Database, config config.Config, notifier *slack.Notifier) *Handler {
+func NewHandler(db database.Store, config config.Config, notifier *slack.Notifier, backups *backup.Manager) *Handler {
 	var clsSvc *auth.CLSAuthService
 	if config.CLS.PublicKey != "" && config.CLS.MatchPurpose != "" && config.CLS.RemoteServer != "" {
 		clsSvc = auth.NewCLSAuthService(config.CLS.PublicKey, config.CLS.MatchPurpose, config.CLS.RemoteServer)
@@ -38,14 +48,31 @@ func NewHandler(db *database.Database, config config.Config, notifier *slack.Not
 		config:   config,
 		notifier: notifier,
 		cls:      clsSvc,
+		backups:  backups,
+		logins:   newLoginLimiter(),
 	}
 }
 
+// store 以当前用户身份操作的存储，追踪和观看状态按用户区分
+// 请求在 SeriesPrecondition 的事务中处理时返回事务中的存储
+func (h *Handler) store(r *http.Request) database.Store {
+	db := h.db
+	if tx, ok := r.Context().Value(txContextKey{}).(database.Store); ok {
+		db = tx
+	}
+	if user := currentUser(r); user != nil {
+		return db.ForUser(user.ID)
+	}
+	return db
+}
+
 // 响应结构
 type Response struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+	Total      *int        `json:"total,omitempty"`       // 分页列表的总数
+	NextCursor string      `json:"next_cursor,omitempty"` // 下一页游标，没有下一页时为空
 }
 
 // 登录请求结构
@@ -56,10 +83,12 @@ type LoginRequest struct {
 
 // 登录响应结构
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"` // 会话的过期时间，使用后顺延
 }
 
-// LoginHandler 登录处理器
+// LoginHandler 登录处理器，认证成功后创建会话
+// 同一 IP 或用户名失败次数过多时临时锁定，锁定期间返回 429
 func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -67,65 +96,162 @@ func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := h.clientIP(r)
+	if wait := h.logins.attempt(ip, req.Username); wait > 0 {
+		minutes := int(math.Ceil(wait.Minutes()))
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		h.errorResponse(w, http.StatusTooManyRequests, fmt.Sprintf("登录失败次数过多，请 %d 分钟后重试", minutes))
+		return
+	}
+
 	// 如果用户名是 CLS，则使用 CLS JWT 认证
 	// 如果用户名是 CLST，则使用 CLS Token 认证
 	switch req.Username {
 	case "CLS":
 		if h.cls == nil {
-			h.errorResponse(w, http.StatusUnauthorized, "CLS 认证未配置")
+			h.rejectLogin(w, ip, req.Username, "CLS 认证未配置")
 			return
 		}
 		claims, err := h.cls.JwtAuth(req.Password)
 		if err != nil {
-			h.errorResponse(w, http.StatusUnauthorized, "认证失败: "+err.Error())
+			h.rejectLogin(w, ip, req.Username, "认证失败: "+err.Error())
 			return
 		}
 		log.Printf("CLS JWT 认证成功: %+v", claims)
 	case "CLST":
 		if h.cls == nil {
-			h.errorResponse(w, http.StatusUnauthorized, "CLS 认证未配置")
+			h.rejectLogin(w, ip, req.Username, "CLS 认证未配置")
 			return
 		}
 		claims, err := h.cls.TokenAuth(req.Password)
 		if err != nil {
-			h.errorResponse(w, http.StatusUnauthorized, "认证失败: "+err.Error())
+			h.rejectLogin(w, ip, req.Username, "认证失败: "+err.Error())
 			return
 		}
 		log.Printf("CLS Token 认证成功: %+v", claims)
 	default:
-		// 验证用户名和密码
-		if req.Username != h.config.Auth.Username || req.Password != h.config.Auth.Password {
-			h.errorResponse(w, http.StatusUnauthorized, "用户名或密码错误")
+		// 验证用户名和密码，会话属于登录的用户
+		user, err := authenticate(&h.config, h.db, req.Username, req.Password)
+		if err != nil {
+			if errors.Is(err, errInvalidCredentials) {
+				h.rejectLogin(w, ip, req.Username, err.Error())
+			} else {
+				h.logins.cancel(ip, req.Username)
+				h.errorResponse(w, http.StatusInternalServerError, "登录失败: "+err.Error())
+			}
 			return
 		}
+		h.logins.succeed(ip, req.Username)
+		h.startSession(w, r, user.ID)
+		return
 	}
 
-	// 生成认证令牌
-	token := GenerateAuthToken(h.config.Auth.Username, h.config.Auth.Password)
-
-	// 设置 Cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   false, // 在生产环境中应该设置为 true
-		SameSite: http.SameSiteStrictMode,
-		Expires:  time.Now().Add(24 * time.Hour), // 24小时过期
-	})
+	// CLS 认证以管理员身份登录
+	h.logins.succeed(ip, req.Username)
+	h.startSession(w, r, database.AdminUserID)
+}
 
-	h.successResponse(w, LoginResponse{Token: token})
+// rejectLogin 记录登录失败并返回 401，达到失败次数锁定时记录日志并发送 Slack 通知
+func (h *Handler) rejectLogin(w http.ResponseWriter, ip, username, message string) {
+	log.Printf("⚠️ 登录失败: 用户名 %q，IP %s", username, ip)
+	for key, lockout := range h.logins.fail(ip, username) {
+		text := fmt.Sprintf("🔒 登录失败次数过多，已锁定 %s %s（最近一次尝试的用户名 %q，IP %s）", key, lockout, username, ip)
+		log.Print(text)
+		if h.notifier != nil {
+			go func() {
+				if err := h.notifier.SendMessage(text); err != nil {
+					log.Printf("发送登录告警失败: %v", err)
+				}
+			}()
+		}
+	}
+	h.errorResponse(w, http.StatusUnauthorized, message)
 }
 
+// 剧集列表每页最大数量
+const maxSeriesPageSize = 500
+
 // GetSeriesList 获取剧集列表
+// 支持 ?tag=anime&state=watching,waiting&watched=false&q=名称&sort=name&order=asc&limit=50&cursor=...
+// tracking=true|false 等同于 state 为 TrackingStates 或其余状态（含不在片单中）
+// 未指定 limit 时返回全部结果，响应中的 total 为符合条件的总数，next_cursor 用于获取下一页
 func (h *Handler) GetSeriesList(w http.ResponseWriter, r *http.Request) {
-	series, err := h.db.GetAllSeries()
+	query := r.URL.Query()
+	q := database.SeriesQuery{
+		Tag:  query.Get("tag"),
+		Q:    strings.TrimSpace(query.Get("q")),
+		Sort: query.Get("sort"),
+	}
+
+	var err error
+	if q.States, err = parseStatesParam(query.Get("state")); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	tracking, err := parseBoolParam(query.Get("tracking"))
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的 tracking 参数")
+		return
+	}
+	if tracking != nil {
+		if q.States != nil {
+			h.errorResponse(w, http.StatusBadRequest, "state 和 tracking 参数不能同时使用")
+			return
+		}
+		q.States = database.TrackingStates
+		if !*tracking {
+			q.States = database.NonTrackingStates()
+		}
+	}
+	if q.Watched, err = parseBoolParam(query.Get("watched")); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的 watched 参数")
+		return
+	}
+
+	if q.Sort != "" && !database.IsValidSeriesSort(q.Sort) {
+		h.errorResponse(w, http.StatusBadRequest, "不支持的排序字段: "+q.Sort)
+		return
+	}
+	switch query.Get("order") {
+	case "":
+		// 名称默认升序，时间默认倒序
+		q.Desc = q.Sort != "name"
+	case "asc":
+		q.Desc = false
+	case "desc":
+		q.Desc = true
+	default:
+		h.errorResponse(w, http.StatusBadRequest, "order 只能是 asc 或 desc")
+		return
+	}
+
+	if q.Limit, err = parseLimit(r, 0, maxSeriesPageSize); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if q.After, err = decodeCursor(query.Get("cursor"), q); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的 cursor")
+		return
+	}
+
+	// 多取一条判断是否还有下一页
+	limit := q.Limit
+	if limit > 0 {
+		q.Limit++
+	}
+	series, total, err := h.store(r).ListSeries(q)
 	if err != nil {
 		h.errorResponse(w, http.StatusInternalServerError, "获取剧集列表失败: "+err.Error())
 		return
 	}
 
-	h.successResponse(w, series)
+	nextCursor := ""
+	if limit > 0 && len(series) > limit {
+		series = series[:limit]
+		nextCursor = encodeCursor(q, series[limit-1])
+	}
+
+	h.pagedResponse(w, r, series, total, nextCursor)
 }
 
 // CreateSeries 创建剧集
@@ -140,22 +266,30 @@ func (h *Handler) CreateSeries(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.URL = database.NormalizeSeriesURL(req.URL)
 	if req.Name == "" || req.URL == "" {
 		h.errorResponse(w, http.StatusBadRequest, "名称和URL不能为空")
 		return
 	}
 
-	series, err := h.db.CreateSeries(req.Name, req.URL)
+	if h.rejectDuplicateURL(w, r, req.URL, 0) {
+		return
+	}
+
+	series, err := h.store(r).CreateSeries(req.Name, req.URL)
+	if database.IsUniqueViolation(err) && h.rejectDuplicateURL(w, r, req.URL, 0) {
+		return
+	}
 	if err != nil {
 		h.errorResponse(w, http.StatusInternalServerError, "创建剧集失败: "+err.Error())
 		return
 	}
 
-	h.successResponse(w, series)
+	h.seriesResponse(w, series)
 }
 
-// UpdateSeries 更新剧集
-func (h *Handler) UpdateSeries(w http.ResponseWriter, r *http.Request) {
+// GetSeries 获取单个剧集（含回收站），支持 If-None-Match
+func (h *Handler) GetSeries(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
@@ -163,33 +297,125 @@ func (h *Handler) UpdateSeries(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		Name string `json:"name"`
-		URL  string `json:"url"`
+	series, err := h.store(r).GetSeriesByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "剧集不存在")
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取剧集信息失败: "+err.Error())
+		return
+	}
+
+	if notModified(w, r, seriesETag(series)) {
+		return
+	}
+	h.successResponse(w, series)
+}
+
+// updateSeriesRequest 更新剧集请求，未提交的字段保持不变
+type updateSeriesRequest struct {
+	Name *string `json:"name"`
+	URL  *string `json:"url"`
+	database.SeriesMetadataPatch
+	Notes  *string `json:"notes"`
+	Rating *int    `json:"rating"`
+}
+
+// UpdateSeries 更新剧集名称、URL、元数据及当前用户的备注和评分
+func (h *Handler) UpdateSeries(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的ID")
+		return
 	}
 
+	var req updateSeriesRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.errorResponse(w, http.StatusBadRequest, "请求参数错误: "+err.Error())
 		return
 	}
 
-	if req.Name == "" || req.URL == "" {
+	if req.URL != nil {
+		*req.URL = database.NormalizeSeriesURL(*req.URL)
+	}
+	if (req.Name != nil && *req.Name == "") || (req.URL != nil && *req.URL == "") {
 		h.errorResponse(w, http.StatusBadRequest, "名称和URL不能为空")
 		return
 	}
+	if err := req.SeriesMetadataPatch.Normalize(); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Rating != nil {
+		if err := database.ValidateRating(*req.Rating); err != nil {
+			h.errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
 
-	if err := h.db.UpdateSeries(id, req.Name, req.URL); err != nil {
-		h.errorResponse(w, http.StatusInternalServerError, "更新剧集失败: "+err.Error())
+	old, err := h.store(r).GetSeriesByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "剧集不存在")
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取剧集失败: "+err.Error())
 		return
 	}
 
-	series, err := h.db.GetSeriesByID(id)
+	if req.Name != nil || req.URL != nil {
+		name, url := old.Name, old.URL
+		if req.Name != nil {
+			name = *req.Name
+		}
+		if req.URL != nil {
+			url = *req.URL
+		}
+		if url != old.URL && h.rejectDuplicateURL(w, r, url, id) {
+			return
+		}
+		if err := h.store(r).UpdateSeries(id, name, url); err != nil {
+			h.errorResponse(w, http.StatusInternalServerError, "更新剧集失败: "+err.Error())
+			return
+		}
+		if old.Name != name {
+			h.recordEvent(r, id, database.EventRenamed, database.EventSourceUser, map[string]string{
+				"old_name": old.Name,
+				"new_name": name,
+			})
+		}
+	}
+
+	if !req.SeriesMetadataPatch.IsEmpty() {
+		if err := h.store(r).UpdateSeriesMetadata(id, req.SeriesMetadataPatch); err != nil {
+			h.errorResponse(w, http.StatusInternalServerError, "更新剧集信息失败: "+err.Error())
+			return
+		}
+	}
+
+	if req.Notes != nil || req.Rating != nil {
+		notes, rating := old.Notes, old.Rating
+		if req.Notes != nil {
+			notes = strings.TrimSpace(*req.Notes)
+		}
+		if req.Rating != nil {
+			rating = *req.Rating
+		}
+		if err := h.store(r).UpdateSeriesNotes(id, notes, rating); err != nil {
+			h.errorResponse(w, http.StatusInternalServerError, "更新备注失败: "+err.Error())
+			return
+		}
+	}
+
+	series, err := h.store(r).GetSeriesByID(id)
 	if err != nil {
 		h.errorResponse(w, http.StatusInternalServerError, "获取更新后的剧集失败: "+err.Error())
 		return
 	}
 
-	h.successResponse(w, series)
+	h.seriesResponse(w, series)
 }
 
 // DeleteSeries 删除剧集
@@ -201,15 +427,18 @@ func (h *Handler) DeleteSeries(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.db.DeleteSeries(id); err != nil {
+	if err := h.store(r).DeleteSeries(id); err != nil {
 		h.errorResponse(w, http.StatusInternalServerError, "删除剧集失败: "+err.Error())
 		return
 	}
 
-	h.successResponse(w, map[string]string{"message": "删除成功"})
+	h.recordEvent(r, id, database.EventDeleted, database.EventSourceUser, nil)
+
+	h.successResponse(w, map[string]string{"message": "已移至回收站"})
 }
 
 // MarkAsWatched 标记为已观看
+// 支持 ?until=S02E05 将进度设置到指定集数，?episode=S02E05 只标记单集，不带参数时标记全部集数
 func (h *Handler) MarkAsWatched(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -218,15 +447,48 @@ func (h *Handler) MarkAsWatched(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.db.MarkAsWatched(id); err != nil {
+	until := r.URL.Query().Get("until")
+	episode := r.URL.Query().Get("episode")
+
+	// code 为请求中指定的集数，用于错误提示
+	code := episode
+	switch {
+	case until != "":
+		if _, _, ok := database.ParseEpisodeCode(until); !ok {
+			h.errorResponse(w, http.StatusBadRequest, "无效的集数编号，请使用 S01E01 格式")
+			return
+		}
+		code = until
+		err = h.store(r).MarkWatchedUntil(id, until)
+	case episode != "":
+		err = h.store(r).SetEpisodeWatched(id, episode, true)
+	default:
+		err = h.store(r).MarkAsWatched(id)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		if code == "" {
+			h.errorResponse(w, http.StatusNotFound, "剧集不存在")
+		} else {
+			h.errorResponse(w, http.StatusNotFound, "集数不存在: "+code)
+		}
+		return
+	}
+	if err != nil {
 		h.errorResponse(w, http.StatusInternalServerError, "标记失败: "+err.Error())
 		return
 	}
 
+	h.recordEvent(r, id, database.EventWatched, database.EventSourceUser, map[string]string{
+		"until":   until,
+		"episode": episode,
+	})
+
 	h.successResponse(w, map[string]string{"message": "标记为已观看"})
 }
 
 // MarkAsUnwatched 标记为未观看
+// 支持 ?episode=S02E05 只标记单集，不带参数时将最新一集标记为未看
 func (h *Handler) MarkAsUnwatched(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -235,16 +497,31 @@ func (h *Handler) MarkAsUnwatched(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.db.MarkAsUnwatched(id); err != nil {
+	episode := r.URL.Query().Get("episode")
+	if episode != "" {
+		err = h.store(r).SetEpisodeWatched(id, episode, false)
+	} else {
+		err = h.store(r).MarkAsUnwatched(id)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "集数不存在: "+episode)
+		return
+	}
+	if err != nil {
 		h.errorResponse(w, http.StatusInternalServerError, "标记失败: "+err.Error())
 		return
 	}
 
+	h.recordEvent(r, id, database.EventUnwatched, database.EventSourceUser, map[string]string{
+		"episode": episode,
+	})
+
 	h.successResponse(w, map[string]string{"message": "标记为未观看"})
 }
 
-// ToggleTracking 切换追踪状态
-func (h *Handler) ToggleTracking(w http.ResponseWriter, r *http.Request) {
+// SetSeriesState 设置当前用户的剧集状态，state 为空时移出片单
+func (h *Handler) SetSeriesState(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
@@ -252,26 +529,56 @@ func (h *Handler) ToggleTracking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.db.ToggleTracking(id); err != nil {
-		h.errorResponse(w, http.StatusInternalServerError, "切换追踪状态失败: "+err.Error())
+	var req struct {
+		State *string `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+	if req.State == nil {
+		h.errorResponse(w, http.StatusBadRequest, "state 不能为空")
+		return
+	}
+	if !database.IsValidSeriesState(*req.State) {
+		h.errorResponse(w, http.StatusBadRequest, "不支持的状态: "+*req.State)
 		return
 	}
 
-	series, err := h.db.GetSeriesByID(id)
+	store := h.store(r)
+	existing, err := store.GetSeriesByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "剧集不存在")
+		return
+	}
 	if err != nil {
 		h.errorResponse(w, http.StatusInternalServerError, "获取剧集信息失败: "+err.Error())
 		return
 	}
 
-	status := "启用"
-	if !series.IsTracking {
-		status = "禁用"
+	err = store.SetSeriesState(id, *req.State)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "剧集不存在")
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "设置剧集状态失败: "+err.Error())
+		return
+	}
+
+	if existing.State != *req.State {
+		h.recordEvent(r, id, database.EventStateChanged, database.EventSourceUser, map[string]string{
+			"old": existing.State,
+			"new": *req.State,
+		})
 	}
 
-	h.successResponse(w, map[string]interface{}{
-		"message":     fmt.Sprintf("追踪状态已%s", status),
-		"is_tracking": series.IsTracking,
-	})
+	series, err := store.GetSeriesByID(id)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取剧集信息失败: "+err.Error())
+		return
+	}
+	h.seriesResponse(w, series)
 }
 
 // ClearSeriesHistory 清空剧集历史和当前进度
@@ -283,50 +590,62 @@ func (h *Handler) ClearSeriesHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.db.ClearSeriesHistory(id); err != nil {
+	old, err := h.store(r).GetSeriesByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "剧集不存在")
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取剧集信息失败: "+err.Error())
+		return
+	}
+
+	if err := h.store(r).ClearSeriesHistory(id); err != nil {
 		h.errorResponse(w, http.StatusInternalServerError, "清空历史失败: "+err.Error())
 		return
 	}
 
-	series, err := h.db.GetSeriesByID(id)
+	h.recordEvent(r, id, database.EventHistoryCleared, database.EventSourceUser, map[string]interface{}{
+		"history": old.History,
+		"current": old.Current,
+	})
+
+	series, err := h.store(r).GetSeriesByID(id)
 	if err != nil {
 		h.errorResponse(w, http.StatusInternalServerError, "获取剧集信息失败: "+err.Error())
 		return
 	}
 
-	h.successResponse(w, series)
+	h.seriesResponse(w, series)
 }
 
-// GetSettings 获取全局配置
-func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
-	settings, err := h.db.GetSettings()
+// GetSeriesEpisodes 获取剧集的集数列表（含首次出现时间）
+func (h *Handler) GetSeriesEpisodes(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		h.errorResponse(w, http.StatusInternalServerError, "获取配置失败: "+err.Error())
+		h.errorResponse(w, http.StatusBadRequest, "无效的ID")
 		return
 	}
-	h.successResponse(w, settings)
-}
 
-// UpdateSettings 更新全局配置
-func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
-	var settings database.Settings
-	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
-		h.errorResponse(w, http.StatusBadRequest, "请求参数错误: "+err.Error())
+	// 剧集不存在时 GetEpisodes 返回空列表，需先确认剧集存在
+	_, err = h.store(r).GetSeriesByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "剧集不存在")
 		return
 	}
-
-	// 简单的验证
-	if (settings.CrawlerStartTime != "" && !isValidTimeFormat(settings.CrawlerStartTime)) ||
-		(settings.CrawlerEndTime != "" && !isValidTimeFormat(settings.CrawlerEndTime)) {
-		h.errorResponse(w, http.StatusBadRequest, "时间格式不正确，请使用 HH:mm 格式")
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取剧集信息失败: "+err.Error())
 		return
 	}
 
-	if err := h.db.UpdateSettings(&settings); err != nil {
-		h.errorResponse(w, http.StatusInternalServerError, "更新配置失败: "+err.Error())
+	episodes, err := h.store(r).GetEpisodes(id)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取集数列表失败: "+err.Error())
 		return
 	}
-	h.successResponse(w, settings)
+
+	h.successResponse(w, episodes)
 }
 
 // TestSlackWebhook 测试 Slack Webhook
@@ -346,10 +665,84 @@ func (h *Handler) TestSlackWebhook(w http.ResponseWriter, r *http.Request) {
 	h.successResponse(w, map[string]string{"message": "测试消息发送成功"})
 }
 
-// isValidTimeFormat 检查时间是否为 HH:mm 格式
-func isValidTimeFormat(timeStr string) bool {
-	_, err := time.Parse("15:04", timeStr)
-	return err == nil
+// parseBoolParam 解析可选的布尔查询参数，为空时返回 nil
+func parseBoolParam(value string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// parseStatesParam 解析逗号分隔的剧集状态，none 表示不在片单中，为空时返回 nil
+func parseStatesParam(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var states []string
+	for _, state := range strings.Split(value, ",") {
+		state = strings.TrimSpace(state)
+		if state == "none" {
+			state = ""
+		} else if state == "" || !database.IsValidSeriesState(state) {
+			return nil, errors.New("不支持的状态: " + state)
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// seriesListCursor 剧集列表的分页游标，包含排序方式，换用其他排序时游标无效
+type seriesListCursor struct {
+	Sort string `json:"sort,omitempty"`
+	Desc bool   `json:"desc,omitempty"`
+	database.SeriesCursor
+}
+
+// encodeCursor 将最后一行的排序字段编码为不透明的分页游标
+func encodeCursor(q database.SeriesQuery, last database.Series) string {
+	data, _ := json.Marshal(seriesListCursor{
+		Sort:         q.Sort,
+		Desc:         q.Desc,
+		SeriesCursor: *database.NewSeriesCursor(last, q.Sort),
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor 解析分页游标，为空时返回 nil
+func decodeCursor(cursor string, q database.SeriesQuery) (*database.SeriesCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	var c seriesListCursor
+	if err := json.Unmarshal(decoded, &c); err != nil || c.ID <= 0 {
+		return nil, fmt.Errorf("无效的游标: %s", cursor)
+	}
+	if c.Sort != q.Sort || c.Desc != q.Desc {
+		return nil, fmt.Errorf("游标与排序方式不一致: %s", cursor)
+	}
+	return &c.SeriesCursor, nil
+}
+
+// parseLimit 解析 ?limit=N，未提供时返回默认值，超过上限时截断
+func parseLimit(r *http.Request, defaultLimit, maxLimit int) (int, error) {
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return defaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("无效的 limit: %s", limitStr)
+	}
+	return min(limit, maxLimit), nil
 }
 
 // isCrawlerInWorkingHours 检查当前是否在爬虫工作时间段内
@@ -360,7 +753,8 @@ func (h *Handler) isCrawlerInWorkingHours() (bool, error) {
 		return true, fmt.Errorf("获取配置失败: %v", err)
 	}
 
-	if settings.CrawlerStartTime == "" || settings.CrawlerEndTime == "" {
+	start, end := settings.Get(database.SettingCrawlerStartTime), settings.Get(database.SettingCrawlerEndTime)
+	if start == "" || end == "" {
 		// 如果没有设置时间，默认一直为工作时间
 		return true, nil
 	}
@@ -371,12 +765,12 @@ func (h *Handler) isCrawlerInWorkingHours() (bool, error) {
 	}
 	nowInLoc := time.Now().In(loc)
 
-	startTime, errStart := time.ParseInLocation("15:04", settings.CrawlerStartTime, loc)
+	startTime, errStart := time.ParseInLocation("15:04", start, loc)
 	if errStart != nil {
 		return true, fmt.Errorf("解析开始时间失败: %v", errStart)
 	}
 
-	endTime, errEnd := time.ParseInLocation("15:04", settings.CrawlerEndTime, loc)
+	endTime, errEnd := time.ParseInLocation("15:04", end, loc)
 	if errEnd != nil {
 		return true, fmt.Errorf("解析结束时间失败: %v", errEnd)
 	}
@@ -416,8 +810,14 @@ func (h *Handler) HandleFetchTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取所有启用的剧集URL
-	urls, err := h.db.GetAllTrackingURLs()
+	// 获取所有启用的剧集URL，已完结的剧集按配置的间隔抓取
+	var recheckFinishedBefore time.Time
+	if settings, err := h.db.GetSettings(); err != nil {
+		log.Printf("获取配置失败: %v", err)
+	} else if days := settings.FinishedRecheckDays(); days > 0 {
+		recheckFinishedBefore = time.Now().AddDate(0, 0, -days)
+	}
+	urls, err := h.db.GetAllTrackingURLs(recheckFinishedBefore)
 	if err != nil {
 		h.errorResponse(w, http.StatusInternalServerError, "获取任务失败: "+err.Error())
 		return
@@ -431,6 +831,7 @@ func (h *Handler) HandleFetchTask(w http.ResponseWriter, r *http.Request) {
 }
 
 // HandleFetchTaskCallback 爬虫回调接口 - POST
+// 所有结果在同一个事务中写入，任一剧集处理失败时整体回滚，通知在提交后才发送
 func (h *Handler) HandleFetchTaskCallback(w http.ResponseWriter, r *http.Request) {
 	var callback database.FetchCallback
 	if err := json.NewDecoder(r.Body).Decode(&callback); err != nil {
@@ -441,66 +842,200 @@ func (h *Handler) HandleFetchTaskCallback(w http.ResponseWriter, r *http.Request
 	log.Printf("收到爬虫回调: status=%d, message=%s, results=%d",
 		callback.Status, callback.Message, len(callback.Results))
 
-	if callback.Status >= 0 {
+	run := &database.CrawlRun{
+		Tasks:       callback.Tasks,
+		ResultCount: len(callback.Results),
+		Status:      callback.Status,
+		Message:     callback.Message,
+		DurationMs:  callback.DurationMs,
+	}
+
+	if callback.Status < 0 {
+		// 处理失败
+		log.Printf("爬虫任务失败: %s", callback.Message)
+		h.saveCrawlRun(run)
+		h.errorResponse(w, http.StatusBadRequest, "FAILED: "+callback.Message)
+		return
+	}
+
+	var notifications []func()
+	err := h.db.Transaction(func(tx database.Store) error {
+		run.Items = nil
+		notifications = nil
+
 		// 处理成功的结果
+		// URL 按规范形式匹配，兼容爬虫上报的不同写法
+		reported := make(map[string]bool)
 		for _, result := range callback.Results {
-			// 获取现有剧集信息
-			series, err := h.db.GetSeriesByURL(result.URL)
+			result.URL = database.NormalizeSeriesURL(result.URL)
+			reported[result.URL] = true
+			item, notify, err := h.applyFetchResult(tx, result)
 			if err != nil {
-				log.Printf("获取剧集信息失败 [%s]: %v", result.Name, err)
-				continue
+				return fmt.Errorf("处理 %s 失败: %v", result.URL, err)
 			}
+			run.Items = append(run.Items, item)
+			notifications = append(notifications, notify...)
+		}
 
-			// 检查是否有新的集数
-			existingSeries := make(map[string]bool)
-			for _, ep := range series.History {
-				existingSeries[ep] = true
+		// 任务中没有返回结果的 URL 视为爬取失败
+		for _, url := range callback.Tasks {
+			if !reported[database.NormalizeSeriesURL(url)] {
+				run.Items = append(run.Items, database.CrawlRunItem{
+					URL:     url,
+					Outcome: database.CrawlOutcomeMissing,
+					Detail:  callback.Errors[url],
+				})
 			}
+		}
 
-			var newEpisodes []string
-			for _, ep := range result.Series {
-				if !existingSeries[ep] {
-					newEpisodes = append(newEpisodes, ep)
-				}
-			}
+		if _, err := tx.CreateCrawlRun(run); err != nil {
+			return fmt.Errorf("保存爬虫记录失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("爬虫回调处理失败，已回滚: %v", err)
+		// 记录本次回调，所有 URL 均标记为处理失败
+		run.Items = nil
+		for _, result := range callback.Results {
+			run.Items = append(run.Items, database.CrawlRunItem{
+				URL:     result.URL,
+				Outcome: database.CrawlOutcomeError,
+				Detail:  "回调处理失败，已回滚: " + err.Error(),
+			})
+		}
+		h.saveCrawlRun(run)
+		h.errorResponse(w, http.StatusInternalServerError, "处理爬虫结果失败: "+err.Error())
+		return
+	}
 
-			if len(newEpisodes) > 0 { // 发现新集数
-				log.Printf("📤 发现新集数: %s, %v", result.Name, newEpisodes)
+	for _, notify := range notifications {
+		go notify()
+	}
+	h.successResponse(w, map[string]string{"message": "OK"})
+}
 
-				// 如果集数更新但是摘要没更新，那么不发送通知
-				if result.Update != "" && result.Update == series.Current {
-					log.Printf("摘要存在且没有更新，不发送通知")
-				} else {
-					go h.notifier.SendNotification(result.Name, newEpisodes, result.URL)
-				}
+// applyFetchResult 将单个爬取结果写入事务，返回处理结果和提交后需要发送的通知
+// 剧集不存在时只记录结果，写入失败时返回错误以回滚整个回调
+func (h *Handler) applyFetchResult(tx database.Store, result database.FetchResult) (database.CrawlRunItem, []func(), error) {
+	item := database.CrawlRunItem{URL: result.URL}
+	var notifications []func()
+
+	// 获取现有剧集信息
+	series, err := tx.GetSeriesByURL(result.URL)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("获取剧集信息失败 [%s]: %v", result.Name, err)
+		item.Outcome = database.CrawlOutcomeUnknown
+		item.Detail = err.Error()
+		return item, nil, nil
+	}
+	if err != nil {
+		return item, nil, err
+	}
+	item.SeriesID = &series.ID
 
-				// 更新数据库
-				if err := h.db.UpdateSeriesInfo(result.URL, result.Update, result.Series); err != nil {
-					log.Printf("更新剧集信息失败 [%s]: %v", result.Name, err)
-				}
-			} else if result.Update != series.Current { // 发现新摘要
-				log.Printf("📤 发现更新状态变更: %s, %s -> %s", result.Name, series.Current, result.Update)
+	if result.PosterURL != "" || result.OriginalTitle != "" {
+		if err := tx.UpdateSeriesCrawlerMetadata(result.URL, result.PosterURL, result.OriginalTitle); err != nil {
+			return item, nil, fmt.Errorf("更新剧集海报和原名失败: %v", err)
+		}
+	}
 
-				// 发送通知
-				go h.notifier.SendStatusUpdateNotification(result.Name, series.Current, result.Update, result.URL)
+	// 检查是否有新的集数
+	existingSeries := make(map[string]bool)
+	for _, ep := range series.History {
+		existingSeries[ep] = true
+	}
 
-				// 更新数据库
-				if err := h.db.UpdateSeriesInfo(result.URL, result.Update, series.History); err != nil {
-					log.Printf("更新剧集信息失败 [%s]: %v", result.Name, err)
-				}
-			} else { // 没有更新
-				// 更新爬虫最后更新时间
-				if err := h.db.UpdateSeriesCrawlerLastSeen(result.URL, time.Now()); err != nil {
-					log.Printf("更新剧集爬虫最后更新时间失败 [%s]: %v", result.Name, err)
-				}
-			}
+	episodes := result.EpisodeList()
+	var newEpisodes []string
+	for _, ep := range episodes {
+		if !existingSeries[ep.Code] {
+			newEpisodes = append(newEpisodes, ep.Code)
 		}
+	}
 
-		h.successResponse(w, map[string]string{"message": "OK"})
-	} else {
-		// 处理失败
-		log.Printf("爬虫任务失败: %s", callback.Message)
-		h.errorResponse(w, http.StatusBadRequest, "FAILED: "+callback.Message)
+	if len(newEpisodes) > 0 { // 发现新集数
+		log.Printf("📤 发现新集数: %s, %v", result.Name, newEpisodes)
+		item.Outcome = database.CrawlOutcomeNewEpisodes
+		item.Detail = strings.Join(newEpisodes, ", ")
+
+		// 更新数据库
+		if err := tx.UpdateSeriesInfo(result.URL, result.Update, episodes); err != nil {
+			return item, nil, fmt.Errorf("更新剧集信息失败: %v", err)
+		}
+		recordEventTo(tx, series.ID, database.EventNewEpisodes, database.EventSourceCrawler, map[string]interface{}{
+			"episodes":   newEpisodes,
+			"old_status": series.Current,
+			"new_status": result.Update,
+		})
+
+		// 如果集数更新但是摘要没更新，那么不发送通知
+		if result.Update != "" && result.Update == series.Current {
+			log.Printf("摘要存在且没有更新，不发送通知")
+		} else {
+			notifications = append(notifications, func() {
+				h.notifier.SendNotification(series.ID, result.Name, newEpisodes, result.URL)
+			})
+		}
+	} else if result.Update != series.Current { // 发现新摘要
+		log.Printf("📤 发现更新状态变更: %s, %s -> %s", result.Name, series.Current, result.Update)
+		item.Outcome = database.CrawlOutcomeStatusChanged
+		item.Detail = series.Current + " -> " + result.Update
+
+		// 更新数据库
+		if err := tx.UpdateSeriesInfo(result.URL, result.Update, episodes); err != nil {
+			return item, nil, fmt.Errorf("更新剧集信息失败: %v", err)
+		}
+		recordEventTo(tx, series.ID, database.EventStatusChanged, database.EventSourceCrawler, map[string]string{
+			"old_status": series.Current,
+			"new_status": result.Update,
+		})
+
+		// 发送通知
+		notifications = append(notifications, func() {
+			h.notifier.SendStatusUpdateNotification(series.ID, result.Name, series.Current, result.Update, result.URL)
+		})
+	} else { // 没有更新
+		item.Outcome = database.CrawlOutcomeUnchanged
+
+		// 更新爬虫最后更新时间
+		if err := tx.UpdateSeriesCrawlerLastSeen(result.URL, time.Now()); err != nil {
+			return item, nil, fmt.Errorf("更新剧集爬虫最后更新时间失败: %v", err)
+		}
+		if err := tx.TouchEpisodes(series.ID, episodes, time.Now()); err != nil {
+			return item, nil, fmt.Errorf("更新集数最后出现时间失败: %v", err)
+		}
+	}
+
+	if notify := h.checkFinished(tx, series, result); notify != nil {
+		notifications = append(notifications, notify)
+	}
+
+	return item, notifications, nil
+}
+
+// checkFinished 剧集由未完结变为已完结时记录事件并返回完结通知，之后按配置降低抓取频率
+func (h *Handler) checkFinished(tx database.Store, series *database.Series, result database.FetchResult) func() {
+	status := database.ParseStatus(result.Update)
+	if series.Finished || !status.Finished {
+		return nil
+	}
+
+	log.Printf("🏁 剧集已完结: %s, %s", result.Name, result.Update)
+	recordEventTo(tx, series.ID, database.EventFinished, database.EventSourceCrawler, map[string]interface{}{
+		"status":         result.Update,
+		"latest_episode": status.LatestEpisode,
+		"total_episodes": status.TotalEpisodes,
+	})
+	return func() {
+		h.notifier.SendFinishedNotification(series.ID, result.Name, result.Update, result.URL)
+	}
+}
+
+// saveCrawlRun 保存爬虫回调记录，失败只记录日志
+func (h *Handler) saveCrawlRun(run *database.CrawlRun) {
+	if _, err := h.db.CreateCrawlRun(run); err != nil {
+		log.Printf("保存爬虫记录失败: %v", err)
 	}
 }
 
@@ -514,6 +1049,46 @@ func (h *Handler) errorResponse(w http.ResponseWriter, status int, message strin
 	})
 }
 
+// rejectDuplicateURL URL 已被其他剧集（含回收站）占用时返回 409，响应数据为已有的剧集
+// excludeID 为正在修改的剧集，返回 true 表示已写入响应
+func (h *Handler) rejectDuplicateURL(w http.ResponseWriter, r *http.Request, url string, excludeID int64) bool {
+	existing, err := h.store(r).GetSeriesByURL(url)
+	if errors.Is(err, sql.ErrNoRows) {
+		existing = nil
+		trashed, err := h.store(r).GetTrashedSeries()
+		if err != nil {
+			h.errorResponse(w, http.StatusInternalServerError, "检查重复剧集失败: "+err.Error())
+			return true
+		}
+		for i := range trashed {
+			if trashed[i].URL == url {
+				existing = &trashed[i]
+				break
+			}
+		}
+	} else if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "检查重复剧集失败: "+err.Error())
+		return true
+	}
+	if existing == nil || existing.ID == excludeID {
+		return false
+	}
+
+	message := fmt.Sprintf("剧集已存在: [%d] %s", existing.ID, existing.Name)
+	if existing.DeletedAt != nil {
+		message += "（在回收站中，可恢复后使用）"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/series/%d", existing.ID))
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(Response{
+		Success: false,
+		Message: message,
+		Data:    existing,
+	})
+	return true
+}
+
 // 成功响应
 func (h *Handler) successResponse(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -522,3 +1097,24 @@ func (h *Handler) successResponse(w http.ResponseWriter, data interface{}) {
 		Data:    data,
 	})
 }
+
+// 分页成功响应，ETag 为响应内容的摘要，支持 If-None-Match
+func (h *Handler) pagedResponse(w http.ResponseWriter, r *http.Request, data interface{}, total int, nextCursor string) {
+	body, err := json.Marshal(Response{
+		Success:    true,
+		Data:       data,
+		Total:      &total,
+		NextCursor: nextCursor,
+	})
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "生成响应失败: "+err.Error())
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	if notModified(w, r, `"`+hex.EncodeToString(sum[:16])+`"`) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"mini-catch/internal/database"
+	"mini-catch/internal/library"
+)
+
+// maxImportSize 导入文件大小上限
+const maxImportSize = 32 << 20
+
+// Export 导出所有剧集，format 为 json（默认）或 csv
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		h.errorResponse(w, http.StatusBadRequest, "format 只能是 json 或 csv")
+		return
+	}
+
+	records, err := library.Export(h.store(r))
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "导出失败: "+err.Error())
+		return
+	}
+
+	filename := "mini-catch-" + time.Now().Format("20060102") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		err = library.WriteCSV(w, records)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = library.WriteJSON(w, records)
+	}
+	if err != nil {
+		log.Printf("写出导出文件失败: %v", err)
+	}
+}
+
+// Import 按 URL 合并导入剧集，dry_run=true 时只返回报告
+//
+// 格式由 format 参数指定，未指定时 Content-Type 为 text/csv 的请求按 CSV 解析，其余按 JSON 解析。
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	dryRun, err := parseBoolParam(query.Get("dry_run"))
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的 dry_run 参数")
+		return
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+			format = "csv"
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportSize)
+	var records []database.SeriesRecord
+	switch format {
+	case "json":
+		records, err = library.ReadJSON(body)
+	case "csv":
+		records, err = library.ReadCSV(body)
+	default:
+		h.errorResponse(w, http.StatusBadRequest, "format 只能是 json 或 csv")
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := library.Import(h.store(r), records, dryRun != nil && *dryRun)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "导入失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, report)
+}
+
+// ImportWatchHistory 导入 Trakt 或 TV Time 导出的观看记录，source 为 trakt 或 tvtime
+//
+// 请求体可以直接是导出文件（JSON、CSV 或 zip），也可以是 multipart 表单：file 为导出文件，
+// mapping 为外部剧名到剧集 ID 的 JSON 映射。dry_run=true 时只返回匹配报告。
+func (h *Handler) ImportWatchHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	source := query.Get("source")
+	if source != library.SourceTrakt && source != library.SourceTVTime {
+		h.errorResponse(w, http.StatusBadRequest, "source 只能是 trakt 或 tvtime")
+		return
+	}
+	dryRun, err := parseBoolParam(query.Get("dry_run"))
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的 dry_run 参数")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	var data []byte
+	mapping := map[string]int64{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			h.errorResponse(w, http.StatusBadRequest, "缺少导出文件: "+err.Error())
+			return
+		}
+		defer file.Close()
+		if data, err = io.ReadAll(file); err != nil {
+			h.errorResponse(w, http.StatusBadRequest, "读取导出文件失败: "+err.Error())
+			return
+		}
+		if raw := r.FormValue("mapping"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+				h.errorResponse(w, http.StatusBadRequest, "无效的 mapping: "+err.Error())
+				return
+			}
+		}
+	} else if data, err = io.ReadAll(r.Body); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "读取导出文件失败: "+err.Error())
+		return
+	}
+
+	shows, err := library.ParseWatchHistory(source, data)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := library.ImportWatchHistory(h.store(r), source, shows, mapping, dryRun != nil && *dryRun)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "导入观看记录失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, report)
+}
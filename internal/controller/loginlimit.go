@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// loginFailureWindow 统计登录失败次数的时间窗口
+	loginFailureWindow = 15 * time.Minute
+	// loginMaxFailuresPerUser 同一用户名在时间窗口内允许的失败次数
+	loginMaxFailuresPerUser = 5
+	// loginMaxFailuresPerIP 同一 IP 在时间窗口内允许的失败次数，多个用户可能共用同一出口 IP，限制较宽
+	loginMaxFailuresPerIP = 20
+	// loginLockout 首次锁定的时间，之后每次锁定加倍，最长 loginMaxLockout
+	loginLockout    = 15 * time.Minute
+	loginMaxLockout = 24 * time.Hour
+	// loginPruneThreshold 记录数超过该值时清理已过期的记录
+	loginPruneThreshold = 1024
+	// loginPendingRetry 进行中的尝试已占满失败次数时的重试等待时间
+	loginPendingRetry = time.Second
+)
+
+// loginAttempts 某个 IP 或用户名的登录失败记录
+type loginAttempts struct {
+	failures    int       // 时间窗口内的失败次数，含尚未得出结果的尝试
+	windowStart time.Time // 时间窗口的开始时间
+	lockedUntil time.Time
+	lockouts    int // 已锁定的次数，记录过期前持续累加
+}
+
+// loginLimiter 按 IP 和用户名限制登录失败次数，超过次数后临时锁定，只保存在内存中
+//
+// 每次尝试在校验密码前先计为失败（attempt），成功后再撤销（succeed），
+// 避免并发请求在任何失败被记录前都通过检查。
+type loginLimiter struct {
+	mu       sync.Mutex
+	attempts map[string]*loginAttempts // "ip:<ip>" 或 "user:<小写用户名>"
+	now      func() time.Time
+}
+
+// newLoginLimiter 创建登录限制器
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{attempts: make(map[string]*loginAttempts), now: time.Now}
+}
+
+// loginLimitKeys 返回 IP 和用户名的记录键及各自允许的失败次数
+func loginLimitKeys(ip, username string) map[string]int {
+	return map[string]int{
+		"ip:" + ip:                          loginMaxFailuresPerIP,
+		"user:" + strings.ToLower(username): loginMaxFailuresPerUser,
+	}
+}
+
+// attempt 检查 IP 和用户名是否被锁定，未锁定时将本次尝试计为失败并返回 0，锁定时返回剩余的锁定时间
+func (l *loginLimiter) attempt(ip, username string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if len(l.attempts) >= loginPruneThreshold {
+		l.prune(now)
+	}
+
+	var wait time.Duration
+	keys := loginLimitKeys(ip, username)
+	for key, limit := range keys {
+		a := l.attempts[key]
+		if a == nil {
+			continue
+		}
+		if a.lockedUntil.After(now) {
+			wait = max(wait, a.lockedUntil.Sub(now))
+		} else if now.Sub(a.windowStart) <= loginFailureWindow && a.failures >= limit {
+			wait = max(wait, loginPendingRetry)
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+
+	for key := range keys {
+		a := l.attempts[key]
+		if a == nil {
+			a = &loginAttempts{windowStart: now}
+			l.attempts[key] = a
+		}
+		if now.Sub(a.windowStart) > loginFailureWindow {
+			a.failures = 0
+			a.windowStart = now
+		}
+		a.failures++
+	}
+	return 0
+}
+
+// fail 确认 attempt 计入的失败，返回因此次失败被锁定的记录键及锁定时间
+func (l *loginLimiter) fail(ip, username string) map[string]time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	locked := make(map[string]time.Duration)
+	for key, limit := range loginLimitKeys(ip, username) {
+		a := l.attempts[key]
+		if a == nil || a.failures < limit {
+			continue
+		}
+		lockout := min(loginLockout<<a.lockouts, loginMaxLockout)
+		a.lockedUntil = now.Add(lockout)
+		a.lockouts++
+		a.failures = 0
+		a.windowStart = now
+		locked[key] = lockout
+	}
+	return locked
+}
+
+// succeed 登录成功后清除该用户名的失败记录，并撤销 attempt 计入 IP 的失败
+func (l *loginLimiter) succeed(ip, username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, "user:"+strings.ToLower(username))
+	l.release("ip:" + ip)
+}
+
+// cancel 撤销 attempt 计入的失败，用于服务端出错等未能校验密码的尝试
+func (l *loginLimiter) cancel(ip, username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key := range loginLimitKeys(ip, username) {
+		l.release(key)
+	}
+}
+
+// release 撤销一次计入的失败，调用方需持有锁
+func (l *loginLimiter) release(key string) {
+	if a := l.attempts[key]; a != nil && a.failures > 0 {
+		a.failures--
+	}
+}
+
+// prune 删除已解除锁定且时间窗口已过的记录，调用方需持有锁
+func (l *loginLimiter) prune(now time.Time) {
+	for key, a := range l.attempts {
+		if !a.lockedUntil.After(now) && now.Sub(a.windowStart) > loginFailureWindow {
+			delete(l.attempts, key)
+		}
+	}
+}
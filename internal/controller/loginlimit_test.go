@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"mini-catch/internal/config"
+)
+
+// fakeClock 测试用的可调时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestLimiter() (*loginLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := newLoginLimiter()
+	l.now = clock.Now
+	return l, clock
+}
+
+// failN 连续登录失败 n 次，返回最后一次失败导致的锁定
+func failN(t *testing.T, l *loginLimiter, ip, username string, n int) map[string]time.Duration {
+	t.Helper()
+	var locked map[string]time.Duration
+	for i := 0; i < n; i++ {
+		if wait := l.attempt(ip, username); wait > 0 {
+			t.Fatalf("第 %d 次尝试被拒绝，剩余锁定时间 %s", i+1, wait)
+		}
+		locked = l.fail(ip, username)
+	}
+	return locked
+}
+
+func TestLoginLimiterLockoutExpiry(t *testing.T) {
+	l, clock := newTestLimiter()
+
+	if locked := failN(t, l, "10.0.0.1", "alice", loginMaxFailuresPerUser-1); len(locked) != 0 {
+		t.Fatalf("未达到次数不应锁定: %v", locked)
+	}
+	locked := failN(t, l, "10.0.0.1", "Alice", 1)
+	if locked["user:alice"] != loginLockout {
+		t.Fatalf("用户名应锁定 %s，实际为 %v", loginLockout, locked)
+	}
+	if wait := l.attempt("10.0.0.2", "alice"); wait != loginLockout {
+		t.Fatalf("锁定期间换 IP 仍应被拒绝，剩余 %s", wait)
+	}
+
+	clock.now = clock.now.Add(loginLockout - time.Minute)
+	if wait := l.attempt("10.0.0.1", "alice"); wait != time.Minute {
+		t.Fatalf("剩余锁定时间应为 1 分钟，实际为 %s", wait)
+	}
+
+	// 锁定到期后可以重试，再次锁定时加倍
+	clock.now = clock.now.Add(time.Minute)
+	locked = failN(t, l, "10.0.0.1", "alice", loginMaxFailuresPerUser)
+	if locked["user:alice"] != 2*loginLockout {
+		t.Fatalf("再次锁定应加倍为 %s，实际为 %v", 2*loginLockout, locked)
+	}
+
+	// 登录成功后清除用户名的记录
+	clock.now = clock.now.Add(2 * loginLockout)
+	if wait := l.attempt("10.0.0.1", "alice"); wait != 0 {
+		t.Fatalf("锁定到期后应允许登录，剩余 %s", wait)
+	}
+	l.succeed("10.0.0.1", "alice")
+	if locked := failN(t, l, "10.0.0.1", "alice", loginMaxFailuresPerUser); locked["user:alice"] != loginLockout {
+		t.Fatalf("成功登录后锁定时间应重新计算，实际为 %v", locked)
+	}
+}
+
+func TestLoginLimiterWindowReset(t *testing.T) {
+	l, clock := newTestLimiter()
+
+	failN(t, l, "10.0.0.1", "bob", loginMaxFailuresPerUser-1)
+	clock.now = clock.now.Add(loginFailureWindow + time.Second)
+	if locked := failN(t, l, "10.0.0.1", "bob", loginMaxFailuresPerUser-1); len(locked) != 0 {
+		t.Fatalf("时间窗口过后失败次数应重新计算: %v", locked)
+	}
+
+	// IP 的限制较宽，多个用户名的失败累计到同一 IP
+	for i := 0; i < loginMaxFailuresPerIP-(loginMaxFailuresPerUser-1); i++ {
+		failN(t, l, "10.0.0.1", "user"+strconv.Itoa(i), 1)
+	}
+	if wait := l.attempt("10.0.0.1", "dave"); wait != loginLockout {
+		t.Fatalf("IP 失败次数过多后应锁定，剩余 %s", wait)
+	}
+}
+
+func TestLoginLimiterConcurrentAttempts(t *testing.T) {
+	l, _ := newTestLimiter()
+
+	// 尚未得出结果的尝试同样占用失败次数
+	for i := 0; i < loginMaxFailuresPerUser; i++ {
+		if wait := l.attempt("10.0.0.1", "erin"); wait != 0 {
+			t.Fatalf("第 %d 次尝试被拒绝", i+1)
+		}
+	}
+	if wait := l.attempt("10.0.0.2", "erin"); wait != loginPendingRetry {
+		t.Fatalf("进行中的尝试占满次数时应拒绝，剩余 %s", wait)
+	}
+
+	// 撤销的尝试不计入失败
+	l.cancel("10.0.0.1", "erin")
+	if wait := l.attempt("10.0.0.2", "erin"); wait != 0 {
+		t.Fatalf("撤销后应允许尝试，剩余 %s", wait)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/login", nil)
+	req.RemoteAddr = "192.168.1.10:5000"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.5")
+
+	h := &Handler{}
+	if ip := h.clientIP(req); ip != "192.168.1.10" {
+		t.Fatalf("未配置时应使用来源地址，实际为 %s", ip)
+	}
+
+	h.config = config.Config{RealIPHeader: "X-Forwarded-For"}
+	if ip := h.clientIP(req); ip != "10.0.0.5" {
+		t.Fatalf("应使用代理追加的最后一个地址，实际为 %s", ip)
+	}
+
+	req.Header.Set("X-Forwarded-For", "not-an-ip")
+	if ip := h.clientIP(req); ip != "192.168.1.10" {
+		t.Fatalf("请求头无效时应使用来源地址，实际为 %s", ip)
+	}
+}
@@ -1,14 +1,19 @@
 package handlers
 
 import (
-	"encoding/base64"
+	"context"
+	"database/sql"
+	"errors"
 	"mini-catch/internal/config"
+	"mini-catch/internal/database"
+	"mini-catch/internal/password"
 	"net/http"
 	"strings"
+	"time"
 )
 
-// 认证中间件
-func AuthMiddleware(config *config.Config, next http.Handler) http.Handler {
+// 认证中间件，令牌来自 Authorization 头或 auth_token Cookie，为登录会话（见 validateSession）或 API 密钥（见 validateAPIKey）
+func AuthMiddleware(db database.Store, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// 跳过不需要认证的路径
 		if shouldSkipAuth(r.URL.Path) {
@@ -17,49 +22,96 @@ func AuthMiddleware(config *config.Config, next http.Handler) http.Handler {
 		}
 
 		// 检查 Authorization 头
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
+		token, fromCookie := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), false
+		if token == "" {
 			// 尝试从 Cookie 获取认证信息
 			cookie, err := r.Cookie("auth_token")
 			if err != nil || cookie.Value == "" {
 				http.Error(w, "需要认证", http.StatusUnauthorized)
 				return
 			}
-			authHeader = cookie.Value
+			token, fromCookie = cookie.Value, true
 		}
 
-		// 验证认证信息
-		if !validateAuth(authHeader, config) {
+		now := time.Now()
+		if isAPIKey(token) {
+			// API 密钥，权限范围由 RequireScope 校验
+			key, user, ok := validateAPIKey(db, token, now)
+			if !ok {
+				http.Error(w, "认证失败", http.StatusUnauthorized)
+				return
+			}
+			ctx := context.WithValue(r.Context(), userContextKey{}, user)
+			ctx = context.WithValue(ctx, apiKeyContextKey{}, key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		// 验证会话，过期时间随使用顺延
+		session, user, ok := validateSession(db, token, now)
+		if !ok {
 			http.Error(w, "认证失败", http.StatusUnauthorized)
 			return
 		}
+		if renewSession(db, session, now) && fromCookie {
+			setAuthCookie(w, token, session.ExpiresAt)
+		}
 
+		ctx := context.WithValue(r.Context(), userContextKey{}, user)
+		ctx = context.WithValue(ctx, sessionContextKey{}, session)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireAdmin 只允许管理员访问
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user := currentUser(r); user == nil || !user.IsAdmin {
+			http.Error(w, "需要管理员权限", http.StatusForbidden)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
-// 验证认证信息
-func validateAuth(authHeader string, config *config.Config) bool {
-	// 移除 "Bearer " 前缀（如果存在）
-	authHeader = strings.TrimPrefix(authHeader, "Bearer ")
+// userContextKey 请求上下文中当前用户的键
+type userContextKey struct{}
 
-	// 解码 base64
-	decoded, err := base64.StdEncoding.DecodeString(authHeader)
-	if err != nil {
-		return false
-	}
+// currentUser 返回通过认证的用户，未认证的请求返回 nil
+func currentUser(r *http.Request) *database.User {
+	user, _ := r.Context().Value(userContextKey{}).(*database.User)
+	return user
+}
 
-	// 解析用户名和密码
-	parts := strings.Split(string(decoded), ":")
-	if len(parts) != 2 {
-		return false
-	}
+// errInvalidCredentials 用户名或密码错误
+var errInvalidCredentials = errors.New("用户名或密码错误")
 
-	username := parts[0]
-	password := parts[1]
+// authenticate 校验用户名和密码：config.json 中的用户为管理员（密码可为明文或 bcrypt 哈希），其他用户使用数据库中的密码哈希
+func authenticate(config *config.Config, db database.Store, username, pass string) (*database.User, error) {
+	if username == config.Auth.Username {
+		if !password.Matches(config.Auth.Password, pass) {
+			return nil, errInvalidCredentials
+		}
+		return db.GetUserByID(database.AdminUserID)
+	}
 
-	// 验证用户名和密码
-	return username == config.Auth.Username && password == config.Auth.Password
+	user, err := db.GetUserByName(username)
+	if errors.Is(err, sql.ErrNoRows) {
+		// 用户不存在时同样校验一次哈希，避免通过响应时间判断用户是否存在
+		password.VerifyDummy(pass)
+		return nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.PasswordHash == "" {
+		password.VerifyDummy(pass)
+		return nil, errInvalidCredentials
+	}
+	if !password.Verify(user.PasswordHash, pass) {
+		return nil, errInvalidCredentials
+	}
+	return user, nil
 }
 
 // shouldSkipAuth 判断是否需要跳过认证
@@ -84,9 +136,3 @@ func shouldSkipAuth(path string) bool {
 
 	return false
 }
-
-// 生成认证令牌
-func GenerateAuthToken(username, password string) string {
-	token := username + ":" + password
-	return base64.StdEncoding.EncodeToString([]byte(token))
-}
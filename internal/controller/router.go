@@ -20,7 +20,7 @@ func SetupRoutes(config *config.Config, handler *Handler) *chi.Mux {
 
 	// 认证中间件
 	r.Use(func(next http.Handler) http.Handler {
-		return AuthMiddleware(config, next)
+		return AuthMiddleware(handler.db, next)
 	})
 
 	// API 路由
@@ -28,25 +28,117 @@ func SetupRoutes(config *config.Config, handler *Handler) *chi.Mux {
 		// 登录接口（不需要认证）
 		r.Post("/login", handler.LoginHandler)
 
-		// 剧集管理接口
-		r.Get("/series", handler.GetSeriesList)
-		r.Post("/series", handler.CreateSeries)
-		r.Put("/series/{id}", handler.UpdateSeries)
-		r.Delete("/series/{id}", handler.DeleteSeries)
-		r.Post("/series/{id}/watch", handler.MarkAsWatched)
-		r.Post("/series/{id}/unwatch", handler.MarkAsUnwatched)
-		r.Post("/series/{id}/toggle-tracking", handler.ToggleTracking)
-		r.Post("/series/{id}/clear-history", handler.ClearSeriesHistory)
-
-		// 爬虫接口
+		// 读取接口，API 密钥需要 series:read
+		r.Group(func(r chi.Router) {
+			r.Use(RequireScope(ScopeSeriesRead))
+
+			r.Get("/series", handler.GetSeriesList)
+			r.Get("/series/{id}", handler.GetSeries)
+			r.Get("/series/{id}/episodes", handler.GetSeriesEpisodes)
+			r.Get("/series/{id}/events", handler.GetSeriesEvents)
+
+			// 全文搜索
+			r.Get("/search", handler.Search)
+
+			r.Get("/tags", handler.GetTags)
+			r.Get("/trash", handler.GetTrash)
+
+			// 剧集事件
+			r.Get("/events", handler.GetEvents)
+
+			// 爬虫记录
+			r.Get("/crawls", handler.GetCrawlRuns)
+			r.Get("/crawls/{id}", handler.GetCrawlRun)
+
+			// 统计
+			r.Get("/stats", handler.GetStats)
+
+			r.Get("/export", handler.Export)
+
+			// 当前用户
+			r.Get("/me", handler.GetCurrentUser)
+
+			// 配置
+			r.Get("/settings", handler.GetSettings)
+			r.Get("/settings/history", handler.GetSettingsHistory)
+		})
+
+		// 修改接口，API 密钥需要 series:write
+		r.Group(func(r chi.Router) {
+			r.Use(RequireScope(ScopeSeriesWrite))
+
+			r.Post("/series", handler.CreateSeries)
+
+			// 修改剧集的接口，要求 If-Match 并校验剧集版本
+			r.Group(func(r chi.Router) {
+				r.Use(handler.SeriesPrecondition)
+
+				r.Put("/series/{id}", handler.UpdateSeries)
+				r.Delete("/series/{id}", handler.DeleteSeries)
+				r.Post("/series/{id}/watch", handler.MarkAsWatched)
+				r.Post("/series/{id}/unwatch", handler.MarkAsUnwatched)
+				r.Post("/series/{id}/state", handler.SetSeriesState)
+				r.Post("/series/{id}/clear-history", handler.ClearSeriesHistory)
+				r.Post("/series/{id}/restore", handler.RestoreSeries)
+				r.Put("/series/{id}/tags", handler.SetSeriesTags)
+			})
+
+			// 标签
+			r.Post("/tags", handler.CreateTag)
+			r.Put("/tags/{id}", handler.UpdateTag)
+			r.Delete("/tags/{id}", handler.DeleteTag)
+
+			// 导入
+			r.Post("/import", handler.Import)
+			r.Post("/import/watch-history", handler.ImportWatchHistory)
+		})
+
+		// 爬虫接口，API 密钥只需要 fetch 权限
 		r.Route("/fetch", func(r chi.Router) {
-			r.Get("/", handler.HandleFetchTask)
-			r.Post("/", handler.HandleFetchTaskCallback)
+			r.With(RequireScope(ScopeFetchRead)).Get("/", handler.HandleFetchTask)
+			r.With(RequireScope(ScopeFetchWrite)).Post("/", handler.HandleFetchTaskCallback)
+		})
+
+		// 会话和密码，只能使用登录会话访问
+		r.Group(func(r chi.Router) {
+			r.Use(RequireSession)
+
+			r.Put("/me", handler.UpdateCurrentUser)
+			r.Post("/logout", handler.Logout)
+			r.Get("/sessions", handler.GetSessions)
+			r.Delete("/sessions", handler.DeleteOtherSessions)
+			r.Delete("/sessions/{id}", handler.DeleteSession)
 		})
 
-		r.Get("/settings", handler.GetSettings)
-		r.Put("/settings", handler.UpdateSettings)
-		r.Post("/settings/test-slack", handler.TestSlackWebhook)
+		// 以下接口仅管理员可用，API 密钥需要 admin
+		r.Group(func(r chi.Router) {
+			r.Use(RequireAdmin)
+			r.Use(RequireScope(ScopeAdmin))
+
+			// 用户管理
+			r.Get("/users", handler.GetUsers)
+			r.Post("/users", handler.CreateUser)
+			r.Put("/users/{id}", handler.UpdateUser)
+			r.Delete("/users/{id}", handler.DeleteUser)
+
+			// API 密钥
+			r.Get("/admin/api-keys", handler.GetAPIKeys)
+			r.Post("/admin/api-keys", handler.CreateAPIKey)
+			r.Delete("/admin/api-keys/{id}", handler.RevokeAPIKey)
+
+			// 本地备份
+			r.Get("/admin/backups", handler.GetBackups)
+			r.Post("/admin/backups", handler.CreateBackup)
+			r.Get("/admin/backups/{name}", handler.DownloadBackup)
+
+			// 回收站，彻底删除会影响所有用户
+			r.With(handler.SeriesPrecondition).Delete("/trash/{id}", handler.PurgeSeries)
+
+			// 全局配置
+			r.Put("/settings", handler.UpdateSettings)
+			r.Post("/settings/{key}/revert", handler.RevertSetting)
+			r.Post("/settings/test-slack", handler.TestSlackWebhook)
+		})
 	})
 
 	// 静态文件服务
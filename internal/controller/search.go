@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// 搜索结果默认和最大返回条数
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Search 全文搜索剧集名称、原名、集数原始标题和备注，支持 ?q=关键词&limit=N
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		h.errorResponse(w, http.StatusBadRequest, "搜索关键词不能为空")
+		return
+	}
+
+	limit, err := parseLimit(r, defaultSearchLimit, maxSearchLimit)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hits, err := h.store(r).SearchSeries(q, limit)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "搜索失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, hits)
+}
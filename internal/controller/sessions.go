@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mini-catch/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// sessionTTL 会话的空闲有效期，每次使用后顺延
+	sessionTTL = 7 * 24 * time.Hour
+	// sessionMaxAge 会话自创建起的最长有效期，到期后需重新登录
+	sessionMaxAge = 90 * 24 * time.Hour
+	// sessionRenewInterval 距上次顺延超过该时间才更新会话，避免每个请求都写数据库
+	sessionRenewInterval = 10 * time.Minute
+)
+
+// sessionContextKey 请求上下文中当前会话的键
+type sessionContextKey struct{}
+
+// currentSession 返回当前请求使用的会话，未认证的请求返回 nil
+func currentSession(r *http.Request) *database.Session {
+	session, _ := r.Context().Value(sessionContextKey{}).(*database.Session)
+	return session
+}
+
+// newSessionToken 生成 256 位随机令牌
+func newSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken 会话令牌或 API 密钥的 SHA-256 摘要，数据库中只保存摘要
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sessionExpiry 会话在 now 使用后的过期时间，不超过最长有效期
+func sessionExpiry(session *database.Session, now time.Time) time.Time {
+	expiresAt := now.Add(sessionTTL)
+	if limit := session.CreatedAt.Add(sessionMaxAge); expiresAt.After(limit) {
+		return limit
+	}
+	return expiresAt
+}
+
+// clientIP 请求来源的 IP，配置了 real_ip_header 时优先使用代理写入的 IP
+// X-Forwarded-For 取最后一个地址，即可信代理看到的来源地址
+func (h *Handler) clientIP(r *http.Request) string {
+	if name := h.config.RealIPHeader; name != "" {
+		values := strings.Split(r.Header.Get(name), ",")
+		if ip := strings.TrimSpace(values[len(values)-1]); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// validateSession 校验令牌，返回未过期的会话及其用户
+func validateSession(db database.Store, token string, now time.Time) (*database.Session, *database.User, bool) {
+	if token == "" {
+		return nil, nil, false
+	}
+	session, err := db.GetSessionByToken(hashToken(token))
+	if err != nil || !session.ExpiresAt.After(now) {
+		return nil, nil, false
+	}
+	user, err := db.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, nil, false
+	}
+	return session, user, true
+}
+
+// renewSession 距上次顺延超过 sessionRenewInterval 时顺延会话的过期时间，返回是否已顺延
+func renewSession(db database.Store, session *database.Session, now time.Time) bool {
+	if now.Sub(session.LastUsedAt) < sessionRenewInterval {
+		return false
+	}
+	expiresAt := sessionExpiry(session, now)
+	if err := db.RenewSession(session.ID, now, expiresAt); err != nil {
+		return false
+	}
+	session.LastUsedAt = now.UTC().Truncate(time.Second)
+	session.ExpiresAt = expiresAt.UTC().Truncate(time.Second)
+	return true
+}
+
+// startSession 为用户创建会话，设置 Cookie 并返回令牌
+func (h *Handler) startSession(w http.ResponseWriter, r *http.Request, userID int64) {
+	token, err := newSessionToken()
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "生成令牌失败: "+err.Error())
+		return
+	}
+
+	now := time.Now()
+	session, err := h.db.CreateSession(&database.Session{
+		UserID:     userID,
+		TokenHash:  hashToken(token),
+		UserAgent:  r.UserAgent(),
+		IP:         h.clientIP(r),
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(sessionTTL),
+	})
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "创建会话失败: "+err.Error())
+		return
+	}
+
+	setAuthCookie(w, token, session.ExpiresAt)
+	h.successResponse(w, LoginResponse{Token: token, ExpiresAt: session.ExpiresAt})
+}
+
+// setAuthCookie 设置认证 Cookie，与会话同时过期
+func setAuthCookie(w http.ResponseWriter, token string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "auth_token",
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   false, // 在生产环境中应该设置为 true
+		SameSite: http.SameSiteStrictMode,
+		Expires:  expiresAt,
+	})
+}
+
+// clearAuthCookie 删除认证 Cookie
+func clearAuthCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "auth_token",
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   -1,
+	})
+}
+
+// SessionView 接口返回的会话信息
+type SessionView struct {
+	database.Session
+	Current bool `json:"current"` // 是否为当前请求使用的会话
+}
+
+// Logout 注销当前会话
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	session := currentSession(r)
+	if err := h.db.DeleteSession(session.UserID, session.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusInternalServerError, "注销失败: "+err.Error())
+		return
+	}
+	clearAuthCookie(w)
+	h.successResponse(w, nil)
+}
+
+// GetSessions 获取当前用户未过期的会话
+func (h *Handler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	current := currentSession(r)
+	sessions, err := h.db.GetSessions(current.UserID, time.Now())
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取会话失败: "+err.Error())
+		return
+	}
+
+	views := make([]SessionView, 0, len(sessions))
+	for _, session := range sessions {
+		views = append(views, SessionView{Session: session, Current: session.ID == current.ID})
+	}
+	h.successResponse(w, views)
+}
+
+// DeleteSession 注销当前用户的某个会话，注销当前会话时同时删除 Cookie
+func (h *Handler) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的ID")
+		return
+	}
+
+	current := currentSession(r)
+	err = h.db.DeleteSession(current.UserID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "会话不存在")
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "注销会话失败: "+err.Error())
+		return
+	}
+
+	if id == current.ID {
+		clearAuthCookie(w)
+	}
+	h.successResponse(w, nil)
+}
+
+// DeleteOtherSessions 注销当前用户除当前会话以外的所有会话
+func (h *Handler) DeleteOtherSessions(w http.ResponseWriter, r *http.Request) {
+	current := currentSession(r)
+	deleted, err := h.db.DeleteUserSessions(current.UserID, current.ID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "注销会话失败: "+err.Error())
+		return
+	}
+	h.successResponse(w, map[string]int64{"deleted": deleted})
+}
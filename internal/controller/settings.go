@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"mini-catch/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// 配置变更记录默认和最大返回条数
+const (
+	defaultSettingsHistoryLimit = 50
+	maxSettingsHistoryLimit     = 500
+)
+
+// SettingView 配置项定义及当前值，Value 为已保存的值，未设置时为空并使用默认值
+type SettingView struct {
+	database.SettingDef
+	Value string `json:"value"`
+	IsSet bool   `json:"is_set"`
+}
+
+// settingViews 按注册表顺序生成所有配置项，敏感配置只返回掩码
+func settingViews(settings *database.Settings) []SettingView {
+	defs := database.SettingDefs()
+	views := make([]SettingView, 0, len(defs))
+	for _, def := range defs {
+		view := SettingView{SettingDef: def, IsSet: settings.IsSet(def.Key)}
+		if view.IsSet {
+			view.Value = maskSetting(def, settings.Get(def.Key))
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// maskSetting 敏感配置的非空值替换为掩码
+func maskSetting(def database.SettingDef, value string) string {
+	if def.Secret && value != "" {
+		return database.SecretMask
+	}
+	return value
+}
+
+// GetSettings 获取所有配置项及当前值
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.db.GetSettings()
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取配置失败: "+err.Error())
+		return
+	}
+	h.successResponse(w, settingViews(settings))
+}
+
+// UpdateSettings 更新配置，请求体为 {"key": value}，只更新提交的键，空值恢复默认值
+// 敏感配置提交掩码时保持不变
+func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var req map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	values := make(map[string]string, len(req))
+	for key, raw := range req {
+		def, ok := database.LookupSetting(key)
+		if !ok {
+			h.errorResponse(w, http.StatusBadRequest, "未知的配置项: "+key)
+			return
+		}
+
+		value, err := settingString(raw)
+		if err != nil {
+			h.errorResponse(w, http.StatusBadRequest, fmt.Sprintf("%s: %v", key, err))
+			return
+		}
+		if def.Secret && value == database.SecretMask {
+			continue
+		}
+		if values[key], err = def.Normalize(value); err != nil {
+			h.errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if _, err := h.db.UpdateSettings(values, currentUser(r).Username); err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "更新配置失败: "+err.Error())
+		return
+	}
+
+	h.GetSettings(w, r)
+}
+
+// settingString 将 JSON 中的字符串、数字、布尔值和 null 统一转换为字符串
+func settingString(raw interface{}) (string, error) {
+	switch v := raw.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", errors.New("不支持的值类型")
+	}
+}
+
+// GetSettingsHistory 获取配置变更记录，支持 ?key= 过滤，敏感配置的值以掩码代替
+func (h *Handler) GetSettingsHistory(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key != "" {
+		if _, ok := database.LookupSetting(key); !ok {
+			h.errorResponse(w, http.StatusBadRequest, "未知的配置项: "+key)
+			return
+		}
+	}
+
+	limit, err := parseLimit(r, defaultSettingsHistoryLimit, maxSettingsHistoryLimit)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	changes, err := h.db.GetSettingsHistory(key, limit)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取配置变更记录失败: "+err.Error())
+		return
+	}
+
+	for i := range changes {
+		maskChange(&changes[i])
+	}
+	h.successResponse(w, changes)
+}
+
+// maskChange 敏感配置的变更记录只显示是否有值
+func maskChange(c *database.SettingChange) {
+	def, ok := database.LookupSetting(c.Key)
+	if !ok {
+		return
+	}
+	c.OldValue = maskSetting(def, c.OldValue)
+	c.NewValue = maskSetting(def, c.NewValue)
+}
+
+// RevertSetting 将配置恢复为某次变更之前的值
+// 请求体可选 {"change_id": 1}，默认撤销该配置最近一次变更；恢复操作本身也会记录为一次变更
+func (h *Handler) RevertSetting(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	def, ok := database.LookupSetting(key)
+	if !ok {
+		h.errorResponse(w, http.StatusNotFound, "未知的配置项: "+key)
+		return
+	}
+
+	var req struct {
+		ChangeID int64 `json:"change_id"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.errorResponse(w, http.StatusBadRequest, "请求参数错误: "+err.Error())
+			return
+		}
+	}
+
+	var change *database.SettingChange
+	if req.ChangeID != 0 {
+		c, err := h.db.GetSettingChange(req.ChangeID)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && c.Key != key) {
+			h.errorResponse(w, http.StatusNotFound, "变更记录不存在")
+			return
+		}
+		if err != nil {
+			h.errorResponse(w, http.StatusInternalServerError, "获取配置变更记录失败: "+err.Error())
+			return
+		}
+		change = c
+	} else {
+		changes, err := h.db.GetSettingsHistory(key, 1)
+		if err != nil {
+			h.errorResponse(w, http.StatusInternalServerError, "获取配置变更记录失败: "+err.Error())
+			return
+		}
+		if len(changes) == 0 {
+			h.errorResponse(w, http.StatusNotFound, "该配置没有变更记录")
+			return
+		}
+		change = &changes[0]
+	}
+
+	// 注册表的校验规则可能已变化，恢复的值同样需要校验
+	value, err := def.Normalize(change.OldValue)
+	if err != nil {
+		h.errorResponse(w, http.StatusConflict, "无法恢复: "+err.Error())
+		return
+	}
+
+	if _, err := h.db.UpdateSettings(map[string]string{key: value}, currentUser(r).Username); err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "恢复配置失败: "+err.Error())
+		return
+	}
+
+	h.GetSettings(w, r)
+}
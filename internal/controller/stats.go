@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 统计周数默认值和上限
+const (
+	defaultStatsWeeks = 12
+	maxStatsWeeks     = 104
+)
+
+// GetStats 获取统计数据，支持 ?weeks=N 指定统计最近几周
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	weeks := defaultStatsWeeks
+	if weeksStr := r.URL.Query().Get("weeks"); weeksStr != "" {
+		n, err := strconv.Atoi(weeksStr)
+		if err != nil || n <= 0 {
+			h.errorResponse(w, http.StatusBadRequest, "无效的 weeks: "+weeksStr)
+			return
+		}
+		weeks = min(n, maxStatsWeeks)
+	}
+
+	stats, err := h.store(r).GetStats(time.Now(), weeks)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取统计数据失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, stats)
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mini-catch/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// tagRequest 创建/更新标签请求
+type tagRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// GetTags 获取所有标签
+func (h *Handler) GetTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.db.GetAllTags()
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取标签失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, tags)
+}
+
+// CreateTag 创建标签
+func (h *Handler) CreateTag(w http.ResponseWriter, r *http.Request) {
+	var req tagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	req.Name = database.NormalizeTagName(req.Name)
+	if req.Name == "" {
+		h.errorResponse(w, http.StatusBadRequest, "标签名称不能为空")
+		return
+	}
+
+	tag, err := h.db.CreateTag(req.Name, req.Color)
+	if database.IsUniqueViolation(err) {
+		h.errorResponse(w, http.StatusConflict, "标签已存在: "+req.Name)
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "创建标签失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, tag)
+}
+
+// UpdateTag 更新标签
+func (h *Handler) UpdateTag(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的ID")
+		return
+	}
+
+	var req tagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	req.Name = database.NormalizeTagName(req.Name)
+	if req.Name == "" {
+		h.errorResponse(w, http.StatusBadRequest, "标签名称不能为空")
+		return
+	}
+
+	err = h.db.UpdateTag(id, req.Name, req.Color)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "标签不存在")
+		return
+	}
+	if database.IsUniqueViolation(err) {
+		h.errorResponse(w, http.StatusConflict, "标签已存在: "+req.Name)
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "更新标签失败: "+err.Error())
+		return
+	}
+
+	tag, err := h.db.GetTagByID(id)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取标签失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, tag)
+}
+
+// DeleteTag 删除标签
+func (h *Handler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的ID")
+		return
+	}
+
+	err = h.db.DeleteTag(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "标签不存在")
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "删除标签失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, map[string]string{"message": "删除成功"})
+}
+
+// SetSeriesTags 替换剧集的标签，请求体为 {"tags": ["anime", "美剧"]}
+func (h *Handler) SetSeriesTags(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的ID")
+		return
+	}
+
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	_, err = h.store(r).GetSeriesByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "剧集不存在")
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取剧集信息失败: "+err.Error())
+		return
+	}
+
+	if err := h.store(r).SetSeriesTags(id, req.Tags); err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "设置标签失败: "+err.Error())
+		return
+	}
+
+	series, err := h.store(r).GetSeriesByID(id)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取剧集信息失败: "+err.Error())
+		return
+	}
+
+	h.seriesResponse(w, series)
+}
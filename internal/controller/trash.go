@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mini-catch/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetTrash 获取回收站中的剧集
+func (h *Handler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	series, err := h.store(r).GetTrashedSeries()
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取回收站失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, series)
+}
+
+// RestoreSeries 从回收站恢复剧集
+func (h *Handler) RestoreSeries(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的ID")
+		return
+	}
+
+	err = h.store(r).RestoreSeries(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "剧集不在回收站中")
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "恢复剧集失败: "+err.Error())
+		return
+	}
+
+	h.recordEvent(r, id, database.EventRestored, database.EventSourceUser, nil)
+
+	series, err := h.store(r).GetSeriesByID(id)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取剧集信息失败: "+err.Error())
+		return
+	}
+
+	h.seriesResponse(w, series)
+}
+
+// PurgeSeries 彻底删除回收站中的剧集
+func (h *Handler) PurgeSeries(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的ID")
+		return
+	}
+
+	err = h.store(r).PurgeSeries(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "剧集不在回收站中")
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "删除剧集失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, map[string]string{"message": "已彻底删除"})
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mini-catch/internal/database"
+	"mini-catch/internal/password"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// UserView 接口返回的用户信息，个人通知地址以掩码代替
+type UserView struct {
+	database.User
+	HasPassword bool `json:"has_password"`
+}
+
+// newUserView 生成用户信息
+func newUserView(user *database.User) UserView {
+	view := UserView{User: *user, HasPassword: user.PasswordHash != ""}
+	if view.SlackWebhookURL != "" {
+		view.SlackWebhookURL = database.SecretMask
+	}
+	return view
+}
+
+// userRequest 创建/更新用户请求，未提交的字段保持不变
+type userRequest struct {
+	Username        *string `json:"username"`
+	Password        *string `json:"password"`
+	IsAdmin         *bool   `json:"is_admin"`
+	SlackWebhookURL *string `json:"slack_webhook_url"`
+}
+
+// reservedUsernames 登录接口中有特殊含义的用户名
+var reservedUsernames = []string{"CLS", "CLST"}
+
+// normalizeUsername 校验用户名，用户名不能包含冒号，不能使用保留的用户名
+func normalizeUsername(username string) (string, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return "", errors.New("用户名不能为空")
+	}
+	if strings.Contains(username, ":") {
+		return "", errors.New("用户名不能包含冒号")
+	}
+	for _, reserved := range reservedUsernames {
+		if strings.EqualFold(username, reserved) {
+			return "", errors.New("用户名已被保留: " + username)
+		}
+	}
+	return username, nil
+}
+
+// apply 将请求中的字段写入用户，校验用户名、密码和通知地址
+func (req *userRequest) apply(user *database.User) error {
+	if req.Username != nil {
+		username, err := normalizeUsername(*req.Username)
+		if err != nil {
+			return err
+		}
+		if user.ID == database.AdminUserID && username != user.Username {
+			return errors.New("管理员用户名在 config.json 中配置")
+		}
+		user.Username = username
+	}
+
+	if req.Password != nil {
+		if user.ID == database.AdminUserID {
+			return errors.New("管理员密码在 config.json 中配置")
+		}
+		hash, err := password.Hash(*req.Password)
+		if err != nil {
+			return err
+		}
+		user.PasswordHash = hash
+	}
+
+	if req.IsAdmin != nil {
+		if user.ID == database.AdminUserID && !*req.IsAdmin {
+			return errors.New("不能取消 config.json 中管理员的权限")
+		}
+		user.IsAdmin = *req.IsAdmin
+	}
+
+	if req.SlackWebhookURL != nil && *req.SlackWebhookURL != database.SecretMask {
+		def, _ := database.LookupSetting(database.SettingSlackWebhookURL)
+		webhookURL, err := def.Normalize(*req.SlackWebhookURL)
+		if err != nil {
+			return err
+		}
+		user.SlackWebhookURL = webhookURL
+	}
+	return nil
+}
+
+// saveUser 保存用户并返回最新信息，修改了密码时注销该用户的其他会话
+func (h *Handler) saveUser(w http.ResponseWriter, r *http.Request, user *database.User, passwordChanged bool) {
+	err := h.db.UpdateUser(user)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "用户不存在")
+		return
+	}
+	if database.IsUniqueViolation(err) {
+		h.errorResponse(w, http.StatusConflict, "用户名已存在: "+user.Username)
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "更新用户失败: "+err.Error())
+		return
+	}
+
+	if passwordChanged {
+		var keep int64
+		if session := currentSession(r); session != nil && session.UserID == user.ID {
+			keep = session.ID
+		}
+		if _, err := h.db.DeleteUserSessions(user.ID, keep); err != nil {
+			h.errorResponse(w, http.StatusInternalServerError, "注销会话失败: "+err.Error())
+			return
+		}
+	}
+
+	updated, err := h.db.GetUserByID(user.ID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取用户失败: "+err.Error())
+		return
+	}
+	h.successResponse(w, newUserView(updated))
+}
+
+// GetCurrentUser 获取当前登录的用户
+func (h *Handler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	h.successResponse(w, newUserView(currentUser(r)))
+}
+
+// UpdateCurrentUser 修改自己的密码和个人通知地址
+func (h *Handler) UpdateCurrentUser(w http.ResponseWriter, r *http.Request) {
+	var req userRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+	if req.Username != nil || req.IsAdmin != nil {
+		h.errorResponse(w, http.StatusForbidden, "只有管理员可以修改用户名和权限")
+		return
+	}
+
+	user := *currentUser(r)
+	if err := req.apply(&user); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.saveUser(w, r, &user, req.Password != nil)
+}
+
+// GetUsers 获取所有用户
+func (h *Handler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.db.GetUsers()
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取用户失败: "+err.Error())
+		return
+	}
+
+	views := make([]UserView, 0, len(users))
+	for i := range users {
+		views = append(views, newUserView(&users[i]))
+	}
+	h.successResponse(w, views)
+}
+
+// CreateUser 创建用户，用户名和密码必填
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var req userRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+	if req.Username == nil || req.Password == nil {
+		h.errorResponse(w, http.StatusBadRequest, "用户名和密码不能为空")
+		return
+	}
+
+	var user database.User
+	if err := req.apply(&user); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.EqualFold(user.Username, h.config.Auth.Username) {
+		h.errorResponse(w, http.StatusConflict, "用户名已存在: "+user.Username)
+		return
+	}
+
+	created, err := h.db.CreateUser(&user)
+	if database.IsUniqueViolation(err) {
+		h.errorResponse(w, http.StatusConflict, "用户名已存在: "+user.Username)
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "创建用户失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, newUserView(created))
+}
+
+// UpdateUser 更新用户的用户名、密码、管理员权限和个人通知地址
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的ID")
+		return
+	}
+
+	var req userRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+	if id == currentUser(r).ID && req.IsAdmin != nil && !*req.IsAdmin {
+		h.errorResponse(w, http.StatusBadRequest, "不能取消自己的管理员权限")
+		return
+	}
+
+	user, err := h.db.GetUserByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "用户不存在")
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "获取用户失败: "+err.Error())
+		return
+	}
+
+	if err := req.apply(user); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if id != database.AdminUserID && strings.EqualFold(user.Username, h.config.Auth.Username) {
+		h.errorResponse(w, http.StatusConflict, "用户名已存在: "+user.Username)
+		return
+	}
+	h.saveUser(w, r, user, req.Password != nil)
+}
+
+// DeleteUser 删除用户及其追踪订阅和观看状态，config.json 中的管理员和自己不能删除
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "无效的ID")
+		return
+	}
+	if id == database.AdminUserID || id == currentUser(r).ID {
+		h.errorResponse(w, http.StatusBadRequest, "不能删除该用户")
+		return
+	}
+
+	err = h.db.DeleteUser(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.errorResponse(w, http.StatusNotFound, "用户不存在")
+		return
+	}
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "删除用户失败: "+err.Error())
+		return
+	}
+
+	h.successResponse(w, map[string]string{"message": "删除成功"})
+}
@@ -18,6 +18,7 @@ import (
 // Config 爬虫配置
 type Config struct {
 	ServerURL string `json:"server_url"`
+	APIKey    string `json:"api_key"` // 具有 fetch:read、fetch:write 权限的 API 密钥，设置后不使用用户名和密码登录
 	Username  string `json:"username"`
 	Password  string `json:"password"`
 	Debug     bool   `json:"debug"`
@@ -27,18 +28,24 @@ type Config struct {
 
 // SeriesInfo 剧集信息
 type SeriesInfo struct {
-	Name   string   `json:"name"`
-	URL    string   `json:"url"`
-	Update string   `json:"update"`
-	Series []string `json:"series"`
+	Name     string                  `json:"name"`
+	URL      string                  `json:"url"`
+	Update   string                  `json:"update"`
+	Series   []string                `json:"series"`
+	Episodes []database.FetchEpisode `json:"episodes,omitempty"`
+
+	PosterURL     string `json:"poster_url,omitempty"`
+	OriginalTitle string `json:"original_title,omitempty"`
 }
 
 // CrawlResult 爬取结果
 type CrawlResult struct {
-	Tasks   []string     `json:"tasks"`
-	Results []SeriesInfo `json:"results"`
-	Status  int          `json:"status"`
-	Message string       `json:"message"`
+	Tasks      []string          `json:"tasks"`
+	Results    []SeriesInfo      `json:"results"`
+	Status     int               `json:"status"`
+	Message    string            `json:"message"`
+	DurationMs int64             `json:"duration_ms"`
+	Errors     map[string]string `json:"errors,omitempty"`
 }
 
 // Mini4KCrawler mini4k 爬虫
@@ -242,6 +249,14 @@ func (c *Mini4KCrawler) fetchMini4KSeries(url string) (*SeriesInfo, error) {
 			return nil
 		}),
 
+		// 获取海报（如果存在）
+		chromedp.Evaluate(`
+			(() => {
+				const meta = document.querySelector('meta[property="og:image"]');
+				return meta ? meta.content : '';
+			})()
+		`, &seriesInfo.PosterURL),
+
 		// 获取所有剧集链接
 		chromedp.Evaluate(`
 			(() => {
@@ -258,28 +273,30 @@ func (c *Mini4KCrawler) fetchMini4KSeries(url string) (*SeriesInfo, error) {
 	// 处理剧集信息
 	seriesInfo.URL = url
 
-	// 从剧集标题中提取季集信息
-	var episodes []string
+	// 从剧集标题中提取季集信息，同一集保留第一个原始标题
 	episodeRegex := regexp.MustCompile(`(S\d+E\d+)`)
+	episodeMap := make(map[string]string)
 
-	for _, series := range seriesInfo.Series {
-		if matches := episodeRegex.FindStringSubmatch(series); len(matches) > 1 {
-			episodes = append(episodes, matches[1])
+	for _, title := range seriesInfo.Series {
+		if matches := episodeRegex.FindStringSubmatch(title); len(matches) > 1 {
+			if _, ok := episodeMap[matches[1]]; !ok {
+				episodeMap[matches[1]] = title
+			}
 		}
 	}
 
 	// 去重并排序
-	episodeMap := make(map[string]bool)
-	for _, episode := range episodes {
-		episodeMap[episode] = true
-	}
-
 	seriesInfo.Series = make([]string, 0, len(episodeMap))
 	for episode := range episodeMap {
 		seriesInfo.Series = append(seriesInfo.Series, episode)
 	}
 	sort.Strings(seriesInfo.Series)
 
+	seriesInfo.Episodes = make([]database.FetchEpisode, 0, len(seriesInfo.Series))
+	for _, episode := range seriesInfo.Series {
+		seriesInfo.Episodes = append(seriesInfo.Episodes, database.FetchEpisode{Code: episode, Title: episodeMap[episode]})
+	}
+
 	// 如果 Update 为空，取 Series 最后一个
 	if seriesInfo.Update == "" && len(seriesInfo.Series) > 0 {
 		seriesInfo.Update = fmt.Sprintf("已更新到 %s", seriesInfo.Series[len(seriesInfo.Series)-1])
@@ -293,6 +310,7 @@ func (c *Mini4KCrawler) fetchMini4KSeries(url string) (*SeriesInfo, error) {
 // fetchMini4KSeriesWithRetry 带重试的爬取
 func (c *Mini4KCrawler) fetchMini4KSeriesWithRetry(url string) (*SeriesInfo, error) {
 	maxRetries := 3
+	var lastErr error
 	for i := 0; i < maxRetries; i++ {
 		c.printAgent(fmt.Sprintf("fetching url %s (attempt %d/%d)", url, i+1, maxRetries))
 
@@ -300,6 +318,7 @@ func (c *Mini4KCrawler) fetchMini4KSeriesWithRetry(url string) (*SeriesInfo, err
 		if err == nil {
 			return result, nil
 		}
+		lastErr = err
 
 		c.printAgent(fmt.Sprintf("attempt %d failed: %v", i+1, err))
 		if i < maxRetries-1 {
@@ -307,17 +326,19 @@ func (c *Mini4KCrawler) fetchMini4KSeriesWithRetry(url string) (*SeriesInfo, err
 		}
 	}
 
-	return nil, fmt.Errorf("爬取失败，已重试 %d 次", maxRetries)
+	return nil, fmt.Errorf("爬取失败，已重试 %d 次: %v", maxRetries, lastErr)
 }
 
-// crawlTasks 爬取任务列表
-func (c *Mini4KCrawler) crawlTasks(urls []string) []SeriesInfo {
+// crawlTasks 爬取任务列表，返回成功的结果及失败 URL 的原因
+func (c *Mini4KCrawler) crawlTasks(urls []string) ([]SeriesInfo, map[string]string) {
 	var results []SeriesInfo
+	failures := make(map[string]string)
 
 	for _, url := range urls {
 		result, err := c.fetchMini4KSeriesWithRetry(url)
 		if err != nil {
 			log.Printf("爬取 %s 失败: %v", url, err)
+			failures[url] = err.Error()
 			continue
 		}
 
@@ -325,16 +346,18 @@ func (c *Mini4KCrawler) crawlTasks(urls []string) []SeriesInfo {
 		log.Printf("成功爬取: %s -> %s", url, result.Name)
 	}
 
-	return results
+	return results, failures
 }
 
 // reportResults 上报爬取结果
-func (c *Mini4KCrawler) reportResults(tasks []string, results []SeriesInfo) error {
+func (c *Mini4KCrawler) reportResults(tasks []string, results []SeriesInfo, failures map[string]string, duration time.Duration) error {
 	callbackData := CrawlResult{
-		Tasks:   tasks,
-		Results: results,
-		Status:  1,
-		Message: "success",
+		Tasks:      tasks,
+		Results:    results,
+		Status:     1,
+		Message:    "success",
+		DurationMs: duration.Milliseconds(),
+		Errors:     failures,
 	}
 
 	jsonData, err := json.Marshal(callbackData)
@@ -381,11 +404,18 @@ func (c *Mini4KCrawler) reportResults(tasks []string, results []SeriesInfo) erro
 func (c *Mini4KCrawler) Run() error {
 	log.Printf("🚀 启动 mini4k 爬虫")
 	log.Printf("📡 目标服务器: %s", c.config.ServerURL)
-	log.Printf("👤 用户名: %s", c.config.Username)
 
-	// 登录获取认证令牌
-	if err := c.login(); err != nil {
-		return fmt.Errorf("登录失败: %v", err)
+	if c.config.APIKey != "" {
+		// 使用 API 密钥，无需登录
+		log.Printf("🔑 使用 API 密钥认证")
+		c.authToken = c.config.APIKey
+	} else {
+		log.Printf("👤 用户名: %s", c.config.Username)
+
+		// 登录获取认证令牌
+		if err := c.login(); err != nil {
+			return fmt.Errorf("登录失败: %v", err)
+		}
 	}
 
 	// 获取任务
@@ -401,10 +431,11 @@ func (c *Mini4KCrawler) Run() error {
 
 	// 爬取任务
 	log.Printf("🕷️ 开始爬取 %d 个任务", len(task.URLs))
-	results := c.crawlTasks(task.URLs)
+	start := time.Now()
+	results, failures := c.crawlTasks(task.URLs)
 
 	// 上报结果
-	if err := c.reportResults(task.URLs, results); err != nil {
+	if err := c.reportResults(task.URLs, results, failures, time.Since(start)); err != nil {
 		return fmt.Errorf("上报结果失败: %v", err)
 	}
 
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// APIKey 供爬虫和自动化脚本使用的 API 密钥，以创建者的身份访问限定范围的接口
+// 密钥只保存 SHA-256 摘要，Prefix 为密钥开头的几个字符，用于辨认
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"` // 不为空时密钥已吊销
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at, revoked_at`
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var k APIKey
+	var scopesJSON string
+	var lastUsedAt, revokedAt sql.NullTime
+	if err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, &scopesJSON, &k.CreatedAt, &lastUsedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(scopesJSON), &k.Scopes); err != nil {
+		return nil, err
+	}
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+	return &k, nil
+}
+
+// CreateAPIKey 创建 API 密钥
+func (d *Database) CreateAPIKey(key *APIKey) (*APIKey, error) {
+	scopesJSON, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := d.db.Exec(`
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes)
+		VALUES (?, ?, ?, ?, ?)
+	`, key.UserID, key.Name, key.Prefix, key.KeyHash, string(scopesJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return scanAPIKey(d.db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ?", id))
+}
+
+// GetAPIKeys 获取所有 API 密钥（含已吊销的），最新创建的在前
+func (d *Database) GetAPIKeys() ([]APIKey, error) {
+	rows, err := d.db.Query("SELECT " + apiKeyColumns + " FROM api_keys ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKeyByHash 根据密钥摘要获取 API 密钥，不检查是否已吊销
+func (d *Database) GetAPIKeyByHash(keyHash string) (*APIKey, error) {
+	return scanAPIKey(d.db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = ?", keyHash))
+}
+
+// TouchAPIKey 记录 API 密钥的最近使用时间，密钥不存在时返回 sql.ErrNoRows
+func (d *Database) TouchAPIKey(id int64, usedAt time.Time) error {
+	result, err := d.db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", sqliteTime(usedAt), id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// RevokeAPIKey 吊销 API 密钥，密钥不存在或已吊销时返回 sql.ErrNoRows
+func (d *Database) RevokeAPIKey(id int64, revokedAt time.Time) error {
+	result, err := d.db.Exec("UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", sqliteTime(revokedAt), id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Backup 使用 SQLite 在线备份 API 将数据库完整复制到 destPath，备份期间不阻塞读写
+//
+// 先写入临时文件再重命名，避免留下不完整的备份。
+func (d *Database) Backup(destPath string) error {
+	ctx := context.Background()
+	tmpPath := destPath + ".tmp"
+	os.Remove(tmpPath)
+
+	dest, err := sql.Open("sqlite3", tmpPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	srcConn, err := d.pool.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	err = destConn.Raw(func(destRaw interface{}) error {
+		return srcConn.Raw(func(srcRaw interface{}) error {
+			destSQLite, ok := destRaw.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("不支持的数据库连接类型: %T", destRaw)
+			}
+			srcSQLite, ok := srcRaw.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("不支持的数据库连接类型: %T", srcRaw)
+			}
+
+			backup, err := destSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return err
+			}
+			// -1 表示一次复制所有页
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// 关闭连接后再重命名，确保数据已落盘
+	destConn.Close()
+	dest.Close()
+	return os.Rename(tmpPath, destPath)
+}
+
+// CheckDatabaseFile 检查文件是否为完整可用的 SQLite 数据库，用于恢复备份前的校验
+func CheckDatabaseFile(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow("PRAGMA quick_check").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("数据库校验失败: %s", result)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// 单个 URL 的爬取结果
+const (
+	CrawlOutcomeNewEpisodes   = "new_episodes"   // 发现新集数
+	CrawlOutcomeStatusChanged = "status_changed" // 更新摘要变化
+	CrawlOutcomeUnchanged     = "unchanged"      // 没有变化
+	CrawlOutcomeUnknown       = "unknown_series" // 回调中的 URL 没有对应剧集
+	CrawlOutcomeError         = "error"          // 服务端处理失败
+	CrawlOutcomeMissing       = "missing"        // 任务中的 URL 没有返回结果（爬取失败）
+)
+
+// CrawlRun 一次爬虫回调记录
+type CrawlRun struct {
+	ID          int64          `json:"id"`
+	Tasks       []string       `json:"tasks"`
+	TaskCount   int            `json:"task_count"`
+	ResultCount int            `json:"result_count"`
+	Status      int            `json:"status"` // 爬虫上报的状态，小于 0 表示失败
+	Message     string         `json:"message"`
+	DurationMs  int64          `json:"duration_ms"` // 爬虫上报的耗时，旧版爬虫为 0
+	CreatedAt   time.Time      `json:"created_at"`
+	Items       []CrawlRunItem `json:"items,omitempty"`
+}
+
+// CrawlRunItem 单个 URL 的处理结果
+type CrawlRunItem struct {
+	URL      string `json:"url"`
+	SeriesID *int64 `json:"series_id"`
+	Outcome  string `json:"outcome"`
+	Detail   string `json:"detail"`
+}
+
+// CreateCrawlRun 保存爬虫回调记录
+func (d *Database) CreateCrawlRun(run *CrawlRun) (int64, error) {
+	tasksJSON, err := json.Marshal(run.Tasks)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := d.begin()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO crawl_runs (tasks, task_count, result_count, status, message, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, tasksJSON, len(run.Tasks), run.ResultCount, run.Status, run.Message, run.DurationMs)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	for _, item := range run.Items {
+		_, err := tx.Exec(`
+			INSERT INTO crawl_run_items (run_id, url, series_id, outcome, detail)
+			VALUES (?, ?, ?, ?, ?)
+		`, id, item.URL, item.SeriesID, item.Outcome, item.Detail)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	return id, tx.Commit()
+}
+
+// crawlRunColumns 爬虫记录查询字段，顺序需与 scanCrawlRun 一致
+const crawlRunColumns = `id, tasks, task_count, result_count, status, message, duration_ms, created_at`
+
+// scanCrawlRun 扫描一行爬虫记录（不含明细）
+func scanCrawlRun(row rowScanner) (*CrawlRun, error) {
+	var run CrawlRun
+	var tasksJSON string
+	err := row.Scan(
+		&run.ID, &tasksJSON, &run.TaskCount, &run.ResultCount,
+		&run.Status, &run.Message, &run.DurationMs, &run.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(tasksJSON), &run.Tasks); err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// GetCrawlRuns 获取最近的爬虫记录，按时间倒序
+func (d *Database) GetCrawlRuns(limit int) ([]CrawlRun, error) {
+	rows, err := d.db.Query(`
+		SELECT `+crawlRunColumns+`
+		FROM crawl_runs
+		ORDER BY id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []CrawlRun{}
+	for rows.Next() {
+		run, err := scanCrawlRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// GetCrawlRun 获取单次爬虫记录及每个 URL 的处理结果
+func (d *Database) GetCrawlRun(id int64) (*CrawlRun, error) {
+	run, err := scanCrawlRun(d.db.QueryRow(`
+		SELECT `+crawlRunColumns+`
+		FROM crawl_runs WHERE id = ?
+	`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.Query(`
+		SELECT url, series_id, outcome, detail
+		FROM crawl_run_items WHERE run_id = ?
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	run.Items = []CrawlRunItem{}
+	for rows.Next() {
+		var item CrawlRunItem
+		var seriesID sql.NullInt64
+		if err := rows.Scan(&item.URL, &seriesID, &item.Outcome, &item.Detail); err != nil {
+			return nil, err
+		}
+		if seriesID.Valid {
+			item.SeriesID = &seriesID.Int64
+		}
+		run.Items = append(run.Items, item)
+	}
+
+	return run, rows.Err()
+}
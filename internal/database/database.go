@@ -2,16 +2,21 @@ package database
 
 import (
 	"database/sql"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 type Database struct {
-	db *sql.DB
+	pool   *sql.DB
+	db     conn  // 执行语句的连接，Transaction 中为事务
+	userID int64 // 追踪订阅和观看状态所属的用户，0 表示管理员，见 ForUser
 }
 
 // Series 剧集信息
@@ -19,20 +24,23 @@ type Series struct {
 	ID              int64      `json:"id"`
 	Name            string     `json:"name"`
 	URL             string     `json:"url"`
-	History         []string   `json:"history"`     // 历史集数 ["S01E01", "S01E02", ...]
-	Current         string     `json:"current"`     // 当前更新到的集数 "S03E02"
-	IsWatched       bool       `json:"is_watched"`  // 当前集数是否已观看
-	IsTracking      bool       `json:"is_tracking"` // 是否启用追踪
+	History         []string   `json:"history"`    // 历史集数 ["S01E01", "S01E02", ...]，由 episodes 表生成
+	Current         string     `json:"current"`    // 当前更新到的集数 "S03E02"
+	IsWatched       bool       `json:"is_watched"` // 所有集数是否都已观看
+	UnwatchedCount  int        `json:"unwatched_count"`
+	NextUnwatched   string     `json:"next_unwatched"` // 下一集未看的集数，全部看完时为空
+	State           string     `json:"state"`          // 当前用户的剧集状态，见 SeriesStates，空值表示不在片单中
 	CreatedAt       time.Time  `json:"created_at"`
 	UpdatedAt       time.Time  `json:"updated_at"` // History, Current 更新才算
 	CrawlerLastSeen *time.Time `json:"crawler_last_seen"`
-}
-
-// Settings 全局配置
-type Settings struct {
-	CrawlerStartTime string `json:"crawler_start_time"`
-	CrawlerEndTime   string `json:"crawler_end_time"`
-	SlackWebhookURL  string `json:"slack_webhook_url"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"` // 移至回收站的时间
+	Version         int64      `json:"version"`              // 行版本，剧集返回的任何数据变化时递增，用于 ETag
+	Tags            []string   `json:"tags"`
+	SeriesStatus               // 由 Current 解析
+	FinishedAt      *time.Time `json:"finished_at"` // 爬虫发现已完结的时间，未完结时为空
+	SeriesMetadata
+	Notes  string `json:"notes"`  // 当前用户的备注
+	Rating int    `json:"rating"` // 当前用户的评分 1-10，0 表示未评分
 }
 
 // FetchTask 爬虫任务
@@ -42,20 +50,67 @@ type FetchTask struct {
 
 // FetchResult 爬虫结果
 type FetchResult struct {
-	Name   string   `json:"name"`
-	Update string   `json:"update"`
-	URL    string   `json:"url"`
-	Series []string `json:"series"`
+	Name     string         `json:"name"`
+	Update   string         `json:"update"`
+	URL      string         `json:"url"`
+	Series   []string       `json:"series"`
+	Episodes []FetchEpisode `json:"episodes,omitempty"` // 带原始标题的集数，旧版爬虫不提供
+
+	// 页面提供时填充，用户填写的值优先
+	PosterURL     string `json:"poster_url,omitempty"`
+	OriginalTitle string `json:"original_title,omitempty"`
+}
+
+// FetchEpisode 爬虫发现的单集
+type FetchEpisode struct {
+	Code  string `json:"code"`  // "S01E01"
+	Title string `json:"title"` // 页面上的原始标题
+}
+
+// EpisodeList 合并 Series 与 Episodes，返回带标题的集数列表
+func (r FetchResult) EpisodeList() []FetchEpisode {
+	titles := make(map[string]string, len(r.Episodes))
+	for _, ep := range r.Episodes {
+		if _, ok := titles[ep.Code]; !ok {
+			titles[ep.Code] = ep.Title
+		}
+	}
+
+	seen := make(map[string]bool, len(r.Series))
+	var episodes []FetchEpisode
+	for _, code := range r.Series {
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		episodes = append(episodes, FetchEpisode{Code: code, Title: titles[code]})
+	}
+	for _, ep := range r.Episodes {
+		if seen[ep.Code] {
+			continue
+		}
+		seen[ep.Code] = true
+		episodes = append(episodes, ep)
+	}
+	return episodes
 }
 
 // FetchCallback 爬虫回调
 type FetchCallback struct {
-	Tasks   []string      `json:"tasks"`
-	Results []FetchResult `json:"results"`
-	Status  int           `json:"status"`
-	Message string        `json:"message"`
+	Tasks      []string          `json:"tasks"`
+	Results    []FetchResult     `json:"results"`
+	Status     int               `json:"status"`
+	Message    string            `json:"message"`
+	DurationMs int64             `json:"duration_ms,omitempty"` // 本次爬取耗时
+	Errors     map[string]string `json:"errors,omitempty"`      // 爬取失败的 URL 及原因
 }
 
+// SQLite 连接配置
+const (
+	busyTimeoutMs = 5000 // 等待其他连接释放锁的时间
+	maxOpenConns  = 4
+)
+
 func NewDatabase(dbPath string) (*Database, error) {
 	dir := filepath.Dir(dbPath)
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
@@ -64,173 +119,418 @@ func NewDatabase(dbPath string) (*Database, error) {
 			return nil, err
 		}
 	}
-	db, err := sql.Open("sqlite3", dbPath)
+	// 启用外键约束，删除剧集时级联删除集数
+	// WAL 模式下读写互不阻塞；写事务在开始时即获取写锁，锁冲突时等待而不是立即返回 SQLITE_BUSY
+	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on&_journal_mode=WAL&_synchronous=NORMAL"+
+		"&_busy_timeout="+strconv.Itoa(busyTimeoutMs)+"&_txlock=immediate")
 	if err != nil {
 		return nil, err
 	}
+	// SQLite 同一时间只有一个写入者，连接过多只会增加锁等待；保留空闲连接以复用连接级的 PRAGMA
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxOpenConns)
+
+	d := &Database{pool: db, db: db}
+	if err := d.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
 
-	return &Database{db: db}, nil
+	return d, nil
 }
 
 func (d *Database) Close() error {
-	return d.db.Close()
-}
-
-func (d *Database) CreateTables() error {
-	// 创建剧集表
-	createSeriesTable := `
-	CREATE TABLE IF NOT EXISTS series (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		url TEXT UNIQUE NOT NULL,
-		history TEXT NOT NULL DEFAULT '[]',
-		current TEXT NOT NULL DEFAULT '',
-		is_watched BOOLEAN NOT NULL DEFAULT 0,
-		is_tracking BOOLEAN NOT NULL DEFAULT 1,
-		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	_, err := d.db.Exec(createSeriesTable)
-
-	if err != nil {
-		return err
+	// 事务中的存储随 Transaction 结束，不能关闭共用的连接池
+	if _, ok := d.db.(*sql.Tx); ok {
+		return nil
 	}
+	return d.pool.Close()
+}
 
-	if exists, err := d.columnExists("series", "crawler_last_seen"); err == nil && !exists {
-		_, err = d.db.Exec("ALTER TABLE series ADD COLUMN crawler_last_seen DATETIME")
-		if err != nil {
-			return err
-		}
+// seriesColumns 剧集查询字段，顺序需与 scanSeries 一致
+// 剧集状态来自当前用户的片单，观看进度由 episodes 表和当前用户的 episode_watches 汇总
+func (d *Database) seriesColumns() string {
+	unwatched := fmt.Sprintf(`NOT EXISTS (
+		SELECT 1 FROM episode_watches w WHERE w.episode_id = e.id AND w.user_id = %d)`, d.user())
+	return fmt.Sprintf(`series.id, series.name, series.url, series.current,
+	`+stateColumn+` AS state,
+	series.created_at, series.updated_at, series.crawler_last_seen, series.deleted_at, series.version,
+	(SELECT COUNT(*) FROM episodes e WHERE e.series_id = series.id) AS episode_count,
+	(SELECT COUNT(*) FROM episodes e WHERE e.series_id = series.id AND %s) AS unwatched_count,
+	COALESCE((SELECT e.code FROM episodes e WHERE e.series_id = series.id AND %s
+		ORDER BY e.season, e.number, e.code LIMIT 1), '') AS next_unwatched,
+	%s,
+	COALESCE((SELECT n.notes FROM series_notes n WHERE n.series_id = series.id AND n.user_id = %d), '') AS notes,
+	COALESCE((SELECT n.rating FROM series_notes n WHERE n.series_id = series.id AND n.user_id = %d), 0) AS rating,
+	%s`,
+		d.user(), unwatched, unwatched, metadataColumns, d.user(), d.user(), statusColumns)
+}
+
+// IsUniqueViolation 判断是否为唯一约束冲突
+func IsUniqueViolation(err error) bool {
+	if errors.Is(err, ErrUniqueViolation) {
+		return true
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
 	}
+	return false
+}
 
-	// 创建全局配置表
-	createSettingsTable := `
-	CREATE TABLE IF NOT EXISTS settings (
-		key TEXT PRIMARY KEY,
-		value TEXT
-	);`
-	_, err = d.db.Exec(createSettingsTable)
+// rowScanner 兼容 *sql.Row 和 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// requireAffected 没有行被修改时返回 sql.ErrNoRows
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
-
-	return err
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
 
-func (d *Database) columnExists(tableName, columnName string) (bool, error) {
-	rows, err := d.db.Query("PRAGMA table_info(" + tableName + ")")
+// scanSeries 扫描一行剧集数据（不含历史集数）
+func scanSeries(row rowScanner) (*Series, error) {
+	var s Series
+	var crawlerLastSeen, deletedAt, finishedAt sql.NullTime
+	var episodeCount int
+	err := row.Scan(
+		&s.ID, &s.Name, &s.URL, &s.Current,
+		&s.State, &s.CreatedAt, &s.UpdatedAt,
+		&crawlerLastSeen, &deletedAt, &s.Version,
+		&episodeCount, &s.UnwatchedCount, &s.NextUnwatched,
+		&s.PosterURL, &s.OriginalTitle, &s.Year, &s.DoubanID, &s.IMDbID, &s.TMDBID,
+		&s.Notes, &s.Rating,
+		&s.LatestEpisode, &s.TotalEpisodes, &finishedAt,
+	)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var cid int
-		var name, ctype string
-		var notnull, pk int
-		var dfltValue interface{}
-		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dfltValue, &pk); err != nil {
-			return false, err
-		}
-		if name == columnName {
-			return true, nil
-		}
+	// 没有任何集数时视为未看
+	s.IsWatched = episodeCount > 0 && s.UnwatchedCount == 0
+
+	if crawlerLastSeen.Valid {
+		s.CrawlerLastSeen = &crawlerLastSeen.Time
+	}
+	if deletedAt.Valid {
+		s.DeletedAt = &deletedAt.Time
 	}
-	return false, nil
+	if finishedAt.Valid {
+		s.FinishedAt = &finishedAt.Time
+		s.Finished = true
+	}
+	s.History = []string{}
+	s.Tags = []string{}
+
+	return &s, nil
 }
 
-// 获取所有剧集
-func (d *Database) GetAllSeries() ([]Series, error) {
-	rows, err := d.db.Query(`
-		SELECT id, name, url, history, current, is_watched, is_tracking, created_at, updated_at, crawler_last_seen
-		FROM series
-		ORDER BY is_tracking DESC, updated_at DESC
-	`)
+// querySeries 查询剧集列表并填充历史集数、标签等关联数据
+func (d *Database) querySeries(query string, args ...interface{}) ([]Series, error) {
+	rows, err := d.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var series []Series
+	series := []Series{}
 	for rows.Next() {
-		var s Series
-		var historyJSON string
-		var crawlerLastSeen sql.NullTime
-		err := rows.Scan(
-			&s.ID, &s.Name, &s.URL, &historyJSON, &s.Current,
-			&s.IsWatched, &s.IsTracking, &s.CreatedAt, &s.UpdatedAt,
-			&crawlerLastSeen,
-		)
+		s, err := scanSeries(rows)
 		if err != nil {
 			return nil, err
 		}
+		series = append(series, *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// 先释放连接再查询关联数据
+	rows.Close()
 
-		// 解析历史集数 JSON
-		if err := json.Unmarshal([]byte(historyJSON), &s.History); err != nil {
-			return nil, err
+	if err := d.fillDetails(series); err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+// querySingleSeries 查询单个剧集，不存在时返回 sql.ErrNoRows
+func (d *Database) querySingleSeries(query string, args ...interface{}) (*Series, error) {
+	s, err := scanSeries(d.db.QueryRow(query, args...))
+	if err != nil {
+		return nil, err
+	}
+
+	series := []Series{*s}
+	if err := d.fillDetails(series); err != nil {
+		return nil, err
+	}
+
+	return &series[0], nil
+}
+
+// fillDetails 填充剧集的关联数据
+func (d *Database) fillDetails(series []Series) error {
+	if len(series) == 0 {
+		return nil
+	}
+
+	index := make(map[int64]*Series, len(series))
+	for i := range series {
+		index[series[i].ID] = &series[i]
+	}
+
+	// 历史集数由 episodes 表生成
+	if err := d.fillHistory(series, index); err != nil {
+		return err
+	}
+
+	return d.fillTags(series, index)
+}
+
+// seriesIDArgs 生成 IN 查询的占位符和参数
+func seriesIDArgs(series []Series) (string, []interface{}) {
+	placeholders := make([]string, len(series))
+	args := make([]interface{}, len(series))
+	for i, s := range series {
+		placeholders[i] = "?"
+		args[i] = s.ID
+	}
+	return strings.Join(placeholders, ", "), args
+}
+
+// SeriesQuery 剧集列表查询条件，零值表示不过滤、使用默认排序、不分页
+type SeriesQuery struct {
+	Tag     string        // 标签名，不区分大小写
+	States  []string      // 当前用户的剧集状态，满足其一即可，空值表示不在片单中
+	Watched *bool         // 是否所有集数都已观看
+	Q       string        // 名称或原名子串
+	Sort    string        // 排序字段，见 seriesSortColumns，为空时按追踪状态和更新时间排序
+	Desc    bool          // 是否倒序，仅在指定 Sort 时生效
+	Limit   int           // 每页数量，0 表示不分页
+	After   *SeriesCursor // 上一页最后一行的游标，nil 表示第一页
+}
+
+// SeriesCursor 剧集列表的分页游标，记录上一页最后一行的排序字段和 id
+// 下一页从排在该行之后的剧集开始，翻页期间剧集增删或修改不会导致重复或遗漏
+type SeriesCursor struct {
+	Tracking bool       `json:"tracking,omitempty"` // 默认排序时是否在追踪
+	Name     string     `json:"name,omitempty"`     // 按名称排序时的名称
+	Time     *time.Time `json:"time,omitempty"`     // 按时间排序（含默认排序的更新时间）时的值，crawler_last_seen 为空时为 nil
+	ID       int64      `json:"id"`
+}
+
+// NewSeriesCursor 返回剧集在 sortKey 排序下的游标
+func NewSeriesCursor(s Series, sortKey string) *SeriesCursor {
+	c := &SeriesCursor{ID: s.ID}
+	switch sortKey {
+	case "":
+		c.Tracking = IsTrackingState(s.State)
+		c.Time = &s.UpdatedAt
+	case "name":
+		c.Name = s.Name
+	case "updated_at":
+		c.Time = &s.UpdatedAt
+	case "created_at":
+		c.Time = &s.CreatedAt
+	case "crawler_last_seen":
+		c.Time = s.CrawlerLastSeen
+	}
+	return c
+}
+
+// seriesSortColumns 允许排序的字段
+// crawler_last_seen 可能由驱动写入带时区的格式，统一转换后再比较
+var seriesSortColumns = map[string]string{
+	"name":              "series.name COLLATE NOCASE",
+	"updated_at":        "series.updated_at",
+	"created_at":        "series.created_at",
+	"crawler_last_seen": "datetime(series.crawler_last_seen)",
+}
+
+// IsValidSeriesSort 检查排序字段是否受支持
+func IsValidSeriesSort(sort string) bool {
+	_, ok := seriesSortColumns[sort]
+	return ok
+}
+
+// 获取所有剧集（不含回收站）
+func (d *Database) GetAllSeries() ([]Series, error) {
+	series, _, err := d.ListSeries(SeriesQuery{})
+	return series, err
+}
+
+// ListSeries 按条件查询剧集（不含回收站），同时返回符合条件的总数
+func (d *Database) ListSeries(q SeriesQuery) ([]Series, int, error) {
+	where := []string{"series.deleted_at IS NULL"}
+	var args []interface{}
+
+	if q.Tag != "" {
+		where = append(where, `EXISTS (
+			SELECT 1 FROM series_tags st JOIN tags t ON t.id = st.tag_id
+			WHERE st.series_id = series.id AND t.name = ?)`)
+		args = append(args, q.Tag)
+	}
+	if len(q.States) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(q.States)), ", ")
+		where = append(where, fmt.Sprintf(stateColumn+" IN (%s)", d.user(), placeholders))
+		for _, state := range q.States {
+			args = append(args, state)
 		}
+	}
+	if q.Watched != nil {
+		// 与 scanSeries 中 IsWatched 的定义保持一致
+		watched := `(EXISTS (SELECT 1 FROM episodes e WHERE e.series_id = series.id)
+			AND NOT EXISTS (SELECT 1 FROM episodes e WHERE e.series_id = series.id AND NOT EXISTS (
+				SELECT 1 FROM episode_watches w WHERE w.episode_id = e.id AND w.user_id = ?)))`
+		if !*q.Watched {
+			watched = "NOT " + watched
+		}
+		where = append(where, watched)
+		args = append(args, d.user())
+	}
+	if q.Q != "" {
+		where = append(where, "(instr(lower(series.name), lower(?)) > 0 OR instr(lower("+originalTitleColumn+"), lower(?)) > 0)")
+		args = append(args, q.Q, q.Q)
+	}
+	whereClause := strings.Join(where, " AND ")
 
-		if crawlerLastSeen.Valid {
-			s.CrawlerLastSeen = &crawlerLastSeen.Time
+	orderBy := "state IN (" + stateListSQL(TrackingStates) + ") DESC, series.updated_at DESC"
+	if q.Sort != "" {
+		column, ok := seriesSortColumns[q.Sort]
+		if !ok {
+			return nil, 0, fmt.Errorf("不支持的排序字段: %s", q.Sort)
 		}
+		direction := "ASC"
+		if q.Desc {
+			direction = "DESC"
+		}
+		orderBy = column + " " + direction + " NULLS LAST"
+	}
+	// 追加 id 保证分页顺序稳定
+	orderBy += ", series.id"
 
-		series = append(series, s)
+	var total int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM series WHERE "+whereClause, args...).Scan(&total); err != nil {
+		return nil, 0, err
 	}
 
-	return series, nil
+	if q.After != nil {
+		after, afterArgs := d.seriesAfter(q)
+		whereClause += " AND " + after
+		args = append(args, afterArgs...)
+	}
+	query := `
+		SELECT ` + d.seriesColumns() + `
+		FROM series
+		WHERE ` + whereClause + `
+		ORDER BY ` + orderBy
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	series, err := d.querySeries(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return series, total, nil
 }
 
-// 创建剧集
-func (d *Database) CreateSeries(name, url string) (*Series, error) {
-	historyJSON, _ := json.Marshal([]string{})
+// seriesAfter 排在游标之后的条件，与 ListSeries 的 ORDER BY 一致，相同排序值按 id 升序
+func (d *Database) seriesAfter(q SeriesQuery) (string, []interface{}) {
+	c := q.After
+	var t interface{}
+	if c.Time != nil {
+		t = sqliteTime(*c.Time)
+	}
+
+	switch q.Sort {
+	case "":
+		tracking := "(" + fmt.Sprintf(stateColumn, d.user()) + " IN (" + stateListSQL(TrackingStates) + "))"
+		return fmt.Sprintf(`(%[1]s < ? OR (%[1]s = ? AND (
+			series.updated_at < ? OR (series.updated_at = ? AND series.id > ?))))`, tracking),
+			[]interface{}{c.Tracking, c.Tracking, t, t, c.ID}
+	case "name":
+		column, op := seriesSortColumns[q.Sort], sortOperator(q.Desc)
+		return fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND series.id > ?))", column, op),
+			[]interface{}{c.Name, c.Name, c.ID}
+	}
+
+	// 时间字段的空值始终排在最后
+	column, op := seriesSortColumns[q.Sort], sortOperator(q.Desc)
+	if c.Time == nil {
+		return fmt.Sprintf("(%s IS NULL AND series.id > ?)", column), []interface{}{c.ID}
+	}
+	return fmt.Sprintf("(%[1]s %[2]s ? OR %[1]s IS NULL OR (%[1]s = ? AND series.id > ?))", column, op),
+		[]interface{}{t, t, c.ID}
+}
 
-	result, err := d.db.Exec(`
-		INSERT INTO series (name, url, history, current, is_watched, is_tracking)
-		VALUES (?, ?, ?, '', 0, 1)
-	`, name, url, historyJSON)
+// sortOperator 排在后面的值的比较运算符
+func sortOperator(desc bool) string {
+	if desc {
+		return "<"
+	}
+	return ">"
+}
+
+// 创建剧集，当前用户自动追踪，URL 保存为规范形式
+func (d *Database) CreateSeries(name, url string) (*Series, error) {
+	url = NormalizeSeriesURL(url)
+	tx, err := d.begin()
 	if err != nil {
 		return nil, err
 	}
 
-	id, err := result.LastInsertId()
+	result, err := tx.Exec(`
+		INSERT INTO series (name, url, current)
+		VALUES (?, ?, '')
+	`, name, url)
 	if err != nil {
+		tx.Rollback()
 		return nil, err
 	}
 
-	return d.GetSeriesByID(id)
-}
-
-// 根据ID获取剧集
-func (d *Database) GetSeriesByID(id int64) (*Series, error) {
-	var s Series
-	var historyJSON string
-	var crawlerLastSeen sql.NullTime
-	err := d.db.QueryRow(`
-		SELECT id, name, url, history, current, is_watched, is_tracking, created_at, updated_at, crawler_last_seen
-		FROM series WHERE id = ?
-	`, id).Scan(
-		&s.ID, &s.Name, &s.URL, &historyJSON, &s.Current,
-		&s.IsWatched, &s.IsTracking, &s.CreatedAt, &s.UpdatedAt,
-		&crawlerLastSeen,
-	)
+	id, err := result.LastInsertId()
 	if err != nil {
+		tx.Rollback()
 		return nil, err
 	}
 
-	if err := json.Unmarshal([]byte(historyJSON), &s.History); err != nil {
+	if _, err := tx.Exec(`
+		INSERT INTO subscriptions (user_id, series_id, state, state_changed_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+	`, d.user(), id, StateWatching); err != nil {
+		tx.Rollback()
 		return nil, err
 	}
 
-	if crawlerLastSeen.Valid {
-		s.CrawlerLastSeen = &crawlerLastSeen.Time
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
+	return d.GetSeriesByID(id)
+}
 
-	return &s, nil
+// 根据ID获取剧集（含回收站）
+func (d *Database) GetSeriesByID(id int64) (*Series, error) {
+	return d.querySingleSeries(`
+		SELECT `+d.seriesColumns()+`
+		FROM series WHERE id = ?
+	`, id)
 }
 
-// 更新剧集
+// 更新剧集，URL 保存为规范形式
 func (d *Database) UpdateSeries(id int64, name, url string) error {
+	url = NormalizeSeriesURL(url)
 	_, err := d.db.Exec(`
 		UPDATE series 
 		SET name = ?, url = ?
@@ -239,57 +539,74 @@ func (d *Database) UpdateSeries(id int64, name, url string) error {
 	return err
 }
 
-// 删除剧集
+// 删除剧集（移至回收站）
 func (d *Database) DeleteSeries(id int64) error {
-	_, err := d.db.Exec("DELETE FROM series WHERE id = ?", id)
-	return err
-}
-
-// 标记为已观看
-func (d *Database) MarkAsWatched(id int64) error {
 	_, err := d.db.Exec(`
 		UPDATE series 
-		SET is_watched = 1
-		WHERE id = ?
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND deleted_at IS NULL
 	`, id)
 	return err
 }
 
-// 标记为未观看
-func (d *Database) MarkAsUnwatched(id int64) error {
+// 标记为已观看（所有集数）
+func (d *Database) MarkAsWatched(id int64) error {
 	_, err := d.db.Exec(`
-		UPDATE series 
-		SET is_watched = 0
-		WHERE id = ?
-	`, id)
+		INSERT OR IGNORE INTO episode_watches (user_id, episode_id)
+		SELECT ?, id FROM episodes WHERE series_id = ?
+	`, d.user(), id)
 	return err
 }
 
-// 切换追踪状态
-func (d *Database) ToggleTracking(id int64) error {
+// 标记为未观看（最新一集）
+func (d *Database) MarkAsUnwatched(id int64) error {
 	_, err := d.db.Exec(`
-		UPDATE series 
-		SET is_tracking = CASE WHEN is_tracking = 1 THEN 0 ELSE 1 END
-		WHERE id = ?
-	`, id)
+		DELETE FROM episode_watches
+		WHERE user_id = ? AND episode_id = (
+			SELECT id FROM episodes WHERE series_id = ?
+			ORDER BY season DESC, number DESC, code DESC LIMIT 1
+		)
+	`, d.user(), id)
 	return err
 }
 
-// 更新剧集信息（爬虫回调使用）
-func (d *Database) UpdateSeriesInfo(url string, current string, series []string) error {
-	historyJSON, err := json.Marshal(series)
+// 更新剧集信息（爬虫回调使用），新集数追加到 episodes 表，状态文本解析为 SeriesStatus
+func (d *Database) UpdateSeriesInfo(url string, current string, episodes []FetchEpisode) error {
+	url = NormalizeSeriesURL(url)
+	tx, err := d.begin()
 	if err != nil {
 		return err
 	}
 
-	// 有新集数时，自动重置为未看状态，因为新集数还没看
-	_, err = d.db.Exec(`
+	var id int64
+	if err := tx.QueryRow("SELECT id FROM series WHERE url = ? AND deleted_at IS NULL", url).Scan(&id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 新集数插入时 watched_at 为空，观看进度自然变为未看
+	_, err = tx.Exec(`
 		UPDATE series 
-		SET current = ?, history = ?, is_watched = 0, updated_at = CURRENT_TIMESTAMP,
+		SET current = ?, updated_at = CURRENT_TIMESTAMP,
 			crawler_last_seen = CURRENT_TIMESTAMP
-		WHERE url = ?
-	`, current, historyJSON, url)
-	return err
+		WHERE id = ?
+	`, current, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := setSeriesStatus(tx, id, current); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := upsertEpisodes(tx, id, episodes, time.Now()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // 更新剧集爬虫最后更新时间
@@ -297,14 +614,22 @@ func (d *Database) UpdateSeriesCrawlerLastSeen(url string, lastSeen time.Time) e
 	_, err := d.db.Exec(`
 		UPDATE series 
 		SET crawler_last_seen = ?
-		WHERE url = ?
-	`, lastSeen, url)
+		WHERE url = ? AND deleted_at IS NULL
+	`, lastSeen, NormalizeSeriesURL(url))
 	return err
 }
 
-// 获取所有启用的剧集URL（爬虫任务使用）
-func (d *Database) GetAllTrackingURLs() ([]string, error) {
-	rows, err := d.db.Query("SELECT url FROM series WHERE is_tracking = 1")
+// 获取任一用户追踪的剧集URL（爬虫任务使用，不含回收站）
+// 已完结的剧集只在最后上报时间早于 recheckFinishedBefore 时返回，用于发现新季；零值表示不再抓取已完结的剧集
+func (d *Database) GetAllTrackingURLs(recheckFinishedBefore time.Time) ([]string, error) {
+	rows, err := d.db.Query(`
+		SELECT url FROM series
+		WHERE deleted_at IS NULL AND EXISTS (
+			SELECT 1 FROM subscriptions sub
+			WHERE sub.series_id = series.id AND sub.state IN (`+stateListSQL(TrackingStates)+`))
+		AND (finished_at IS NULL OR (? AND datetime(COALESCE(crawler_last_seen, finished_at)) < ?))
+		ORDER BY id
+	`, !recheckFinishedBefore.IsZero(), sqliteTime(recheckFinishedBefore))
 	if err != nil {
 		return nil, err
 	}
@@ -319,97 +644,36 @@ func (d *Database) GetAllTrackingURLs() ([]string, error) {
 		urls = append(urls, url)
 	}
 
-	return urls, nil
+	return urls, rows.Err()
 }
 
-// 根据URL获取剧集信息
+// 根据URL获取剧集信息（不含回收站），URL 按规范形式匹配
 func (d *Database) GetSeriesByURL(url string) (*Series, error) {
-	var s Series
-	var historyJSON string
-	var crawlerLastSeen sql.NullTime
-	err := d.db.QueryRow(`
-		SELECT id, name, url, history, current, is_watched, is_tracking, created_at, updated_at, crawler_last_seen
-		FROM series WHERE url = ?
-	`, url).Scan(
-		&s.ID, &s.Name, &s.URL, &historyJSON, &s.Current,
-		&s.IsWatched, &s.IsTracking, &s.CreatedAt, &s.UpdatedAt,
-		&crawlerLastSeen,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := json.Unmarshal([]byte(historyJSON), &s.History); err != nil {
-		return nil, err
-	}
-
-	if crawlerLastSeen.Valid {
-		s.CrawlerLastSeen = &crawlerLastSeen.Time
-	}
-
-	return &s, nil
+	return d.querySingleSeries(`
+		SELECT `+d.seriesColumns()+`
+		FROM series WHERE url = ? AND deleted_at IS NULL
+	`, NormalizeSeriesURL(url))
 }
 
 // 清空剧集历史和当前进度
 func (d *Database) ClearSeriesHistory(id int64) error {
-	emptyHistory, _ := json.Marshal([]string{})
-	_, err := d.db.Exec(`
-		UPDATE series 
-		SET history = ?, current = '', updated_at = CURRENT_TIMESTAMP
-		WHERE id = ?
-	`, emptyHistory, id)
-	return err
-}
-
-// GetSettings 获取全局配置
-func (d *Database) GetSettings() (*Settings, error) {
-	settings := &Settings{}
-	rows, err := d.db.Query("SELECT key, value FROM settings")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var key, value string
-		if err := rows.Scan(&key, &value); err != nil {
-			return nil, err
-		}
-		switch key {
-		case "crawler_start_time":
-			settings.CrawlerStartTime = value
-		case "crawler_end_time":
-			settings.CrawlerEndTime = value
-		case "slack_webhook_url":
-			settings.SlackWebhookURL = value
-		}
-	}
-	return settings, nil
-}
-
-// UpdateSettings 更新全局配置
-func (d *Database) UpdateSettings(settings *Settings) error {
-	tx, err := d.db.Begin()
+	tx, err := d.begin()
 	if err != nil {
 		return err
 	}
 
-	stmt, err := tx.Prepare("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)")
-	if err != nil {
+	if _, err := tx.Exec("DELETE FROM episodes WHERE series_id = ?", id); err != nil {
 		tx.Rollback()
 		return err
 	}
-	defer stmt.Close()
 
-	if _, err := stmt.Exec("crawler_start_time", settings.CrawlerStartTime); err != nil {
-		tx.Rollback()
-		return err
-	}
-	if _, err := stmt.Exec("crawler_end_time", settings.CrawlerEndTime); err != nil {
-		tx.Rollback()
-		return err
-	}
-	if _, err := stmt.Exec("slack_webhook_url", settings.SlackWebhookURL); err != nil {
+	_, err = tx.Exec(`
+		UPDATE series 
+		SET current = '', updated_at = CURRENT_TIMESTAMP,
+			latest_episode = '', total_episodes = 0, finished_at = NULL
+		WHERE id = ?
+	`, id)
+	if err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -0,0 +1,200 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Episode 单集信息
+type Episode struct {
+	ID          int64      `json:"id"`
+	SeriesID    int64      `json:"series_id"`
+	Code        string     `json:"code"`   // "S01E01"
+	Season      int        `json:"season"` // 无法解析时为 0
+	Number      int        `json:"number"` // 无法解析时为 0
+	Title       string     `json:"title"`  // 页面上的原始标题
+	FirstSeenAt time.Time  `json:"first_seen_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	WatchedAt   *time.Time `json:"watched_at"` // 当前用户未看时为空
+}
+
+var episodeCodeRegex = regexp.MustCompile(`^S(\d+)E(\d+)$`)
+
+// ParseEpisodeCode 解析 "S01E02" 形式的集数编号
+func ParseEpisodeCode(code string) (season, number int, ok bool) {
+	matches := episodeCodeRegex.FindStringSubmatch(code)
+	if matches == nil {
+		return 0, 0, false
+	}
+	season, _ = strconv.Atoi(matches[1])
+	number, _ = strconv.Atoi(matches[2])
+	return season, number, true
+}
+
+// episodeOrder 集数排序规则，与原先爬虫对编号字符串排序的结果一致
+const episodeOrder = `season, number, code`
+
+// GetEpisodes 获取剧集的所有集数
+func (d *Database) GetEpisodes(seriesID int64) ([]Episode, error) {
+	rows, err := d.db.Query(`
+		SELECT e.id, e.series_id, e.code, e.season, e.number, e.title, e.first_seen_at, e.last_seen_at, w.watched_at
+		FROM episodes e
+		LEFT JOIN episode_watches w ON w.episode_id = e.id AND w.user_id = ?
+		WHERE e.series_id = ?
+		ORDER BY `+episodeOrder, d.user(), seriesID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	episodes := []Episode{}
+	for rows.Next() {
+		var e Episode
+		var watchedAt sql.NullTime
+		if err := rows.Scan(
+			&e.ID, &e.SeriesID, &e.Code, &e.Season, &e.Number, &e.Title,
+			&e.FirstSeenAt, &e.LastSeenAt, &watchedAt,
+		); err != nil {
+			return nil, err
+		}
+		if watchedAt.Valid {
+			e.WatchedAt = &watchedAt.Time
+		}
+		episodes = append(episodes, e)
+	}
+	return episodes, rows.Err()
+}
+
+// MarkWatchedUntil 将观看进度设置到指定集数：之前（含）的集数标记为已看，之后的标记为未看
+// 剧集没有该集时返回 sql.ErrNoRows
+func (d *Database) MarkWatchedUntil(seriesID int64, code string) error {
+	season, number, ok := ParseEpisodeCode(code)
+	if !ok {
+		return fmt.Errorf("无效的集数编号: %s", code)
+	}
+
+	tx, err := d.begin()
+	if err != nil {
+		return err
+	}
+
+	var episodeID int64
+	err = tx.QueryRow(`
+		SELECT id FROM episodes WHERE series_id = ? AND season = ? AND number = ? LIMIT 1
+	`, seriesID, season, number).Scan(&episodeID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT OR IGNORE INTO episode_watches (user_id, episode_id)
+		SELECT ?, id FROM episodes
+		WHERE series_id = ? AND (season, number) <= (?, ?)
+	`, d.user(), seriesID, season, number)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM episode_watches
+		WHERE user_id = ? AND episode_id IN (
+			SELECT id FROM episodes WHERE series_id = ? AND (season, number) > (?, ?)
+		)
+	`, d.user(), seriesID, season, number)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetEpisodeWatched 设置单集的观看状态，集数不存在时返回 sql.ErrNoRows
+func (d *Database) SetEpisodeWatched(seriesID int64, code string, watched bool) error {
+	var episodeID int64
+	err := d.db.QueryRow("SELECT id FROM episodes WHERE series_id = ? AND code = ?", seriesID, code).Scan(&episodeID)
+	if err != nil {
+		return err
+	}
+
+	query := "DELETE FROM episode_watches WHERE user_id = ? AND episode_id = ?"
+	if watched {
+		query = "INSERT OR IGNORE INTO episode_watches (user_id, episode_id) VALUES (?, ?)"
+	}
+	_, err = d.db.Exec(query, d.user(), episodeID)
+	return err
+}
+
+// TouchEpisodes 记录爬虫再次看到的集数，新集数会被追加
+func (d *Database) TouchEpisodes(seriesID int64, episodes []FetchEpisode, seenAt time.Time) error {
+	tx, err := d.begin()
+	if err != nil {
+		return err
+	}
+
+	if err := upsertEpisodes(tx, seriesID, episodes, seenAt); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// upsertEpisodes 插入新集数并刷新已有集数的最后出现时间，不会删除已记录的集数
+func upsertEpisodes(tx conn, seriesID int64, episodes []FetchEpisode, seenAt time.Time) error {
+	if len(episodes) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO episodes (series_id, code, season, number, title, first_seen_at, last_seen_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (series_id, code) DO UPDATE SET
+			last_seen_at = excluded.last_seen_at,
+			title = CASE WHEN excluded.title != '' THEN excluded.title ELSE episodes.title END
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	// 统一以 UTC 存储，便于按时间范围比较
+	seenAt = seenAt.UTC()
+	for _, ep := range episodes {
+		season, number, _ := ParseEpisodeCode(ep.Code)
+		if _, err := stmt.Exec(seriesID, ep.Code, season, number, ep.Title, seenAt, seenAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillHistory 一次查询填充多个剧集的历史集数
+func (d *Database) fillHistory(series []Series, index map[int64]*Series) error {
+	placeholders, args := seriesIDArgs(series)
+	rows, err := d.db.Query(`
+		SELECT series_id, code FROM episodes
+		WHERE series_id IN (`+placeholders+`)
+		ORDER BY series_id, `+episodeOrder, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var seriesID int64
+		var code string
+		if err := rows.Scan(&seriesID, &code); err != nil {
+			return err
+		}
+		if s, ok := index[seriesID]; ok {
+			s.History = append(s.History, code)
+		}
+	}
+	return rows.Err()
+}
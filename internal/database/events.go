@@ -0,0 +1,135 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// 剧集事件类型
+const (
+	EventNewEpisodes     = "new_episodes"
+	EventStatusChanged   = "status_changed"
+	EventFinished        = "finished"
+	EventWatched         = "watched"
+	EventUnwatched       = "unwatched"
+	EventTrackingToggled = "tracking_toggled" // 旧版本的追踪开关，仅存在于历史事件中
+	EventStateChanged    = "state_changed"
+	EventHistoryCleared  = "history_cleared"
+	EventRenamed         = "renamed"
+	EventDeleted         = "deleted"
+	EventRestored        = "restored"
+)
+
+// 事件来源
+const (
+	EventSourceCrawler = "crawler"
+	EventSourceUser    = "user"
+)
+
+// SeriesEvent 剧集变更事件，只追加不修改
+type SeriesEvent struct {
+	ID         int64           `json:"id"`
+	SeriesID   *int64          `json:"series_id"`   // 剧集被删除后为空
+	SeriesName string          `json:"series_name"` // 事件发生时的剧集名称
+	Type       string          `json:"type"`
+	Source     string          `json:"source"`
+	UserID     *int64          `json:"user_id"` // 操作的用户，爬虫和系统事件为空
+	Detail     json.RawMessage `json:"detail"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// sqliteTime 转换为与 CURRENT_TIMESTAMP 一致的 UTC 文本，用于时间范围比较
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// eventUserID 用户来源的事件记录当前用户，其他来源不属于任何用户
+func eventUserID(source string, userID int64) *int64 {
+	if source != EventSourceUser {
+		return nil
+	}
+	return &userID
+}
+
+// CreateSeriesEvent 记录剧集事件，detail 会被序列化为 JSON
+func (d *Database) CreateSeriesEvent(seriesID int64, eventType, source string, detail interface{}) error {
+	if detail == nil {
+		detail = map[string]interface{}{}
+	}
+	detailJSON, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.Exec(`
+		INSERT INTO series_events (series_id, series_name, type, source, user_id, detail)
+		VALUES (?, COALESCE((SELECT name FROM series WHERE id = ?), ''), ?, ?, ?, ?)
+	`, seriesID, seriesID, eventType, source, eventUserID(source, d.user()), string(detailJSON))
+	return err
+}
+
+// seriesEventColumns 事件查询字段，顺序需与 scanSeriesEvent 一致
+const seriesEventColumns = `id, series_id, series_name, type, source, user_id, detail, created_at`
+
+// scanSeriesEvent 扫描一行事件
+func scanSeriesEvent(row rowScanner) (*SeriesEvent, error) {
+	var e SeriesEvent
+	var seriesID, userID sql.NullInt64
+	var detail string
+	err := row.Scan(&e.ID, &seriesID, &e.SeriesName, &e.Type, &e.Source, &userID, &detail, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if seriesID.Valid {
+		e.SeriesID = &seriesID.Int64
+	}
+	if userID.Valid {
+		e.UserID = &userID.Int64
+	}
+	e.Detail = json.RawMessage(detail)
+
+	return &e, nil
+}
+
+// querySeriesEvents 执行事件查询
+func (d *Database) querySeriesEvents(query string, args ...interface{}) ([]SeriesEvent, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []SeriesEvent{}
+	for rows.Next() {
+		e, err := scanSeriesEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+	return events, rows.Err()
+}
+
+// GetSeriesEvents 获取剧集的事件时间线，按时间倒序，只包含当前用户和不属于任何用户的事件
+func (d *Database) GetSeriesEvents(seriesID int64, limit int) ([]SeriesEvent, error) {
+	return d.querySeriesEvents(`
+		SELECT `+seriesEventColumns+`
+		FROM series_events
+		WHERE series_id = ? AND (user_id IS NULL OR user_id = ?)
+		ORDER BY id DESC
+		LIMIT ?
+	`, seriesID, d.user(), limit)
+}
+
+// GetEvents 获取指定时间之后的所有事件，按时间倒序，只包含当前用户和不属于任何用户的事件
+func (d *Database) GetEvents(since time.Time, limit int) ([]SeriesEvent, error) {
+	return d.querySeriesEvents(`
+		SELECT `+seriesEventColumns+`
+		FROM series_events
+		WHERE created_at >= ? AND (user_id IS NULL OR user_id = ?)
+		ORDER BY id DESC
+		LIMIT ?
+	`, sqliteTime(since), d.user(), limit)
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SeriesRecord 导出、导入使用的剧集完整记录
+type SeriesRecord struct {
+	Name            string          `json:"name"`
+	URL             string          `json:"url"`
+	Current         string          `json:"current"`
+	State           *string         `json:"state"`                 // 当前用户的剧集状态，空值表示不在片单中，nil 表示保持不变
+	LegacyTracking  *bool           `json:"is_tracking,omitempty"` // 旧版本导出文件的追踪开关，读取时转换为 State
+	Tags            []string        `json:"tags"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	CrawlerLastSeen *time.Time      `json:"crawler_last_seen"`
+	Episodes        []EpisodeRecord `json:"episodes"`
+
+	SeriesMetadata        // 导出时为实际显示的值（用户填写的优先）
+	Notes          string `json:"notes,omitempty"`  // 当前用户的备注
+	Rating         int    `json:"rating,omitempty"` // 当前用户的评分，0 表示未评分
+}
+
+// EpisodeRecord 导出、导入使用的单集记录
+type EpisodeRecord struct {
+	Code        string     `json:"code"`
+	Title       string     `json:"title,omitempty"`
+	FirstSeenAt time.Time  `json:"first_seen_at"`
+	WatchedAt   *time.Time `json:"watched_at"` // 未看时为空
+}
+
+// timeOrNow 零值时间使用当前时间
+func timeOrNow(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
+
+// ImportSeries 按 URL 合并导入剧集（不含回收站），返回剧集 ID
+//
+// 不存在时按记录创建并保留原有时间；已存在时更新名称，剧集状态和当前进度仅在记录提供时覆盖。
+// 已有数据只增不减：集数只追加，观看状态只会标记为已看，标签只会添加，
+// 元数据、备注和评分只补充为空的字段，用户已填写或爬虫已发现的值不会被覆盖。
+func (d *Database) ImportSeries(record SeriesRecord) (int64, error) {
+	record.URL = NormalizeSeriesURL(record.URL)
+	tx, err := d.begin()
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = tx.QueryRow("SELECT id FROM series WHERE url = ? AND deleted_at IS NULL", record.URL).Scan(&id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		var crawlerLastSeen interface{}
+		if record.CrawlerLastSeen != nil {
+			crawlerLastSeen = record.CrawlerLastSeen.UTC()
+		}
+		meta := record.SeriesMetadata
+		result, err := tx.Exec(`
+			INSERT INTO series (name, url, current, created_at, updated_at, crawler_last_seen,
+				poster_url, original_title, year, douban_id, imdb_id, tmdb_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, record.Name, record.URL, record.Current,
+			sqliteTime(timeOrNow(record.CreatedAt)), sqliteTime(timeOrNow(record.UpdatedAt)), crawlerLastSeen,
+			meta.PosterURL, meta.OriginalTitle, meta.Year, meta.DoubanID, meta.IMDbID, meta.TMDBID)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	case err != nil:
+		tx.Rollback()
+		return 0, err
+	default:
+		meta := record.SeriesMetadata
+		_, err := tx.Exec(`
+			UPDATE series
+			SET name = ?,
+				current = CASE WHEN ? != '' THEN ? ELSE current END,
+				poster_url = CASE WHEN poster_url = '' AND crawler_poster_url = '' THEN ? ELSE poster_url END,
+				original_title = CASE WHEN original_title = '' AND crawler_original_title = '' THEN ? ELSE original_title END,
+				year = CASE WHEN year = 0 THEN ? ELSE year END,
+				douban_id = CASE WHEN douban_id = '' THEN ? ELSE douban_id END,
+				imdb_id = CASE WHEN imdb_id = '' THEN ? ELSE imdb_id END,
+				tmdb_id = CASE WHEN tmdb_id = '' THEN ? ELSE tmdb_id END
+			WHERE id = ?
+		`, record.Name, record.Current, record.Current,
+			meta.PosterURL, meta.OriginalTitle, meta.Year, meta.DoubanID, meta.IMDbID, meta.TMDBID, id)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	switch {
+	case record.State == nil:
+	case *record.State == "":
+		_, err = tx.Exec("DELETE FROM subscriptions WHERE user_id = ? AND series_id = ?", d.user(), id)
+	default:
+		_, err = tx.Exec(`
+			INSERT INTO subscriptions (user_id, series_id, state, state_changed_at)
+			VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+			ON CONFLICT (user_id, series_id) DO UPDATE SET
+				state = excluded.state,
+				state_changed_at = CASE WHEN subscriptions.state != excluded.state
+					THEN CURRENT_TIMESTAMP ELSE subscriptions.state_changed_at END
+		`, d.user(), id, *record.State)
+	}
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if record.Notes != "" || record.Rating != 0 {
+		_, err := tx.Exec(`
+			INSERT INTO series_notes (user_id, series_id, notes, rating, updated_at)
+			VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+			ON CONFLICT (user_id, series_id) DO UPDATE SET
+				notes = CASE WHEN series_notes.notes = '' THEN excluded.notes ELSE series_notes.notes END,
+				rating = CASE WHEN series_notes.rating = 0 THEN excluded.rating ELSE series_notes.rating END,
+				updated_at = excluded.updated_at
+		`, d.user(), id, record.Notes, record.Rating)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if record.Current != "" {
+		if err := setSeriesStatus(tx, id, record.Current); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if err := importEpisodes(tx, d.user(), id, record.Episodes); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := addSeriesTags(tx, id, record.Tags); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// importEpisodes 追加导入的集数，已有集数只补充标题和用户的已看状态
+func importEpisodes(tx conn, userID, seriesID int64, episodes []EpisodeRecord) error {
+	if len(episodes) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO episodes (series_id, code, season, number, title, first_seen_at, last_seen_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (series_id, code) DO UPDATE SET
+			title = CASE WHEN episodes.title = '' THEN excluded.title ELSE episodes.title END
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	watchStmt, err := tx.Prepare(`
+		INSERT OR IGNORE INTO episode_watches (user_id, episode_id, watched_at)
+		SELECT ?, id, ? FROM episodes WHERE series_id = ? AND code = ?
+	`)
+	if err != nil {
+		return err
+	}
+	defer watchStmt.Close()
+
+	for _, ep := range episodes {
+		season, number, _ := ParseEpisodeCode(ep.Code)
+		seenAt := timeOrNow(ep.FirstSeenAt).UTC()
+		if _, err := stmt.Exec(seriesID, ep.Code, season, number, ep.Title, seenAt, seenAt); err != nil {
+			return err
+		}
+		if ep.WatchedAt != nil {
+			if _, err := watchStmt.Exec(userID, sqliteTime(*ep.WatchedAt), seriesID, ep.Code); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
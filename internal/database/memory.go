@@ -0,0 +1,1943 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MemoryStore 纯内存的 Store 实现，语义与 SQLite 实现保持一致，用于测试
+// 单个方法调用是并发安全的，但事务没有隔离，只适用于单写入者，见 Transaction
+type MemoryStore struct {
+	*memoryData
+	userID int64 // 追踪订阅和观看状态所属的用户，0 表示管理员，见 ForUser
+}
+
+// memoryData 所有用户共享的数据
+type memoryData struct {
+	mu sync.Mutex
+	memoryTables
+}
+
+// memoryTables 存储的全部数据，Transaction 失败时整体恢复
+type memoryTables struct {
+	series        map[int64]*Series              // 只保存剧集自身字段和用户填写的元数据，追踪状态、观看进度、历史、标签和备注在读取时生成
+	crawlerMeta   map[int64]SeriesMetadata       // 爬虫发现的海报和原名
+	notes         map[int64]map[int64]seriesNote // series_id -> user_id
+	episodes      map[int64][]*Episode           // 不含观看状态
+	watches       map[int64]map[int64]time.Time  // episode_id -> user_id -> watched_at
+	subscriptions map[int64]map[int64]string     // series_id -> user_id -> state
+	users         map[int64]*User
+	sessions      map[int64]*Session
+	apiKeys       map[int64]*APIKey
+	tags          map[int64]*Tag
+	seriesTags    map[int64]map[int64]bool // series_id -> tag_id
+	events        []SeriesEvent
+	crawlRuns     []CrawlRun
+	settings      map[string]string
+	changes       []SettingChange
+
+	lastSeriesID   int64
+	lastEpisodeID  int64
+	lastUserID     int64
+	lastSessionID  int64
+	lastAPIKeyID   int64
+	lastTagID      int64
+	lastEventID    int64
+	lastCrawlRunID int64
+	lastChangeID   int64
+}
+
+// NewMemoryStore 创建空的内存存储，与迁移后的数据库一样只包含管理员用户
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{memoryData: &memoryData{memoryTables: memoryTables{
+		series:        make(map[int64]*Series),
+		crawlerMeta:   make(map[int64]SeriesMetadata),
+		notes:         make(map[int64]map[int64]seriesNote),
+		episodes:      make(map[int64][]*Episode),
+		watches:       make(map[int64]map[int64]time.Time),
+		subscriptions: make(map[int64]map[int64]string),
+		users: map[int64]*User{
+			AdminUserID: {ID: AdminUserID, Username: "admin", IsAdmin: true, CreatedAt: memoryNow()},
+		},
+		sessions:   make(map[int64]*Session),
+		apiKeys:    make(map[int64]*APIKey),
+		tags:       make(map[int64]*Tag),
+		seriesTags: make(map[int64]map[int64]bool),
+		settings:   make(map[string]string),
+		lastUserID: AdminUserID,
+	}}}
+}
+
+// clone 深复制所有数据，记录只在修改时整体替换的字段（如时间指针、事件详情）共用
+func (t *memoryTables) clone() memoryTables {
+	c := *t
+	c.series = make(map[int64]*Series, len(t.series))
+	for id, s := range t.series {
+		copied := *s
+		c.series[id] = &copied
+	}
+	c.crawlerMeta = make(map[int64]SeriesMetadata, len(t.crawlerMeta))
+	for id, meta := range t.crawlerMeta {
+		c.crawlerMeta[id] = meta
+	}
+	c.notes = make(map[int64]map[int64]seriesNote, len(t.notes))
+	for id, notes := range t.notes {
+		c.notes[id] = make(map[int64]seriesNote, len(notes))
+		for userID, note := range notes {
+			c.notes[id][userID] = note
+		}
+	}
+	c.episodes = make(map[int64][]*Episode, len(t.episodes))
+	for id, episodes := range t.episodes {
+		for _, e := range episodes {
+			copied := *e
+			c.episodes[id] = append(c.episodes[id], &copied)
+		}
+	}
+	c.watches = make(map[int64]map[int64]time.Time, len(t.watches))
+	for id, watches := range t.watches {
+		c.watches[id] = make(map[int64]time.Time, len(watches))
+		for userID, watchedAt := range watches {
+			c.watches[id][userID] = watchedAt
+		}
+	}
+	c.subscriptions = make(map[int64]map[int64]string, len(t.subscriptions))
+	for id, states := range t.subscriptions {
+		c.subscriptions[id] = make(map[int64]string, len(states))
+		for userID, state := range states {
+			c.subscriptions[id][userID] = state
+		}
+	}
+	c.users = make(map[int64]*User, len(t.users))
+	for id, u := range t.users {
+		copied := *u
+		c.users[id] = &copied
+	}
+	c.sessions = make(map[int64]*Session, len(t.sessions))
+	for id, session := range t.sessions {
+		copied := *session
+		c.sessions[id] = &copied
+	}
+	c.apiKeys = make(map[int64]*APIKey, len(t.apiKeys))
+	for id, key := range t.apiKeys {
+		copied := *key
+		c.apiKeys[id] = &copied
+	}
+	c.tags = make(map[int64]*Tag, len(t.tags))
+	for id, tag := range t.tags {
+		copied := *tag
+		c.tags[id] = &copied
+	}
+	c.seriesTags = make(map[int64]map[int64]bool, len(t.seriesTags))
+	for id, tags := range t.seriesTags {
+		c.seriesTags[id] = make(map[int64]bool, len(tags))
+		for tagID := range tags {
+			c.seriesTags[id][tagID] = true
+		}
+	}
+	c.events = append([]SeriesEvent(nil), t.events...)
+	c.crawlRuns = append([]CrawlRun(nil), t.crawlRuns...)
+	c.settings = make(map[string]string, len(t.settings))
+	for key, value := range t.settings {
+		c.settings[key] = value
+	}
+	c.changes = append([]SettingChange(nil), t.changes...)
+	return c
+}
+
+// Transaction 内存实现在 fn 返回错误时恢复到调用前的快照
+// 事务期间不持有锁，其他调用方能看到未提交的修改，回滚时其他调用方的修改也会一并撤销，
+// 因此只适用于单写入者：事务执行期间不能有其他写入
+func (m *MemoryStore) Transaction(fn func(tx Store) error) error {
+	m.mu.Lock()
+	snapshot := m.memoryTables.clone()
+	m.mu.Unlock()
+
+	if err := fn(m); err != nil {
+		m.mu.Lock()
+		m.memoryTables = snapshot
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// seriesNote 用户的备注和评分
+type seriesNote struct {
+	notes  string
+	rating int
+}
+
+func (m *MemoryStore) ForUser(userID int64) Store {
+	return &MemoryStore{memoryData: m.memoryData, userID: userID}
+}
+
+// user 当前操作的用户
+func (m *MemoryStore) user() int64 {
+	if m.userID == 0 {
+		return AdminUserID
+	}
+	return m.userID
+}
+
+// watchedAt 当前用户观看该集的时间，未看时为 nil，调用方需持有锁
+func (m *MemoryStore) watchedAt(e *Episode) *time.Time {
+	if t, ok := m.watches[e.ID][m.user()]; ok {
+		return &t
+	}
+	return nil
+}
+
+// setWatched 设置当前用户的观看状态，watchedAt 为 nil 时标记为未看，已看的集数保留原有时间
+func (m *MemoryStore) setWatched(e *Episode, watchedAt *time.Time) {
+	_, watched := m.watches[e.ID][m.user()]
+	if watchedAt == nil {
+		if watched {
+			delete(m.watches[e.ID], m.user())
+			m.touch(e.SeriesID)
+		}
+		return
+	}
+	if m.watches[e.ID] == nil {
+		m.watches[e.ID] = make(map[int64]time.Time)
+	}
+	if !watched {
+		m.watches[e.ID][m.user()] = *watchedAt
+		m.touch(e.SeriesID)
+	}
+}
+
+// setState 设置当前用户的剧集状态，空值移出片单
+func (m *MemoryStore) setState(seriesID int64, state string) {
+	if state == "" {
+		if _, ok := m.subscriptions[seriesID][m.user()]; ok {
+			delete(m.subscriptions[seriesID], m.user())
+			m.touch(seriesID)
+		}
+		return
+	}
+	if m.subscriptions[seriesID] == nil {
+		m.subscriptions[seriesID] = make(map[int64]string)
+	}
+	m.subscriptions[seriesID][m.user()] = state
+	m.touch(seriesID)
+}
+
+// isTracked 是否有用户正在追踪该剧集
+func (m *MemoryStore) isTracked(seriesID int64) bool {
+	for _, state := range m.subscriptions[seriesID] {
+		if IsTrackingState(state) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// memoryNow 与 CURRENT_TIMESTAMP 一致：UTC，精确到秒
+func memoryNow() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// nocaseFold 与 SQLite 的 NOCASE 排序规则和 lower() 一致，只转换 ASCII 字母
+func nocaseFold(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+// limitCount 与 SQLite 的 LIMIT 一致，负数表示不限制
+func limitCount(n, limit int) int {
+	if limit < 0 || limit > n {
+		return n
+	}
+	return limit
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+func copyID(id *int64) *int64 {
+	if id == nil {
+		return nil
+	}
+	c := *id
+	return &c
+}
+
+// view 生成对外返回的剧集副本，派生字段与 seriesColumns 的定义一致
+func (m *MemoryStore) view(s *Series) Series {
+	v := *s
+	v.CrawlerLastSeen = copyTime(s.CrawlerLastSeen)
+	v.DeletedAt = copyTime(s.DeletedAt)
+	v.FinishedAt = copyTime(s.FinishedAt)
+	v.State = m.subscriptions[s.ID][m.user()]
+	v.History = []string{}
+	v.UnwatchedCount = 0
+	v.NextUnwatched = ""
+
+	episodes := m.episodes[s.ID]
+	for _, e := range episodes {
+		v.History = append(v.History, e.Code)
+		if m.watchedAt(e) == nil {
+			if v.UnwatchedCount == 0 {
+				v.NextUnwatched = e.Code
+			}
+			v.UnwatchedCount++
+		}
+	}
+	v.IsWatched = len(episodes) > 0 && v.UnwatchedCount == 0
+
+	// 与 posterURLColumn、originalTitleColumn 一致，用户填写的值优先
+	crawler := m.crawlerMeta[s.ID]
+	if v.PosterURL == "" {
+		v.PosterURL = crawler.PosterURL
+	}
+	if v.OriginalTitle == "" {
+		v.OriginalTitle = crawler.OriginalTitle
+	}
+	note := m.notes[s.ID][m.user()]
+	v.Notes = note.notes
+	v.Rating = note.rating
+
+	v.Tags = []string{}
+	for tagID := range m.seriesTags[s.ID] {
+		v.Tags = append(v.Tags, m.tags[tagID].Name)
+	}
+	sort.Slice(v.Tags, func(i, j int) bool {
+		return nocaseFold(v.Tags[i]) < nocaseFold(v.Tags[j])
+	})
+
+	return v
+}
+
+// setStatus 与 setSeriesStatus 一致，已完结时保留首次完结的时间
+func setStatus(s *Series, current string, now time.Time) {
+	s.SeriesStatus = ParseStatus(current)
+	if !s.Finished {
+		s.FinishedAt = nil
+	} else if s.FinishedAt == nil {
+		s.FinishedAt = &now
+	}
+}
+
+// touch 递增剧集的行版本，与 series_version_update 等触发器一致，调用方需持有锁
+func (m *MemoryStore) touch(seriesID int64) {
+	if s, ok := m.series[seriesID]; ok {
+		s.Version++
+	}
+}
+
+// touchEpisode 递增集数所属剧集的行版本，调用方需持有锁
+func (m *MemoryStore) touchEpisode(episodeID int64) {
+	for seriesID, episodes := range m.episodes {
+		for _, e := range episodes {
+			if e.ID == episodeID {
+				m.touch(seriesID)
+				return
+			}
+		}
+	}
+}
+
+// findSeriesByURL 按规范形式的 URL 查找剧集，includeDeleted 为 false 时忽略回收站
+func (m *MemoryStore) findSeriesByURL(url string, includeDeleted bool) *Series {
+	url = NormalizeSeriesURL(url)
+	for _, s := range m.series {
+		if s.URL == url && (includeDeleted || s.DeletedAt == nil) {
+			return s
+		}
+	}
+	return nil
+}
+
+// sortedSeries 按 ID 顺序返回满足条件的剧集
+func (m *MemoryStore) sortedSeries(match func(s *Series) bool) []*Series {
+	var series []*Series
+	for _, s := range m.series {
+		if match(s) {
+			series = append(series, s)
+		}
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].ID < series[j].ID })
+	return series
+}
+
+func (m *MemoryStore) CreateSeries(name, url string) (*Series, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 与 url UNIQUE 约束一致，回收站中的剧集同样占用 URL
+	if m.findSeriesByURL(url, true) != nil {
+		return nil, fmt.Errorf("series.url: %w", ErrUniqueViolation)
+	}
+
+	m.lastSeriesID++
+	now := memoryNow()
+	s := &Series{
+		ID:        m.lastSeriesID,
+		Name:      name,
+		URL:       NormalizeSeriesURL(url),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Version:   1,
+	}
+	m.series[s.ID] = s
+	m.setState(s.ID, StateWatching)
+
+	v := m.view(s)
+	return &v, nil
+}
+
+func (m *MemoryStore) GetSeriesByID(id int64) (*Series, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.series[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	v := m.view(s)
+	return &v, nil
+}
+
+func (m *MemoryStore) GetSeriesByURL(url string) (*Series, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.findSeriesByURL(url, false)
+	if s == nil {
+		return nil, sql.ErrNoRows
+	}
+	v := m.view(s)
+	return &v, nil
+}
+
+func (m *MemoryStore) GetAllSeries() ([]Series, error) {
+	series, _, err := m.ListSeries(SeriesQuery{})
+	return series, err
+}
+
+func (m *MemoryStore) ListSeries(q SeriesQuery) ([]Series, int, error) {
+	if q.Sort != "" && !IsValidSeriesSort(q.Sort) {
+		return nil, 0, fmt.Errorf("不支持的排序字段: %s", q.Sort)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tag := nocaseFold(q.Tag)
+	name := nocaseFold(q.Q)
+	series := []Series{}
+	for _, s := range m.series {
+		if s.DeletedAt != nil {
+			continue
+		}
+		v := m.view(s)
+		if q.Tag != "" && !containsFold(v.Tags, tag) {
+			continue
+		}
+		if len(q.States) > 0 && !containsState(q.States, v.State) {
+			continue
+		}
+		if q.Watched != nil && v.IsWatched != *q.Watched {
+			continue
+		}
+		if q.Q != "" && !strings.Contains(nocaseFold(v.Name), name) && !strings.Contains(nocaseFold(v.OriginalTitle), name) {
+			continue
+		}
+		series = append(series, v)
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		if c := compareSeries(&series[i], &series[j], q.Sort, q.Desc); c != 0 {
+			return c < 0
+		}
+		// 追加 id 保证分页顺序稳定
+		return series[i].ID < series[j].ID
+	})
+
+	total := len(series)
+	if q.After != nil {
+		after := cursorSeries(q.After)
+		start := sort.Search(len(series), func(i int) bool {
+			c := compareSeries(&series[i], &after, q.Sort, q.Desc)
+			return c > 0 || c == 0 && series[i].ID > after.ID
+		})
+		series = series[start:]
+	}
+	if q.Limit > 0 {
+		series = series[:limitCount(len(series), q.Limit)]
+	}
+
+	return series, total, nil
+}
+
+// cursorSeries 由游标构造用于 compareSeries 比较的剧集
+func cursorSeries(c *SeriesCursor) Series {
+	s := Series{ID: c.ID, Name: c.Name, CrawlerLastSeen: c.Time}
+	if c.Tracking {
+		s.State = TrackingStates[0]
+	}
+	if c.Time != nil {
+		s.UpdatedAt = *c.Time
+		s.CreatedAt = *c.Time
+	}
+	return s
+}
+
+// containsFold 判断标签列表中是否包含指定标签（已折叠大小写）
+func containsFold(tags []string, tag string) bool {
+	for _, t := range tags {
+		if nocaseFold(t) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// compareSeries 与 ListSeries 的 ORDER BY 一致，不含最后的 id
+func compareSeries(a, b *Series, sortKey string, desc bool) int {
+	if sortKey == "" {
+		if IsTrackingState(a.State) != IsTrackingState(b.State) {
+			if IsTrackingState(a.State) {
+				return -1
+			}
+			return 1
+		}
+		return -a.UpdatedAt.Compare(b.UpdatedAt)
+	}
+
+	var c int
+	switch sortKey {
+	case "name":
+		c = strings.Compare(nocaseFold(a.Name), nocaseFold(b.Name))
+	case "updated_at":
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	case "created_at":
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	case "crawler_last_seen":
+		// NULLS LAST 与排序方向无关
+		switch {
+		case a.CrawlerLastSeen == nil && b.CrawlerLastSeen == nil:
+			return 0
+		case a.CrawlerLastSeen == nil:
+			return 1
+		case b.CrawlerLastSeen == nil:
+			return -1
+		}
+		c = a.CrawlerLastSeen.Compare(*b.CrawlerLastSeen)
+	}
+	if desc {
+		c = -c
+	}
+	return c
+}
+
+func (m *MemoryStore) UpdateSeries(id int64, name, url string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.series[id]
+	if !ok {
+		return nil
+	}
+	if other := m.findSeriesByURL(url, true); other != nil && other.ID != id {
+		return fmt.Errorf("series.url: %w", ErrUniqueViolation)
+	}
+	s.Name = name
+	s.URL = NormalizeSeriesURL(url)
+	s.Version++
+	return nil
+}
+
+func (m *MemoryStore) UpdateSeriesMetadata(id int64, patch SeriesMetadataPatch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.series[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	patch.Apply(&s.SeriesMetadata)
+	s.Version++
+	return nil
+}
+
+func (m *MemoryStore) UpdateSeriesNotes(id int64, notes string, rating int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.series[id]; !ok {
+		return sql.ErrNoRows
+	}
+	if m.notes[id] == nil {
+		m.notes[id] = make(map[int64]seriesNote)
+	}
+	m.notes[id][m.user()] = seriesNote{notes: notes, rating: rating}
+	m.touch(id)
+	return nil
+}
+
+func (m *MemoryStore) DeleteSeries(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.series[id]; ok && s.DeletedAt == nil {
+		now := memoryNow()
+		s.DeletedAt = &now
+		s.Version++
+	}
+	return nil
+}
+
+func (m *MemoryStore) MarkAsWatched(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := memoryNow()
+	for _, e := range m.episodes[id] {
+		m.setWatched(e, &now)
+	}
+	return nil
+}
+
+func (m *MemoryStore) MarkAsUnwatched(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 集数按顺序保存，最后一个即最新一集
+	if episodes := m.episodes[id]; len(episodes) > 0 {
+		m.setWatched(episodes[len(episodes)-1], nil)
+	}
+	return nil
+}
+
+func (m *MemoryStore) SetSeriesState(id int64, state string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.series[id]; !ok {
+		return sql.ErrNoRows
+	}
+	m.setState(id, state)
+	return nil
+}
+
+func (m *MemoryStore) ClearSeriesHistory(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleteEpisodes(id)
+	if s, ok := m.series[id]; ok {
+		s.Current = ""
+		s.UpdatedAt = memoryNow()
+		setStatus(s, "", s.UpdatedAt)
+		s.Version++
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetAllTrackingURLs(recheckFinishedBefore time.Time) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 与 sqliteTime 一致，只比较到秒
+	recheckFinishedBefore = recheckFinishedBefore.UTC().Truncate(time.Second)
+	due := func(s *Series) bool {
+		if s.FinishedAt == nil {
+			return true
+		}
+		if recheckFinishedBefore.IsZero() {
+			return false
+		}
+		lastSeen := s.FinishedAt
+		if s.CrawlerLastSeen != nil {
+			lastSeen = s.CrawlerLastSeen
+		}
+		return lastSeen.Truncate(time.Second).Before(recheckFinishedBefore)
+	}
+
+	var urls []string
+	for _, s := range m.sortedSeries(func(s *Series) bool { return m.isTracked(s.ID) && s.DeletedAt == nil && due(s) }) {
+		urls = append(urls, s.URL)
+	}
+	return urls, nil
+}
+
+func (m *MemoryStore) UpdateSeriesInfo(url string, current string, episodes []FetchEpisode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.findSeriesByURL(url, false)
+	if s == nil {
+		return sql.ErrNoRows
+	}
+
+	now := memoryNow()
+	s.Current = current
+	s.UpdatedAt = now
+	s.CrawlerLastSeen = &now
+	setStatus(s, current, now)
+	s.Version++
+	m.upsertEpisodes(s.ID, episodes, time.Now())
+	return nil
+}
+
+func (m *MemoryStore) UpdateSeriesCrawlerLastSeen(url string, lastSeen time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s := m.findSeriesByURL(url, false); s != nil {
+		lastSeen = lastSeen.UTC()
+		s.CrawlerLastSeen = &lastSeen
+		s.Version++
+	}
+	return nil
+}
+
+func (m *MemoryStore) UpdateSeriesCrawlerMetadata(url, posterURL, originalTitle string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.findSeriesByURL(url, false)
+	if s == nil {
+		return nil
+	}
+	meta := m.crawlerMeta[s.ID]
+	if posterURL != "" {
+		meta.PosterURL = posterURL
+	}
+	if originalTitle != "" {
+		meta.OriginalTitle = originalTitle
+	}
+	m.crawlerMeta[s.ID] = meta
+	s.Version++
+	return nil
+}
+
+func (m *MemoryStore) CreateCrawlRun(run *CrawlRun) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastCrawlRunID++
+	saved := CrawlRun{
+		ID:          m.lastCrawlRunID,
+		Tasks:       append([]string(nil), run.Tasks...),
+		TaskCount:   len(run.Tasks),
+		ResultCount: run.ResultCount,
+		Status:      run.Status,
+		Message:     run.Message,
+		DurationMs:  run.DurationMs,
+		CreatedAt:   memoryNow(),
+		Items:       []CrawlRunItem{},
+	}
+	for _, item := range run.Items {
+		item.SeriesID = copyID(item.SeriesID)
+		saved.Items = append(saved.Items, item)
+	}
+	m.crawlRuns = append(m.crawlRuns, saved)
+
+	return saved.ID, nil
+}
+
+// copyCrawlRun 复制爬虫记录，withItems 为 false 时不含明细
+func copyCrawlRun(run CrawlRun, withItems bool) CrawlRun {
+	c := run
+	c.Tasks = append([]string(nil), run.Tasks...)
+	c.Items = nil
+	if withItems {
+		c.Items = []CrawlRunItem{}
+		for _, item := range run.Items {
+			item.SeriesID = copyID(item.SeriesID)
+			c.Items = append(c.Items, item)
+		}
+	}
+	return c
+}
+
+func (m *MemoryStore) GetCrawlRuns(limit int) ([]CrawlRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	runs := []CrawlRun{}
+	for i := len(m.crawlRuns) - 1; i >= 0 && len(runs) < limitCount(len(m.crawlRuns), limit); i-- {
+		runs = append(runs, copyCrawlRun(m.crawlRuns[i], false))
+	}
+	return runs, nil
+}
+
+func (m *MemoryStore) GetCrawlRun(id int64) (*CrawlRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, run := range m.crawlRuns {
+		if run.ID == id {
+			c := copyCrawlRun(run, true)
+			return &c, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStore) GetStats(now time.Time, weeks int) (*Stats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := newStats(now, weeks)
+	var episodes []statsEpisode
+	for _, s := range m.sortedSeries(func(s *Series) bool { return s.DeletedAt == nil }) {
+		stats.SeriesCount++
+		state := m.subscriptions[s.ID][m.user()]
+		if state != "" {
+			stats.States[state]++
+		}
+
+		unwatched := 0
+		for _, e := range m.episodes[s.ID] {
+			episodes = append(episodes, statsEpisode{seriesID: s.ID, name: s.Name, firstSeenAt: e.FirstSeenAt})
+			if m.watchedAt(e) == nil {
+				unwatched++
+			}
+		}
+		if IsTrackingState(state) && unwatched > 0 {
+			stats.UnwatchedEpisodes += unwatched
+			stats.UnwatchedSeries++
+		}
+	}
+	stats.finishStates()
+	stats.addEpisodes(episodes)
+
+	for _, run := range m.crawlRuns {
+		if !stats.inPeriod(run.CreatedAt) {
+			continue
+		}
+		stats.Crawler.Runs++
+		if run.Status < 0 {
+			stats.Crawler.FailedRuns++
+		}
+		for _, item := range run.Items {
+			stats.addCrawlItems(item.Outcome, 1)
+		}
+	}
+	return stats, nil
+}
+
+// sortEpisodes 与 episodeOrder 一致
+func sortEpisodes(episodes []*Episode) {
+	sort.Slice(episodes, func(i, j int) bool {
+		a, b := episodes[i], episodes[j]
+		if a.Season != b.Season {
+			return a.Season < b.Season
+		}
+		if a.Number != b.Number {
+			return a.Number < b.Number
+		}
+		return a.Code < b.Code
+	})
+}
+
+func (m *MemoryStore) GetEpisodes(seriesID int64) ([]Episode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	episodes := []Episode{}
+	for _, e := range m.episodes[seriesID] {
+		c := *e
+		c.WatchedAt = m.watchedAt(e)
+		episodes = append(episodes, c)
+	}
+	return episodes, nil
+}
+
+func (m *MemoryStore) MarkWatchedUntil(seriesID int64, code string) error {
+	season, number, ok := ParseEpisodeCode(code)
+	if !ok {
+		return fmt.Errorf("无效的集数编号: %s", code)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := false
+	for _, e := range m.episodes[seriesID] {
+		if e.Season == season && e.Number == number {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return sql.ErrNoRows
+	}
+
+	now := memoryNow()
+	for _, e := range m.episodes[seriesID] {
+		if e.Season < season || (e.Season == season && e.Number <= number) {
+			m.setWatched(e, &now)
+		} else {
+			m.setWatched(e, nil)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) SetEpisodeWatched(seriesID int64, code string, watched bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.findEpisode(seriesID, code)
+	if e == nil {
+		return sql.ErrNoRows
+	}
+	var watchedAt *time.Time
+	if watched {
+		now := memoryNow()
+		watchedAt = &now
+	}
+	m.setWatched(e, watchedAt)
+	return nil
+}
+
+func (m *MemoryStore) TouchEpisodes(seriesID int64, episodes []FetchEpisode, seenAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 与外键约束一致
+	if _, ok := m.series[seriesID]; !ok {
+		return fmt.Errorf("剧集不存在: %d", seriesID)
+	}
+	m.upsertEpisodes(seriesID, episodes, seenAt)
+	return nil
+}
+
+// deleteEpisodes 删除剧集的所有集数并模拟观看状态的级联删除，调用方需持有锁
+func (m *MemoryStore) deleteEpisodes(seriesID int64) {
+	for _, e := range m.episodes[seriesID] {
+		delete(m.watches, e.ID)
+	}
+	if len(m.episodes[seriesID]) > 0 {
+		m.touch(seriesID)
+	}
+	delete(m.episodes, seriesID)
+}
+
+func (m *MemoryStore) findEpisode(seriesID int64, code string) *Episode {
+	for _, e := range m.episodes[seriesID] {
+		if e.Code == code {
+			return e
+		}
+	}
+	return nil
+}
+
+// upsertEpisodes 与 SQLite 实现的 upsertEpisodes 一致，调用方需持有锁
+func (m *MemoryStore) upsertEpisodes(seriesID int64, episodes []FetchEpisode, seenAt time.Time) {
+	seenAt = seenAt.UTC()
+	for _, ep := range episodes {
+		if e := m.findEpisode(seriesID, ep.Code); e != nil {
+			e.LastSeenAt = seenAt
+			if ep.Title != "" {
+				e.Title = ep.Title
+			}
+			continue
+		}
+
+		season, number, _ := ParseEpisodeCode(ep.Code)
+		m.lastEpisodeID++
+		m.episodes[seriesID] = append(m.episodes[seriesID], &Episode{
+			ID:          m.lastEpisodeID,
+			SeriesID:    seriesID,
+			Code:        ep.Code,
+			Season:      season,
+			Number:      number,
+			Title:       ep.Title,
+			FirstSeenAt: seenAt,
+			LastSeenAt:  seenAt,
+		})
+		m.touch(seriesID)
+	}
+	sortEpisodes(m.episodes[seriesID])
+}
+
+func (m *MemoryStore) CreateSeriesEvent(seriesID int64, eventType, source string, detail interface{}) error {
+	if detail == nil {
+		detail = map[string]interface{}{}
+	}
+	detailJSON, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.series[seriesID]
+	if !ok {
+		return fmt.Errorf("剧集不存在: %d", seriesID)
+	}
+
+	m.lastEventID++
+	m.events = append(m.events, SeriesEvent{
+		ID:         m.lastEventID,
+		SeriesID:   &seriesID,
+		SeriesName: s.Name,
+		Type:       eventType,
+		Source:     source,
+		UserID:     eventUserID(source, m.user()),
+		Detail:     json.RawMessage(detailJSON),
+		CreatedAt:  memoryNow(),
+	})
+	return nil
+}
+
+// queryEvents 按时间倒序返回当前用户可见且满足条件的事件
+func (m *MemoryStore) queryEvents(limit int, match func(e *SeriesEvent) bool) []SeriesEvent {
+	events := []SeriesEvent{}
+	for i := len(m.events) - 1; i >= 0; i-- {
+		if limit >= 0 && len(events) >= limit {
+			break
+		}
+		e := m.events[i]
+		if e.UserID != nil && *e.UserID != m.user() {
+			continue
+		}
+		if !match(&e) {
+			continue
+		}
+		e.SeriesID = copyID(e.SeriesID)
+		e.UserID = copyID(e.UserID)
+		events = append(events, e)
+	}
+	return events
+}
+
+func (m *MemoryStore) GetSeriesEvents(seriesID int64, limit int) ([]SeriesEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.queryEvents(limit, func(e *SeriesEvent) bool {
+		return e.SeriesID != nil && *e.SeriesID == seriesID
+	}), nil
+}
+
+func (m *MemoryStore) GetEvents(since time.Time, limit int) ([]SeriesEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 与 sqliteTime 一致，只比较到秒
+	since = since.UTC().Truncate(time.Second)
+	return m.queryEvents(limit, func(e *SeriesEvent) bool {
+		return !e.CreatedAt.Before(since)
+	}), nil
+}
+
+func (m *MemoryStore) GetTrashedSeries() ([]Series, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trashed := m.sortedSeries(func(s *Series) bool { return s.DeletedAt != nil })
+	sort.SliceStable(trashed, func(i, j int) bool {
+		return trashed[i].DeletedAt.After(*trashed[j].DeletedAt)
+	})
+
+	series := []Series{}
+	for _, s := range trashed {
+		series = append(series, m.view(s))
+	}
+	return series, nil
+}
+
+func (m *MemoryStore) RestoreSeries(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.series[id]
+	if !ok || s.DeletedAt == nil {
+		return sql.ErrNoRows
+	}
+	s.DeletedAt = nil
+	s.Version++
+	return nil
+}
+
+func (m *MemoryStore) PurgeSeries(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.series[id]
+	if !ok || s.DeletedAt == nil {
+		return sql.ErrNoRows
+	}
+	m.purge(id)
+	return nil
+}
+
+func (m *MemoryStore) PurgeTrash(before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before = before.UTC().Truncate(time.Second)
+	var purged int64
+	for _, s := range m.sortedSeries(func(s *Series) bool { return s.DeletedAt != nil && s.DeletedAt.Before(before) }) {
+		m.purge(s.ID)
+		purged++
+	}
+	return purged, nil
+}
+
+// purge 删除剧集并模拟外键的级联删除与置空，调用方需持有锁
+func (m *MemoryStore) purge(id int64) {
+	delete(m.series, id)
+	m.deleteEpisodes(id)
+	delete(m.subscriptions, id)
+	delete(m.seriesTags, id)
+	delete(m.crawlerMeta, id)
+	delete(m.notes, id)
+
+	for i := range m.events {
+		if e := &m.events[i]; e.SeriesID != nil && *e.SeriesID == id {
+			e.SeriesID = nil
+		}
+	}
+	for i := range m.crawlRuns {
+		for j := range m.crawlRuns[i].Items {
+			if item := &m.crawlRuns[i].Items[j]; item.SeriesID != nil && *item.SeriesID == id {
+				item.SeriesID = nil
+			}
+		}
+	}
+}
+
+// MergeSeries 与 SQLite 实现一致，冲突时以 targetID 为准
+func (m *MemoryStore) MergeSeries(targetID, sourceID int64) error {
+	if targetID == sourceID {
+		return errMergeSelf
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	target, ok := m.series[targetID]
+	source, ok2 := m.series[sourceID]
+	if !ok || !ok2 {
+		return sql.ErrNoRows
+	}
+
+	for _, e := range m.episodes[sourceID] {
+		t := m.findEpisode(targetID, e.Code)
+		if t == nil {
+			e.SeriesID = targetID
+			m.episodes[targetID] = append(m.episodes[targetID], e)
+			continue
+		}
+		if e.FirstSeenAt.Before(t.FirstSeenAt) {
+			t.FirstSeenAt = e.FirstSeenAt
+		}
+		if t.Title == "" {
+			t.Title = e.Title
+		}
+		for userID, watchedAt := range m.watches[e.ID] {
+			if m.watches[t.ID] == nil {
+				m.watches[t.ID] = make(map[int64]time.Time)
+			}
+			if _, ok := m.watches[t.ID][userID]; !ok {
+				m.watches[t.ID][userID] = watchedAt
+			}
+		}
+		delete(m.watches, e.ID)
+	}
+	delete(m.episodes, sourceID)
+	sortEpisodes(m.episodes[targetID])
+
+	for userID, state := range m.subscriptions[sourceID] {
+		if _, ok := m.subscriptions[targetID][userID]; !ok {
+			if m.subscriptions[targetID] == nil {
+				m.subscriptions[targetID] = make(map[int64]string)
+			}
+			m.subscriptions[targetID][userID] = state
+		}
+	}
+	for userID, note := range m.notes[sourceID] {
+		if _, ok := m.notes[targetID][userID]; !ok {
+			if m.notes[targetID] == nil {
+				m.notes[targetID] = make(map[int64]seriesNote)
+			}
+			m.notes[targetID][userID] = note
+		}
+	}
+	for tagID := range m.seriesTags[sourceID] {
+		if m.seriesTags[targetID] == nil {
+			m.seriesTags[targetID] = make(map[int64]bool)
+		}
+		m.seriesTags[targetID][tagID] = true
+	}
+
+	for i := range m.events {
+		if e := &m.events[i]; e.SeriesID != nil && *e.SeriesID == sourceID {
+			e.SeriesID = copyID(&targetID)
+		}
+	}
+	for i := range m.crawlRuns {
+		// 记录在快照中共用，修改前先复制
+		items := append([]CrawlRunItem(nil), m.crawlRuns[i].Items...)
+		for j := range items {
+			if item := &items[j]; item.SeriesID != nil && *item.SeriesID == sourceID {
+				item.SeriesID = copyID(&targetID)
+			}
+		}
+		m.crawlRuns[i].Items = items
+	}
+
+	fillEmpty := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fillEmpty(&target.PosterURL, source.PosterURL)
+	fillEmpty(&target.OriginalTitle, source.OriginalTitle)
+	if target.Year == 0 {
+		target.Year = source.Year
+	}
+	fillEmpty(&target.DoubanID, source.DoubanID)
+	fillEmpty(&target.IMDbID, source.IMDbID)
+	fillEmpty(&target.TMDBID, source.TMDBID)
+	crawler, sourceCrawler := m.crawlerMeta[targetID], m.crawlerMeta[sourceID]
+	fillEmpty(&crawler.PosterURL, sourceCrawler.PosterURL)
+	fillEmpty(&crawler.OriginalTitle, sourceCrawler.OriginalTitle)
+	m.crawlerMeta[targetID] = crawler
+
+	if source.CreatedAt.Before(target.CreatedAt) {
+		target.CreatedAt = source.CreatedAt
+	}
+	if source.CrawlerLastSeen != nil && (target.CrawlerLastSeen == nil || source.CrawlerLastSeen.After(*target.CrawlerLastSeen)) {
+		target.CrawlerLastSeen = copyTime(source.CrawlerLastSeen)
+	}
+	if target.Current == "" && source.Current != "" {
+		target.Current = source.Current
+		target.SeriesStatus = source.SeriesStatus
+		target.FinishedAt = copyTime(source.FinishedAt)
+		if source.UpdatedAt.After(target.UpdatedAt) {
+			target.UpdatedAt = source.UpdatedAt
+		}
+	}
+	target.Version++
+
+	m.purge(sourceID)
+	return nil
+}
+
+// tagView 生成标签副本并统计关联的剧集数（不含回收站）
+func (m *MemoryStore) tagView(t *Tag) Tag {
+	v := *t
+	v.SeriesCount = 0
+	for seriesID, tags := range m.seriesTags {
+		if tags[t.ID] && m.series[seriesID].DeletedAt == nil {
+			v.SeriesCount++
+		}
+	}
+	return v
+}
+
+// findTagByName 按名称查找标签，不区分大小写
+func (m *MemoryStore) findTagByName(name string) *Tag {
+	name = nocaseFold(name)
+	for _, t := range m.tags {
+		if nocaseFold(t.Name) == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetAllTags() ([]Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tags := []Tag{}
+	for _, t := range m.tags {
+		tags = append(tags, m.tagView(t))
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return nocaseFold(tags[i].Name) < nocaseFold(tags[j].Name)
+	})
+	return tags, nil
+}
+
+func (m *MemoryStore) GetTagByID(id int64) (*Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tags[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	v := m.tagView(t)
+	return &v, nil
+}
+
+func (m *MemoryStore) CreateTag(name, color string) (*Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.findTagByName(name) != nil {
+		return nil, fmt.Errorf("tags.name: %w", ErrUniqueViolation)
+	}
+	t := m.createTag(name, color)
+	v := m.tagView(t)
+	return &v, nil
+}
+
+// createTag 创建标签，调用方需持有锁并检查名称冲突
+func (m *MemoryStore) createTag(name, color string) *Tag {
+	m.lastTagID++
+	t := &Tag{ID: m.lastTagID, Name: name, Color: color, CreatedAt: memoryNow()}
+	m.tags[t.ID] = t
+	return t
+}
+
+func (m *MemoryStore) UpdateTag(id int64, name, color string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tags[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if other := m.findTagByName(name); other != nil && other.ID != id {
+		return fmt.Errorf("tags.name: %w", ErrUniqueViolation)
+	}
+	if t.Name != name {
+		for seriesID, tags := range m.seriesTags {
+			if tags[id] {
+				m.touch(seriesID)
+			}
+		}
+	}
+	t.Name = name
+	t.Color = color
+	return nil
+}
+
+func (m *MemoryStore) DeleteTag(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tags[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.tags, id)
+	for seriesID, tags := range m.seriesTags {
+		if tags[id] {
+			delete(tags, id)
+			m.touch(seriesID)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) SetSeriesTags(seriesID int64, names []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 与外键约束一致
+	if _, ok := m.series[seriesID]; !ok {
+		return fmt.Errorf("剧集不存在: %d", seriesID)
+	}
+
+	if len(m.seriesTags[seriesID]) > 0 {
+		m.touch(seriesID)
+	}
+	m.seriesTags[seriesID] = make(map[int64]bool)
+	m.addSeriesTags(seriesID, names)
+	return nil
+}
+
+// addSeriesTags 为剧集添加标签，不存在的标签会自动创建，调用方需持有锁
+func (m *MemoryStore) addSeriesTags(seriesID int64, names []string) {
+	if m.seriesTags[seriesID] == nil {
+		m.seriesTags[seriesID] = make(map[int64]bool)
+	}
+	for _, name := range names {
+		name = NormalizeTagName(name)
+		if name == "" {
+			continue
+		}
+		t := m.findTagByName(name)
+		if t == nil {
+			t = m.createTag(name, "")
+		}
+		if !m.seriesTags[seriesID][t.ID] {
+			m.seriesTags[seriesID][t.ID] = true
+			m.touch(seriesID)
+		}
+	}
+}
+
+func (m *MemoryStore) ImportSeries(record SeriesRecord) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.findSeriesByURL(record.URL, false)
+	if s == nil {
+		if m.findSeriesByURL(record.URL, true) != nil {
+			return 0, fmt.Errorf("series.url: %w", ErrUniqueViolation)
+		}
+		m.lastSeriesID++
+		s = &Series{
+			ID:              m.lastSeriesID,
+			Name:            record.Name,
+			URL:             NormalizeSeriesURL(record.URL),
+			Current:         record.Current,
+			CreatedAt:       timeOrNow(record.CreatedAt).UTC().Truncate(time.Second),
+			UpdatedAt:       timeOrNow(record.UpdatedAt).UTC().Truncate(time.Second),
+			CrawlerLastSeen: copyTime(record.CrawlerLastSeen),
+			SeriesMetadata:  record.SeriesMetadata,
+			Version:         1,
+		}
+		if s.CrawlerLastSeen != nil {
+			*s.CrawlerLastSeen = s.CrawlerLastSeen.UTC()
+		}
+		m.series[s.ID] = s
+	} else {
+		s.Name = record.Name
+		if record.Current != "" {
+			s.Current = record.Current
+		}
+		// 与 SQLite 实现一致，只补充用户和爬虫都未填写的字段
+		fillEmpty := func(dst *string, crawler, src string) {
+			if *dst == "" && crawler == "" {
+				*dst = src
+			}
+		}
+		crawler := m.crawlerMeta[s.ID]
+		fillEmpty(&s.PosterURL, crawler.PosterURL, record.PosterURL)
+		fillEmpty(&s.OriginalTitle, crawler.OriginalTitle, record.OriginalTitle)
+		if s.Year == 0 {
+			s.Year = record.Year
+		}
+		fillEmpty(&s.DoubanID, "", record.DoubanID)
+		fillEmpty(&s.IMDbID, "", record.IMDbID)
+		fillEmpty(&s.TMDBID, "", record.TMDBID)
+		s.Version++
+	}
+	if record.Current != "" {
+		setStatus(s, record.Current, memoryNow())
+	}
+	if record.State != nil {
+		m.setState(s.ID, *record.State)
+	}
+
+	if record.Notes != "" || record.Rating != 0 {
+		if m.notes[s.ID] == nil {
+			m.notes[s.ID] = make(map[int64]seriesNote)
+		}
+		note := m.notes[s.ID][m.user()]
+		if note.notes == "" {
+			note.notes = record.Notes
+		}
+		if note.rating == 0 {
+			note.rating = record.Rating
+		}
+		m.notes[s.ID][m.user()] = note
+	}
+
+	for _, ep := range record.Episodes {
+		var watchedAt *time.Time
+		if ep.WatchedAt != nil {
+			t := ep.WatchedAt.UTC().Truncate(time.Second)
+			watchedAt = &t
+		}
+		e := m.findEpisode(s.ID, ep.Code)
+		if e != nil {
+			if e.Title == "" {
+				e.Title = ep.Title
+			}
+		} else {
+			m.upsertEpisodes(s.ID, []FetchEpisode{{Code: ep.Code, Title: ep.Title}}, timeOrNow(ep.FirstSeenAt))
+			e = m.findEpisode(s.ID, ep.Code)
+		}
+		if watchedAt != nil {
+			m.setWatched(e, watchedAt)
+		}
+	}
+
+	m.addSeriesTags(s.ID, record.Tags)
+	return s.ID, nil
+}
+
+// SearchSeries 内存实现不区分长短查询，统一按名称、原名、集数标题和当前用户的备注子串匹配，名称命中优先
+func (m *MemoryStore) SearchSeries(q string, limit int) ([]SearchHit, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return []SearchHit{}, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	folded := nocaseFold(q)
+	type match struct {
+		series *Series
+		byName bool
+		title  string // 命中的集数标题
+	}
+	var matches []match
+	for _, s := range m.sortedSeries(func(s *Series) bool { return s.DeletedAt == nil }) {
+		v := m.view(s)
+		mt := match{series: s, byName: strings.Contains(nocaseFold(s.Name), folded)}
+		for _, e := range m.episodes[s.ID] {
+			if strings.Contains(nocaseFold(e.Title), folded) {
+				mt.title = e.Title
+				break
+			}
+		}
+		byMeta := strings.Contains(nocaseFold(v.OriginalTitle), folded) || strings.Contains(nocaseFold(v.Notes), folded)
+		if mt.byName || mt.title != "" || byMeta {
+			matches = append(matches, mt)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].byName != matches[j].byName {
+			return matches[i].byName
+		}
+		return matches[i].series.UpdatedAt.After(matches[j].series.UpdatedAt)
+	})
+
+	hits := []SearchHit{}
+	for _, mt := range matches[:limitCount(len(matches), limit)] {
+		hit := SearchHit{
+			Series:    m.view(mt.series),
+			Name:      highlightSubstring(mt.series.Name, q),
+			MatchedBy: "substring",
+		}
+		if mt.title != "" && utf8.RuneCountInString(q) >= minFTSQueryLength {
+			hit.Snippet = highlightSubstring(mt.title, q)
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
+
+func (m *MemoryStore) GetSettings() (*Settings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return NewSettings(m.settings), nil
+}
+
+func (m *MemoryStore) UpdateSettings(values map[string]string, changedBy string) ([]SettingChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changedAt := memoryNow()
+	changes := []SettingChange{}
+	for _, key := range sortedKeys(values) {
+		old, value := m.settings[key], values[key]
+		if old == value {
+			continue
+		}
+		if value == "" {
+			delete(m.settings, key)
+		} else {
+			m.settings[key] = value
+		}
+
+		m.lastChangeID++
+		change := SettingChange{
+			ID:        m.lastChangeID,
+			Key:       key,
+			OldValue:  old,
+			NewValue:  value,
+			ChangedBy: changedBy,
+			ChangedAt: changedAt,
+		}
+		m.changes = append(m.changes, change)
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func (m *MemoryStore) GetSettingsHistory(key string, limit int) ([]SettingChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changes := []SettingChange{}
+	n := limitCount(len(m.changes), limit)
+	for i := len(m.changes) - 1; i >= 0 && len(changes) < n; i-- {
+		if key == "" || m.changes[i].Key == key {
+			changes = append(changes, m.changes[i])
+		}
+	}
+	return changes, nil
+}
+
+func (m *MemoryStore) GetSettingChange(id int64) (*SettingChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.changes {
+		if c.ID == id {
+			change := c
+			return &change, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// findUserByName 按用户名查找用户，不区分大小写
+func (m *MemoryStore) findUserByName(username string) *User {
+	username = nocaseFold(username)
+	for _, u := range m.users {
+		if nocaseFold(u.Username) == username {
+			return u
+		}
+	}
+	return nil
+}
+
+// sortedUsers 按 ID 顺序返回满足条件的用户副本
+func (m *MemoryStore) sortedUsers(match func(u *User) bool) []User {
+	users := []User{}
+	for _, u := range m.users {
+		if match(u) {
+			users = append(users, *u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users
+}
+
+func (m *MemoryStore) GetUsers() ([]User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.sortedUsers(func(u *User) bool { return true }), nil
+}
+
+func (m *MemoryStore) GetUserByID(id int64) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	c := *u
+	return &c, nil
+}
+
+func (m *MemoryStore) GetUserByName(username string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u := m.findUserByName(username)
+	if u == nil {
+		return nil, sql.ErrNoRows
+	}
+	c := *u
+	return &c, nil
+}
+
+func (m *MemoryStore) CreateUser(user *User) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.findUserByName(user.Username) != nil {
+		return nil, fmt.Errorf("users.username: %w", ErrUniqueViolation)
+	}
+
+	m.lastUserID++
+	u := *user
+	u.ID = m.lastUserID
+	u.CreatedAt = memoryNow()
+	m.users[u.ID] = &u
+
+	c := u
+	return &c, nil
+}
+
+func (m *MemoryStore) UpdateUser(user *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[user.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if other := m.findUserByName(user.Username); other != nil && other.ID != user.ID {
+		return fmt.Errorf("users.username: %w", ErrUniqueViolation)
+	}
+	u.Username = user.Username
+	u.PasswordHash = user.PasswordHash
+	u.IsAdmin = user.IsAdmin
+	u.SlackWebhookURL = user.SlackWebhookURL
+	return nil
+}
+
+func (m *MemoryStore) DeleteUser(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.users, id)
+	// 与外键的级联删除一致
+	for seriesID, users := range m.subscriptions {
+		if _, ok := users[id]; ok {
+			delete(users, id)
+			m.touch(seriesID)
+		}
+	}
+	for episodeID, users := range m.watches {
+		if _, ok := users[id]; ok {
+			delete(users, id)
+			m.touchEpisode(episodeID)
+		}
+	}
+	for _, users := range m.notes {
+		delete(users, id)
+	}
+	events := m.events[:0]
+	for _, e := range m.events {
+		if e.UserID == nil || *e.UserID != id {
+			events = append(events, e)
+		}
+	}
+	m.events = events
+	for sessionID, session := range m.sessions {
+		if session.UserID == id {
+			delete(m.sessions, sessionID)
+		}
+	}
+	for keyID, key := range m.apiKeys {
+		if key.UserID == id {
+			delete(m.apiKeys, keyID)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetSubscribers(seriesID int64) ([]User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.sortedUsers(func(u *User) bool { return IsTrackingState(m.subscriptions[seriesID][u.ID]) }), nil
+}
+
+func (m *MemoryStore) CreateSession(session *Session) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[session.UserID]; !ok {
+		return nil, fmt.Errorf("sessions.user_id: 用户 %d 不存在", session.UserID)
+	}
+	for _, other := range m.sessions {
+		if other.TokenHash == session.TokenHash {
+			return nil, fmt.Errorf("sessions.token_hash: %w", ErrUniqueViolation)
+		}
+	}
+
+	m.lastSessionID++
+	c := *session
+	c.ID = m.lastSessionID
+	c.CreatedAt = c.CreatedAt.UTC().Truncate(time.Second)
+	c.LastUsedAt = c.LastUsedAt.UTC().Truncate(time.Second)
+	c.ExpiresAt = c.ExpiresAt.UTC().Truncate(time.Second)
+	m.sessions[c.ID] = &c
+
+	result := c
+	return &result, nil
+}
+
+func (m *MemoryStore) GetSessionByToken(tokenHash string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, session := range m.sessions {
+		if session.TokenHash == tokenHash {
+			c := *session
+			return &c, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStore) GetSessions(userID int64, now time.Time) ([]Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now = now.UTC().Truncate(time.Second)
+	sessions := []Session{}
+	for _, session := range m.sessions {
+		if session.UserID == userID && session.ExpiresAt.After(now) {
+			sessions = append(sessions, *session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastUsedAt.Equal(sessions[j].LastUsedAt) {
+			return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (m *MemoryStore) RenewSession(id int64, lastUsedAt, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	session.LastUsedAt = lastUsedAt.UTC().Truncate(time.Second)
+	session.ExpiresAt = expiresAt.UTC().Truncate(time.Second)
+	return nil
+}
+
+func (m *MemoryStore) DeleteSession(userID, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok || session.UserID != userID {
+		return sql.ErrNoRows
+	}
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemoryStore) DeleteUserSessions(userID, exceptID int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for id, session := range m.sessions {
+		if session.UserID == userID && id != exceptID {
+			delete(m.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MemoryStore) PurgeSessions(before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before = before.UTC().Truncate(time.Second)
+	var purged int64
+	for id, session := range m.sessions {
+		if !session.ExpiresAt.After(before) {
+			delete(m.sessions, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// copyAPIKey 复制 API 密钥，与 SQLite 实现一样返回独立的时间和范围
+func copyAPIKey(key *APIKey) *APIKey {
+	c := *key
+	c.Scopes = append([]string{}, key.Scopes...)
+	if key.LastUsedAt != nil {
+		t := *key.LastUsedAt
+		c.LastUsedAt = &t
+	}
+	if key.RevokedAt != nil {
+		t := *key.RevokedAt
+		c.RevokedAt = &t
+	}
+	return &c
+}
+
+func (m *MemoryStore) CreateAPIKey(key *APIKey) (*APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[key.UserID]; !ok {
+		return nil, fmt.Errorf("api_keys.user_id: 用户 %d 不存在", key.UserID)
+	}
+	for _, other := range m.apiKeys {
+		if other.KeyHash == key.KeyHash {
+			return nil, fmt.Errorf("api_keys.key_hash: %w", ErrUniqueViolation)
+		}
+	}
+
+	m.lastAPIKeyID++
+	c := copyAPIKey(key)
+	c.ID = m.lastAPIKeyID
+	c.CreatedAt = memoryNow()
+	c.LastUsedAt = nil
+	c.RevokedAt = nil
+	m.apiKeys[c.ID] = c
+	return copyAPIKey(c), nil
+}
+
+func (m *MemoryStore) GetAPIKeys() ([]APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := []APIKey{}
+	for _, key := range m.apiKeys {
+		keys = append(keys, *copyAPIKey(key))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID > keys[j].ID })
+	return keys, nil
+}
+
+func (m *MemoryStore) GetAPIKeyByHash(keyHash string) (*APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range m.apiKeys {
+		if key.KeyHash == keyHash {
+			return copyAPIKey(key), nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStore) TouchAPIKey(id int64, usedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.apiKeys[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	usedAt = usedAt.UTC().Truncate(time.Second)
+	key.LastUsedAt = &usedAt
+	return nil
+}
+
+func (m *MemoryStore) RevokeAPIKey(id int64, revokedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.apiKeys[id]
+	if !ok || key.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+	revokedAt = revokedAt.UTC().Truncate(time.Second)
+	key.RevokedAt = &revokedAt
+	return nil
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// errMergeSelf 合并的两个剧集相同
+var errMergeSelf = errors.New("不能将剧集合并到自身")
+
+// mergeSeriesStatements 合并剧集的语句，?1 为保留的剧集，?2 为被合并的剧集
+var mergeSeriesStatements = []string{
+	// 两边都有的集数：保留最早的首次出现时间，补充缺少的标题，观看记录合并到保留的集数
+	`UPDATE episodes SET
+		first_seen_at = MIN(first_seen_at, (SELECT s.first_seen_at FROM episodes s WHERE s.series_id = ?2 AND s.code = episodes.code)),
+		title = CASE WHEN title != '' THEN title ELSE (SELECT s.title FROM episodes s WHERE s.series_id = ?2 AND s.code = episodes.code) END
+	WHERE series_id = ?1 AND code IN (SELECT code FROM episodes WHERE series_id = ?2)`,
+	`INSERT OR IGNORE INTO episode_watches (user_id, episode_id, watched_at)
+	SELECT w.user_id, t.id, w.watched_at
+	FROM episode_watches w
+	JOIN episodes s ON s.id = w.episode_id
+	JOIN episodes t ON t.series_id = ?1 AND t.code = s.code
+	WHERE s.series_id = ?2`,
+	// 只有被合并的剧集才有的集数连同观看记录一起移动
+	`UPDATE episodes SET series_id = ?1
+	WHERE series_id = ?2 AND code NOT IN (SELECT code FROM episodes WHERE series_id = ?1)`,
+
+	// 片单、备注和标签：保留的剧集已有的不覆盖
+	`INSERT OR IGNORE INTO subscriptions (user_id, series_id, state, created_at, state_changed_at)
+	SELECT user_id, ?1, state, created_at, state_changed_at FROM subscriptions WHERE series_id = ?2`,
+	`INSERT OR IGNORE INTO series_notes (user_id, series_id, notes, rating, updated_at)
+	SELECT user_id, ?1, notes, rating, updated_at FROM series_notes WHERE series_id = ?2`,
+	`INSERT OR IGNORE INTO series_tags (series_id, tag_id)
+	SELECT ?1, tag_id FROM series_tags WHERE series_id = ?2`,
+
+	// 事件和爬虫记录归属保留的剧集
+	`UPDATE series_events SET series_id = ?1 WHERE series_id = ?2`,
+	`UPDATE crawl_run_items SET series_id = ?1 WHERE series_id = ?2`,
+
+	// 元数据只补充空值；保留的剧集没有进度时使用被合并剧集的进度和完结状态
+	`UPDATE series SET
+		poster_url = CASE WHEN series.poster_url != '' THEN series.poster_url ELSE s.poster_url END,
+		original_title = CASE WHEN series.original_title != '' THEN series.original_title ELSE s.original_title END,
+		year = CASE WHEN series.year != 0 THEN series.year ELSE s.year END,
+		douban_id = CASE WHEN series.douban_id != '' THEN series.douban_id ELSE s.douban_id END,
+		imdb_id = CASE WHEN series.imdb_id != '' THEN series.imdb_id ELSE s.imdb_id END,
+		tmdb_id = CASE WHEN series.tmdb_id != '' THEN series.tmdb_id ELSE s.tmdb_id END,
+		crawler_poster_url = CASE WHEN series.crawler_poster_url != '' THEN series.crawler_poster_url ELSE s.crawler_poster_url END,
+		crawler_original_title = CASE WHEN series.crawler_original_title != '' THEN series.crawler_original_title ELSE s.crawler_original_title END,
+		created_at = MIN(series.created_at, s.created_at),
+		crawler_last_seen = COALESCE(MAX(series.crawler_last_seen, s.crawler_last_seen), series.crawler_last_seen, s.crawler_last_seen)
+	FROM (SELECT * FROM series WHERE id = ?2) AS s
+	WHERE series.id = ?1`,
+	`UPDATE series SET
+		current = s.current, latest_episode = s.latest_episode, total_episodes = s.total_episodes,
+		finished_at = s.finished_at, updated_at = MAX(series.updated_at, s.updated_at)
+	FROM (SELECT * FROM series WHERE id = ?2) AS s
+	WHERE series.id = ?1 AND series.current = '' AND s.current != ''`,
+
+	// 移动集数不会触发全文索引的更新
+	`UPDATE series_fts SET episode_titles = COALESCE((
+		SELECT group_concat(title, char(10)) FROM episodes
+		WHERE series_id = ?1 AND title != ''
+	), '') WHERE rowid = ?1`,
+
+	`DELETE FROM series WHERE id = ?2`,
+}
+
+// MergeSeries 将重复的剧集 sourceID 合并到 targetID 后彻底删除 sourceID（用于合并 URL 重复的剧集）
+//
+// 集数、观看记录、片单状态、备注和标签取两者的并集，冲突时以 targetID 为准；事件和爬虫记录改为归属 targetID。
+// 任一剧集不存在时返回 sql.ErrNoRows。
+func (d *Database) MergeSeries(targetID, sourceID int64) error {
+	if targetID == sourceID {
+		return errMergeSelf
+	}
+
+	tx, err := d.begin()
+	if err != nil {
+		return err
+	}
+
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM series WHERE id IN (?, ?)", targetID, sourceID).Scan(&count); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if count != 2 {
+		tx.Rollback()
+		return sql.ErrNoRows
+	}
+
+	for _, stmt := range mergeSeriesStatements {
+		if _, err := tx.Exec(stmt, targetID, sourceID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// SeriesMetadata 剧集元数据，所有用户共享，字段为空表示未知
+//
+// 海报和原名也可由爬虫填充，爬虫的值单独保存，用户填写的值优先；用户清空后恢复使用爬虫的值。
+type SeriesMetadata struct {
+	PosterURL     string `json:"poster_url"`
+	OriginalTitle string `json:"original_title"`
+	Year          int    `json:"year"` // 首播年份，0 表示未知
+	DoubanID      string `json:"douban_id"`
+	IMDbID        string `json:"imdb_id"`
+	TMDBID        string `json:"tmdb_id"`
+}
+
+// 年份和评分的取值范围
+const (
+	MinSeriesYear = 1900
+	MaxSeriesYear = 2100
+	MaxRating     = 10
+)
+
+var (
+	imdbIDPattern    = regexp.MustCompile(`^tt\d+$`)
+	numericIDPattern = regexp.MustCompile(`^\d+$`)
+)
+
+// SeriesMetadataPatch 元数据的部分更新，nil 字段保持不变，空值清除
+type SeriesMetadataPatch struct {
+	PosterURL     *string `json:"poster_url"`
+	OriginalTitle *string `json:"original_title"`
+	Year          *int    `json:"year"`
+	DoubanID      *string `json:"douban_id"`
+	IMDbID        *string `json:"imdb_id"`
+	TMDBID        *string `json:"tmdb_id"`
+}
+
+// IsEmpty 是否没有任何需要更新的字段
+func (p *SeriesMetadataPatch) IsEmpty() bool {
+	return p.PosterURL == nil && p.OriginalTitle == nil && p.Year == nil &&
+		p.DoubanID == nil && p.IMDbID == nil && p.TMDBID == nil
+}
+
+// Normalize 去除首尾空白并校验提交的字段
+func (p *SeriesMetadataPatch) Normalize() error {
+	for _, field := range []*string{p.PosterURL, p.OriginalTitle, p.DoubanID, p.IMDbID, p.TMDBID} {
+		if field != nil {
+			*field = strings.TrimSpace(*field)
+		}
+	}
+
+	if p.PosterURL != nil && *p.PosterURL != "" {
+		u, err := url.Parse(*p.PosterURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("海报地址必须是 http 或 https 地址")
+		}
+	}
+	if p.Year != nil && *p.Year != 0 && (*p.Year < MinSeriesYear || *p.Year > MaxSeriesYear) {
+		return errors.New("年份超出范围")
+	}
+	if p.DoubanID != nil && *p.DoubanID != "" && !numericIDPattern.MatchString(*p.DoubanID) {
+		return errors.New("豆瓣 ID 必须是数字")
+	}
+	if p.IMDbID != nil && *p.IMDbID != "" && !imdbIDPattern.MatchString(*p.IMDbID) {
+		return errors.New("IMDb ID 格式不正确，例如 tt0903747")
+	}
+	if p.TMDBID != nil && *p.TMDBID != "" && !numericIDPattern.MatchString(*p.TMDBID) {
+		return errors.New("TMDB ID 必须是数字")
+	}
+	return nil
+}
+
+// Apply 将提交的字段写入元数据
+func (p *SeriesMetadataPatch) Apply(m *SeriesMetadata) {
+	if p.PosterURL != nil {
+		m.PosterURL = *p.PosterURL
+	}
+	if p.OriginalTitle != nil {
+		m.OriginalTitle = *p.OriginalTitle
+	}
+	if p.Year != nil {
+		m.Year = *p.Year
+	}
+	if p.DoubanID != nil {
+		m.DoubanID = *p.DoubanID
+	}
+	if p.IMDbID != nil {
+		m.IMDbID = *p.IMDbID
+	}
+	if p.TMDBID != nil {
+		m.TMDBID = *p.TMDBID
+	}
+}
+
+// ValidateRating 校验评分，0 表示未评分
+func ValidateRating(rating int) error {
+	if rating < 0 || rating > MaxRating {
+		return errors.New("评分必须在 1 到 10 之间")
+	}
+	return nil
+}
+
+// 用户填写的海报和原名优先于爬虫的值
+const (
+	posterURLColumn     = "CASE WHEN series.poster_url != '' THEN series.poster_url ELSE series.crawler_poster_url END"
+	originalTitleColumn = "CASE WHEN series.original_title != '' THEN series.original_title ELSE series.crawler_original_title END"
+)
+
+// metadataColumns 元数据查询字段，顺序与 SeriesMetadata 一致
+const metadataColumns = posterURLColumn + " AS poster_url, " + originalTitleColumn + ` AS original_title,
+	series.year, series.douban_id, series.imdb_id, series.tmdb_id`
+
+// UpdateSeriesMetadata 保存用户填写的元数据，只更新提交的字段，剧集不存在时返回 sql.ErrNoRows
+func (d *Database) UpdateSeriesMetadata(id int64, patch SeriesMetadataPatch) error {
+	result, err := d.db.Exec(`
+		UPDATE series
+		SET poster_url = COALESCE(?, poster_url),
+			original_title = COALESCE(?, original_title),
+			year = COALESCE(?, year),
+			douban_id = COALESCE(?, douban_id),
+			imdb_id = COALESCE(?, imdb_id),
+			tmdb_id = COALESCE(?, tmdb_id)
+		WHERE id = ?
+	`, patch.PosterURL, patch.OriginalTitle, patch.Year, patch.DoubanID, patch.IMDbID, patch.TMDBID, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// UpdateSeriesCrawlerMetadata 保存爬虫发现的海报和原名，空值表示页面未提供，保留原有的值
+func (d *Database) UpdateSeriesCrawlerMetadata(url, posterURL, originalTitle string) error {
+	_, err := d.db.Exec(`
+		UPDATE series
+		SET crawler_poster_url = CASE WHEN ? != '' THEN ? ELSE crawler_poster_url END,
+			crawler_original_title = CASE WHEN ? != '' THEN ? ELSE crawler_original_title END
+		WHERE url = ? AND deleted_at IS NULL
+	`, posterURL, posterURL, originalTitle, originalTitle, NormalizeSeriesURL(url))
+	return err
+}
+
+// UpdateSeriesNotes 保存当前用户的备注和评分，剧集不存在时返回 sql.ErrNoRows
+func (d *Database) UpdateSeriesNotes(id int64, notes string, rating int) error {
+	result, err := d.db.Exec(`
+		INSERT INTO series_notes (user_id, series_id, notes, rating, updated_at)
+		SELECT ?, id, ?, ?, CURRENT_TIMESTAMP FROM series WHERE id = ?
+		ON CONFLICT (user_id, series_id) DO UPDATE SET
+			notes = excluded.notes, rating = excluded.rating, updated_at = excluded.updated_at
+	`, d.user(), notes, rating, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
@@ -0,0 +1,727 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// migration 一次版本化的数据库结构变更
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// migrations 按版本号升序排列，只允许在末尾追加，已发布的迁移不得修改
+var migrations = []migration{
+	{version: 1, name: "create_series_and_settings", up: migrateCreateSeriesAndSettings},
+	{version: 2, name: "add_series_crawler_last_seen", up: migrateAddSeriesCrawlerLastSeen},
+	{version: 3, name: "create_episodes", up: migrateCreateEpisodes},
+	{version: 4, name: "episode_watch_progress", up: migrateEpisodeWatchProgress},
+	{version: 5, name: "create_crawl_runs", up: migrateCreateCrawlRuns},
+	{version: 6, name: "create_series_events", up: migrateCreateSeriesEvents},
+	{version: 7, name: "add_series_deleted_at", up: migrateAddSeriesDeletedAt},
+	{version: 8, name: "create_tags", up: migrateCreateTags},
+	{version: 9, name: "create_series_fts", up: migrateCreateSeriesFTS},
+	{version: 10, name: "create_settings_history", up: migrateCreateSettingsHistory},
+	{version: 11, name: "create_users", up: migrateCreateUsers},
+	{version: 12, name: "add_series_metadata", up: migrateAddSeriesMetadata},
+	{version: 13, name: "add_subscription_state", up: migrateAddSubscriptionState},
+	{version: 14, name: "add_series_status", up: migrateAddSeriesStatus},
+	{version: 15, name: "add_series_version", up: migrateAddSeriesVersion},
+	{version: 16, name: "normalize_series_urls", up: migrateNormalizeSeriesURLs},
+	{version: 17, name: "create_sessions", up: migrateCreateSessions},
+	{version: 18, name: "create_api_keys", up: migrateCreateAPIKeys},
+	{version: 19, name: "add_series_fts_metadata", up: migrateAddSeriesFTSMetadata},
+	{version: 20, name: "add_series_events_user_id", up: migrateAddSeriesEventsUserID},
+}
+
+// latestSchemaVersion 当前程序支持的最新结构版本
+func latestSchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].version
+}
+
+// migrate 应用所有尚未执行的迁移
+func (d *Database) migrate() error {
+	_, err := d.db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`)
+	if err != nil {
+		return fmt.Errorf("创建迁移记录表失败: %v", err)
+	}
+
+	current, err := d.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	// 数据库由更新版本的程序创建，继续运行可能破坏数据
+	if latest := latestSchemaVersion(); current > latest {
+		return fmt.Errorf("数据库结构版本 %d 高于程序支持的版本 %d，请升级程序", current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := d.applyMigration(m); err != nil {
+			return fmt.Errorf("执行迁移 %d_%s 失败: %v", m.version, m.name, err)
+		}
+		log.Printf("✅ 已应用数据库迁移: %d_%s", m.version, m.name)
+	}
+
+	return nil
+}
+
+// applyMigration 在单个事务中执行迁移并记录版本
+func (d *Database) applyMigration(m migration) error {
+	tx, err := d.pool.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := m.up(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.version, m.name); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SchemaVersion 获取数据库当前的结构版本
+func (d *Database) SchemaVersion() (int, error) {
+	var version int
+	err := d.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("读取数据库结构版本失败: %v", err)
+	}
+	return version, nil
+}
+
+// columnExists 检查表中是否存在指定列
+func columnExists(tx *sql.Tx, tableName, columnName string) (bool, error) {
+	rows, err := tx.Query("PRAGMA table_info(" + tableName + ")")
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid int
+		var name, ctype string
+		var notnull, pk int
+		var dfltValue interface{}
+		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dfltValue, &pk); err != nil {
+			return false, err
+		}
+		if name == columnName {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// 1: 剧集表与全局配置表（旧版本可能已通过 CREATE TABLE IF NOT EXISTS 建好）
+func migrateCreateSeriesAndSettings(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS series (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		url TEXT UNIQUE NOT NULL,
+		history TEXT NOT NULL DEFAULT '[]',
+		current TEXT NOT NULL DEFAULT '',
+		is_watched BOOLEAN NOT NULL DEFAULT 0,
+		is_tracking BOOLEAN NOT NULL DEFAULT 1,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT
+	);`)
+	return err
+}
+
+// 2: 爬虫最后访问时间（旧版本可能已手动添加该列）
+func migrateAddSeriesCrawlerLastSeen(tx *sql.Tx) error {
+	exists, err := columnExists(tx, "series", "crawler_last_seen")
+	if err != nil || exists {
+		return err
+	}
+	_, err = tx.Exec("ALTER TABLE series ADD COLUMN crawler_last_seen DATETIME")
+	return err
+}
+
+// 3: 将 series.history JSON 数组拆分到 episodes 表，首次出现时间取剧集的更新时间
+func migrateCreateEpisodes(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE episodes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		series_id INTEGER NOT NULL REFERENCES series(id) ON DELETE CASCADE,
+		code TEXT NOT NULL,
+		season INTEGER NOT NULL DEFAULT 0,
+		number INTEGER NOT NULL DEFAULT 0,
+		title TEXT NOT NULL DEFAULT '',
+		first_seen_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (series_id, code)
+	);
+	CREATE INDEX idx_episodes_first_seen_at ON episodes(first_seen_at);`)
+	if err != nil {
+		return err
+	}
+
+	type legacy struct {
+		id        int64
+		history   string
+		updatedAt time.Time
+	}
+
+	rows, err := tx.Query("SELECT id, history, updated_at FROM series")
+	if err != nil {
+		return err
+	}
+	var all []legacy
+	for rows.Next() {
+		var l legacy
+		if err := rows.Scan(&l.id, &l.history, &l.updatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		all = append(all, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, l := range all {
+		var codes []string
+		if err := json.Unmarshal([]byte(l.history), &codes); err != nil {
+			return fmt.Errorf("解析剧集 %d 的历史集数失败: %v", l.id, err)
+		}
+		for _, code := range codes {
+			season, number, _ := ParseEpisodeCode(code)
+			_, err := tx.Exec(`
+				INSERT OR IGNORE INTO episodes (series_id, code, season, number, first_seen_at, last_seen_at)
+				VALUES (?, ?, ?, ?, ?, ?)
+			`, l.id, code, season, number, l.updatedAt, l.updatedAt)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.Exec("ALTER TABLE series DROP COLUMN history")
+	return err
+}
+
+// 4: 观看状态从 series.is_watched 迁移到单集 watched_at
+// 旧的 is_watched 只表示当前集是否已看：已看则全部标记为已看，未看则只保留最新一集为未看
+func migrateEpisodeWatchProgress(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE episodes ADD COLUMN watched_at DATETIME;
+
+	UPDATE episodes
+	SET watched_at = (SELECT updated_at FROM series WHERE series.id = episodes.series_id)
+	WHERE series_id IN (SELECT id FROM series WHERE is_watched = 1)
+		OR id != (
+			SELECT latest.id FROM episodes latest
+			WHERE latest.series_id = episodes.series_id
+			ORDER BY latest.season DESC, latest.number DESC, latest.code DESC LIMIT 1
+		);
+
+	ALTER TABLE series DROP COLUMN is_watched;`)
+	return err
+}
+
+// 5: 爬虫回调记录及每个 URL 的处理结果
+func migrateCreateCrawlRuns(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE crawl_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tasks TEXT NOT NULL DEFAULT '[]',
+		task_count INTEGER NOT NULL DEFAULT 0,
+		result_count INTEGER NOT NULL DEFAULT 0,
+		status INTEGER NOT NULL,
+		message TEXT NOT NULL DEFAULT '',
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE crawl_run_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id INTEGER NOT NULL REFERENCES crawl_runs(id) ON DELETE CASCADE,
+		url TEXT NOT NULL,
+		series_id INTEGER REFERENCES series(id) ON DELETE SET NULL,
+		outcome TEXT NOT NULL,
+		detail TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX idx_crawl_run_items_run_id ON crawl_run_items(run_id);`)
+	return err
+}
+
+// 6: 剧集变更事件，剧集删除后保留事件用于审计
+func migrateCreateSeriesEvents(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE series_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		series_id INTEGER REFERENCES series(id) ON DELETE SET NULL,
+		series_name TEXT NOT NULL DEFAULT '',
+		type TEXT NOT NULL,
+		source TEXT NOT NULL,
+		detail TEXT NOT NULL DEFAULT '{}',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX idx_series_events_series_id ON series_events(series_id);
+	CREATE INDEX idx_series_events_created_at ON series_events(created_at);`)
+	return err
+}
+
+// 7: 回收站（软删除）
+func migrateAddSeriesDeletedAt(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE series ADD COLUMN deleted_at DATETIME;
+	CREATE INDEX idx_series_deleted_at ON series(deleted_at);`)
+	return err
+}
+
+// 8: 标签及剧集与标签的多对多关系
+func migrateCreateTags(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		color TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE series_tags (
+		series_id INTEGER NOT NULL REFERENCES series(id) ON DELETE CASCADE,
+		tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+		PRIMARY KEY (series_id, tag_id)
+	);
+	CREATE INDEX idx_series_tags_tag_id ON series_tags(tag_id);`)
+	return err
+}
+
+// 9: 剧集全文索引（名称和集数原始标题），使用 trigram 分词以支持中文子串搜索
+// 索引通过触发器与 series、episodes 表保持同步，rowid 即 series.id
+func migrateCreateSeriesFTS(tx *sql.Tx) error {
+	var fts5 bool
+	if err := tx.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		return err
+	}
+	if !fts5 {
+		return fmt.Errorf("SQLite 未启用 FTS5，请使用 -tags sqlite_fts5 编译")
+	}
+
+	_, err := tx.Exec(`
+	CREATE VIRTUAL TABLE series_fts USING fts5(name, episode_titles, tokenize = 'trigram');
+
+	INSERT INTO series_fts (rowid, name, episode_titles)
+	SELECT id, name, COALESCE((
+		SELECT group_concat(title, char(10)) FROM episodes
+		WHERE series_id = series.id AND title != ''
+	), '') FROM series;
+
+	CREATE TRIGGER series_fts_insert AFTER INSERT ON series BEGIN
+		INSERT INTO series_fts (rowid, name, episode_titles) VALUES (new.id, new.name, '');
+	END;
+
+	CREATE TRIGGER series_fts_update AFTER UPDATE OF name ON series BEGIN
+		UPDATE series_fts SET name = new.name WHERE rowid = new.id;
+	END;
+
+	CREATE TRIGGER series_fts_delete AFTER DELETE ON series BEGIN
+		DELETE FROM series_fts WHERE rowid = old.id;
+	END;
+
+	CREATE TRIGGER episodes_fts_insert AFTER INSERT ON episodes WHEN new.title != '' BEGIN
+		UPDATE series_fts SET episode_titles = (
+			SELECT group_concat(title, char(10)) FROM episodes
+			WHERE series_id = new.series_id AND title != ''
+		) WHERE rowid = new.series_id;
+	END;
+
+	CREATE TRIGGER episodes_fts_update AFTER UPDATE OF title ON episodes WHEN old.title IS NOT new.title BEGIN
+		UPDATE series_fts SET episode_titles = COALESCE((
+			SELECT group_concat(title, char(10)) FROM episodes
+			WHERE series_id = new.series_id AND title != ''
+		), '') WHERE rowid = new.series_id;
+	END;
+
+	CREATE TRIGGER episodes_fts_delete AFTER DELETE ON episodes WHEN old.title != '' BEGIN
+		UPDATE series_fts SET episode_titles = COALESCE((
+			SELECT group_concat(title, char(10)) FROM episodes
+			WHERE series_id = old.series_id AND title != ''
+		), '') WHERE rowid = old.series_id;
+	END;`)
+	return err
+}
+
+// 10: 配置变更记录，空值表示使用默认值
+func migrateCreateSettingsHistory(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE settings_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		key TEXT NOT NULL,
+		old_value TEXT NOT NULL DEFAULT '',
+		new_value TEXT NOT NULL DEFAULT '',
+		changed_by TEXT NOT NULL DEFAULT '',
+		changed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX idx_settings_history_key ON settings_history(key, id);`)
+	return err
+}
+
+// 11: 多用户，追踪订阅和观看状态按用户保存，剧集和集数仍为共享数据
+// 用户 1 为 config.json 中配置的管理员，用户名在启动时同步，原有的追踪和观看状态归属于该用户
+func migrateCreateUsers(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		password_hash TEXT NOT NULL DEFAULT '',
+		is_admin BOOLEAN NOT NULL DEFAULT 0,
+		slack_webhook_url TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO users (id, username, is_admin) VALUES (1, 'admin', 1);
+
+	CREATE TABLE subscriptions (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		series_id INTEGER NOT NULL REFERENCES series(id) ON DELETE CASCADE,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, series_id)
+	);
+	CREATE INDEX idx_subscriptions_series_id ON subscriptions(series_id);
+	INSERT INTO subscriptions (user_id, series_id) SELECT 1, id FROM series WHERE is_tracking = 1;
+
+	CREATE TABLE episode_watches (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		episode_id INTEGER NOT NULL REFERENCES episodes(id) ON DELETE CASCADE,
+		watched_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, episode_id)
+	);
+	CREATE INDEX idx_episode_watches_episode_id ON episode_watches(episode_id);
+	INSERT INTO episode_watches (user_id, episode_id, watched_at)
+	SELECT 1, id, watched_at FROM episodes WHERE watched_at IS NOT NULL;
+
+	ALTER TABLE series DROP COLUMN is_tracking;
+	ALTER TABLE episodes DROP COLUMN watched_at;`)
+	return err
+}
+
+// 12: 剧集元数据（共享）及用户的备注和评分
+// 爬虫发现的海报和原名保存在 crawler_ 前缀的字段中，查询时用户填写的值优先
+func migrateAddSeriesMetadata(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE series ADD COLUMN poster_url TEXT NOT NULL DEFAULT '';
+	ALTER TABLE series ADD COLUMN original_title TEXT NOT NULL DEFAULT '';
+	ALTER TABLE series ADD COLUMN year INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE series ADD COLUMN douban_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE series ADD COLUMN imdb_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE series ADD COLUMN tmdb_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE series ADD COLUMN crawler_poster_url TEXT NOT NULL DEFAULT '';
+	ALTER TABLE series ADD COLUMN crawler_original_title TEXT NOT NULL DEFAULT '';
+
+	CREATE TABLE series_notes (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		series_id INTEGER NOT NULL REFERENCES series(id) ON DELETE CASCADE,
+		notes TEXT NOT NULL DEFAULT '',
+		rating INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, series_id)
+	);
+	CREATE INDEX idx_series_notes_series_id ON series_notes(series_id);`)
+	return err
+}
+
+// 13: 剧集状态取代追踪开关，subscriptions 即用户的片单
+// 原有的订阅为“在看”，管理员未追踪的剧集为“暂停”，其他用户未追踪的剧集不在片单中
+func migrateAddSubscriptionState(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE subscriptions ADD COLUMN state TEXT NOT NULL DEFAULT 'watching';
+	ALTER TABLE subscriptions ADD COLUMN state_changed_at DATETIME;
+	UPDATE subscriptions SET state_changed_at = created_at;
+
+	INSERT INTO subscriptions (user_id, series_id, state, state_changed_at)
+	SELECT 1, id, 'paused', CURRENT_TIMESTAMP FROM series
+	WHERE id NOT IN (SELECT series_id FROM subscriptions WHERE user_id = 1);`)
+	return err
+}
+
+// 14: 由状态文本解析出的最新集数、总集数和完结时间，已完结的剧集完结时间取剧集的更新时间
+func migrateAddSeriesStatus(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE series ADD COLUMN latest_episode TEXT NOT NULL DEFAULT '';
+	ALTER TABLE series ADD COLUMN total_episodes INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE series ADD COLUMN finished_at DATETIME;`)
+	if err != nil {
+		return err
+	}
+
+	type legacy struct {
+		id        int64
+		current   string
+		updatedAt time.Time
+	}
+
+	rows, err := tx.Query("SELECT id, current, updated_at FROM series WHERE current != ''")
+	if err != nil {
+		return err
+	}
+	var all []legacy
+	for rows.Next() {
+		var l legacy
+		if err := rows.Scan(&l.id, &l.current, &l.updatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		all = append(all, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, l := range all {
+		status := ParseStatus(l.current)
+		var finishedAt interface{}
+		if status.Finished {
+			finishedAt = sqliteTime(l.updatedAt)
+		}
+		_, err := tx.Exec(`
+			UPDATE series SET latest_episode = ?, total_episodes = ?, finished_at = ?
+			WHERE id = ?
+		`, status.LatestEpisode, status.TotalEpisodes, finishedAt, l.id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 15: 剧集的行版本，用于 ETag 和乐观并发控制
+// 剧集返回的数据（包括集数、片单状态、观看进度、备注和标签）变化时由触发器递增，应用代码无需维护
+func migrateAddSeriesVersion(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE series ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+	CREATE TRIGGER series_version_update AFTER UPDATE ON series WHEN new.version = old.version BEGIN
+		UPDATE series SET version = version + 1 WHERE id = new.id;
+	END;
+
+	CREATE TRIGGER episodes_version_insert AFTER INSERT ON episodes BEGIN
+		UPDATE series SET version = version + 1 WHERE id = new.series_id;
+	END;
+	CREATE TRIGGER episodes_version_delete AFTER DELETE ON episodes BEGIN
+		UPDATE series SET version = version + 1 WHERE id = old.series_id;
+	END;
+
+	CREATE TRIGGER episode_watches_version_insert AFTER INSERT ON episode_watches BEGIN
+		UPDATE series SET version = version + 1
+		WHERE id = (SELECT series_id FROM episodes WHERE id = new.episode_id);
+	END;
+	CREATE TRIGGER episode_watches_version_delete AFTER DELETE ON episode_watches BEGIN
+		UPDATE series SET version = version + 1
+		WHERE id = (SELECT series_id FROM episodes WHERE id = old.episode_id);
+	END;
+
+	CREATE TRIGGER subscriptions_version_insert AFTER INSERT ON subscriptions BEGIN
+		UPDATE series SET version = version + 1 WHERE id = new.series_id;
+	END;
+	CREATE TRIGGER subscriptions_version_update AFTER UPDATE OF state ON subscriptions BEGIN
+		UPDATE series SET version = version + 1 WHERE id = new.series_id;
+	END;
+	CREATE TRIGGER subscriptions_version_delete AFTER DELETE ON subscriptions BEGIN
+		UPDATE series SET version = version + 1 WHERE id = old.series_id;
+	END;
+
+	CREATE TRIGGER series_notes_version_insert AFTER INSERT ON series_notes BEGIN
+		UPDATE series SET version = version + 1 WHERE id = new.series_id;
+	END;
+	CREATE TRIGGER series_notes_version_update AFTER UPDATE ON series_notes BEGIN
+		UPDATE series SET version = version + 1 WHERE id = new.series_id;
+	END;
+
+	CREATE TRIGGER series_tags_version_insert AFTER INSERT ON series_tags BEGIN
+		UPDATE series SET version = version + 1 WHERE id = new.series_id;
+	END;
+	CREATE TRIGGER series_tags_version_delete AFTER DELETE ON series_tags BEGIN
+		UPDATE series SET version = version + 1 WHERE id = old.series_id;
+	END;
+	CREATE TRIGGER tags_version_update AFTER UPDATE OF name ON tags BEGIN
+		UPDATE series SET version = version + 1
+		WHERE id IN (SELECT series_id FROM series_tags WHERE tag_id = new.id);
+	END;`)
+	return err
+}
+
+// 16: 剧集 URL 改为规范形式，见 NormalizeSeriesURL
+// 规范 URL 已被其他剧集占用的（重复剧集）保持不变，由 dedupe-series 命令合并；未删除的剧集和较早创建的剧集优先
+func migrateNormalizeSeriesURLs(tx *sql.Tx) error {
+	type legacy struct {
+		id  int64
+		url string
+	}
+
+	rows, err := tx.Query("SELECT id, url FROM series ORDER BY deleted_at IS NOT NULL, id")
+	if err != nil {
+		return err
+	}
+	var all []legacy
+	used := make(map[string]bool)
+	for rows.Next() {
+		var l legacy
+		if err := rows.Scan(&l.id, &l.url); err != nil {
+			rows.Close()
+			return err
+		}
+		all = append(all, l)
+		used[l.url] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	duplicates := 0
+	for _, l := range all {
+		canonical := NormalizeSeriesURL(l.url)
+		if canonical == l.url {
+			continue
+		}
+		if used[canonical] {
+			duplicates++
+			continue
+		}
+		if _, err := tx.Exec("UPDATE series SET url = ? WHERE id = ?", canonical, l.id); err != nil {
+			return err
+		}
+		delete(used, l.url)
+		used[canonical] = true
+	}
+	if duplicates > 0 {
+		log.Printf("⚠️ 发现 %d 个与其他剧集重复的 URL，可运行 dedupe-series 命令合并", duplicates)
+	}
+	return nil
+}
+
+// 17: 登录会话，令牌只保存 SHA-256 摘要；用户删除时会话随之删除
+func migrateCreateSessions(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token_hash TEXT NOT NULL UNIQUE,
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		last_used_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL
+	);
+	CREATE INDEX idx_sessions_user ON sessions(user_id);`)
+	return err
+}
+
+// 18: API 密钥，密钥只保存 SHA-256 摘要，scopes 为 JSON 数组；吊销后保留记录
+func migrateCreateAPIKeys(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE api_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name TEXT NOT NULL DEFAULT '',
+		prefix TEXT NOT NULL DEFAULT '',
+		key_hash TEXT NOT NULL UNIQUE,
+		scopes TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_used_at DATETIME,
+		revoked_at DATETIME
+	);`)
+	return err
+}
+
+// 19: 全文索引增加原名和备注，FTS5 不支持添加列，重建索引表和剧集上的触发器
+// 原名取用户填写的值，没有时取爬虫的值；备注为所有用户备注的合集，搜索时只匹配当前用户的备注
+func migrateAddSeriesFTSMetadata(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	DROP TRIGGER series_fts_insert;
+	DROP TRIGGER series_fts_update;
+	DROP TABLE series_fts;
+
+	CREATE VIRTUAL TABLE series_fts USING fts5(name, episode_titles, original_title, notes, tokenize = 'trigram');
+
+	INSERT INTO series_fts (rowid, name, episode_titles, original_title, notes)
+	SELECT id, name, COALESCE((
+		SELECT group_concat(title, char(10)) FROM episodes
+		WHERE series_id = series.id AND title != ''
+	), ''),
+	CASE WHEN original_title != '' THEN original_title ELSE crawler_original_title END,
+	COALESCE((
+		SELECT group_concat(notes, char(10)) FROM series_notes
+		WHERE series_id = series.id AND notes != ''
+	), '') FROM series;
+
+	CREATE TRIGGER series_fts_insert AFTER INSERT ON series BEGIN
+		INSERT INTO series_fts (rowid, name, episode_titles, original_title, notes)
+		VALUES (new.id, new.name, '',
+			CASE WHEN new.original_title != '' THEN new.original_title ELSE new.crawler_original_title END, '');
+	END;
+
+	CREATE TRIGGER series_fts_update AFTER UPDATE OF name, original_title, crawler_original_title ON series BEGIN
+		UPDATE series_fts SET name = new.name,
+			original_title = CASE WHEN new.original_title != '' THEN new.original_title ELSE new.crawler_original_title END
+		WHERE rowid = new.id;
+	END;
+
+	CREATE TRIGGER series_notes_fts_insert AFTER INSERT ON series_notes WHEN new.notes != '' BEGIN
+		UPDATE series_fts SET notes = (
+			SELECT group_concat(notes, char(10)) FROM series_notes
+			WHERE series_id = new.series_id AND notes != ''
+		) WHERE rowid = new.series_id;
+	END;
+
+	CREATE TRIGGER series_notes_fts_update AFTER UPDATE OF notes ON series_notes WHEN old.notes IS NOT new.notes BEGIN
+		UPDATE series_fts SET notes = COALESCE((
+			SELECT group_concat(notes, char(10)) FROM series_notes
+			WHERE series_id = new.series_id AND notes != ''
+		), '') WHERE rowid = new.series_id;
+	END;
+
+	CREATE TRIGGER series_notes_fts_delete AFTER DELETE ON series_notes WHEN old.notes != '' BEGIN
+		UPDATE series_fts SET notes = COALESCE((
+			SELECT group_concat(notes, char(10)) FROM series_notes
+			WHERE series_id = old.series_id AND notes != ''
+		), '') WHERE rowid = old.series_id;
+	END;`)
+	return err
+}
+
+// 20: 事件记录操作的用户，爬虫和系统事件为空；已有事件无法确定用户，保持为空
+func migrateAddSeriesEventsUserID(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE series_events ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+	CREATE INDEX idx_series_events_user_id ON series_events(user_id);`)
+	return err
+}
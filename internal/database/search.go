@@ -0,0 +1,187 @@
+package database
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// SearchHit 全文搜索命中的剧集
+type SearchHit struct {
+	Series    Series  `json:"series"`
+	Rank      float64 `json:"rank"`       // bm25 得分，越小越相关
+	Name      string  `json:"name"`       // 高亮后的名称（HTML，命中部分使用 <mark> 包裹）
+	Snippet   string  `json:"snippet"`    // 高亮后的集数标题片段（HTML），未命中时为空
+	MatchedBy string  `json:"matched_by"` // fts 或 substring
+}
+
+// trigram 分词要求查询至少 3 个字符，更短的查询退化为子串匹配
+const minFTSQueryLength = 3
+
+// 高亮标记使用控制字符，便于在 HTML 转义后替换为 <mark>
+const (
+	highlightOpen  = "\x02"
+	highlightClose = "\x03"
+)
+
+// ownNotesMatch 当前用户的备注包含查询词，参数为用户 ID 和查询词
+const ownNotesMatch = `EXISTS (
+	SELECT 1 FROM series_notes n
+	WHERE n.series_id = series.id AND n.user_id = ? AND instr(lower(n.notes), lower(?)) > 0)`
+
+// extraScanner 在 scanSeries 的字段之后继续扫描附加字段
+type extraScanner struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+func (s extraScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
+
+// SearchSeries 按名称、原名、集数原始标题和当前用户的备注搜索剧集（不含回收站），按相关度排序
+func (d *Database) SearchSeries(q string, limit int) ([]SearchHit, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return []SearchHit{}, nil
+	}
+
+	if utf8.RuneCountInString(q) < minFTSQueryLength {
+		return d.searchSeriesBySubstring(q, limit)
+	}
+
+	// 作为短语查询，避免用户输入被解析为 FTS5 语法
+	phrase := `"` + strings.ReplaceAll(q, `"`, `""`) + `"`
+	// 索引中的备注包含所有用户的备注，只命中备注的剧集需是当前用户的备注命中
+	shared := "{name episode_titles original_title} : " + phrase
+
+	rows, err := d.db.Query(`
+		SELECT `+d.seriesColumns()+`,
+			bm25(series_fts) AS rank,
+			highlight(series_fts, 0, ?, ?),
+			snippet(series_fts, 1, ?, ?, '…', 16)
+		FROM series_fts
+		JOIN series ON series.id = series_fts.rowid
+		WHERE series_fts MATCH ? AND series.deleted_at IS NULL AND (
+			series.id IN (SELECT rowid FROM series_fts WHERE series_fts MATCH ?) OR `+ownNotesMatch+`)
+		ORDER BY rank
+		LIMIT ?
+	`, highlightOpen, highlightClose, highlightOpen, highlightClose, phrase, shared, d.user(), q, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []SearchHit{}
+	for rows.Next() {
+		var hit SearchHit
+		s, err := scanSeries(extraScanner{row: rows, extra: []interface{}{&hit.Rank, &hit.Name, &hit.Snippet}})
+		if err != nil {
+			return nil, err
+		}
+		hit.Series = *s
+		hit.Name = renderHighlight(hit.Name)
+		hit.MatchedBy = "fts"
+		// snippet 未命中时只是标题开头，不作为结果返回
+		if strings.Contains(hit.Snippet, highlightOpen) {
+			hit.Snippet = renderHighlight(hit.Snippet)
+		} else {
+			hit.Snippet = ""
+		}
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	return hits, d.fillHitDetails(hits)
+}
+
+// searchSeriesBySubstring 短查询的子串匹配，按名称命中优先
+func (d *Database) searchSeriesBySubstring(q string, limit int) ([]SearchHit, error) {
+	series, err := d.querySeries(`
+		SELECT `+d.seriesColumns()+`
+		FROM series
+		WHERE series.deleted_at IS NULL AND (
+			instr(lower(series.name), lower(?)) > 0 OR
+			instr(lower(`+originalTitleColumn+`), lower(?)) > 0 OR EXISTS (
+				SELECT 1 FROM episodes e
+				WHERE e.series_id = series.id AND instr(lower(e.title), lower(?)) > 0) OR
+			`+ownNotesMatch+`)
+		ORDER BY instr(lower(series.name), lower(?)) = 0, series.updated_at DESC
+		LIMIT ?
+	`, q, q, q, d.user(), q, q, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]SearchHit, 0, len(series))
+	for _, s := range series {
+		hits = append(hits, SearchHit{
+			Series:    s,
+			Name:      highlightSubstring(s.Name, q),
+			MatchedBy: "substring",
+		})
+	}
+	return hits, nil
+}
+
+// fillHitDetails 填充命中剧集的关联数据
+func (d *Database) fillHitDetails(hits []SearchHit) error {
+	if len(hits) == 0 {
+		return nil
+	}
+
+	series := make([]Series, len(hits))
+	for i := range hits {
+		series[i] = hits[i].Series
+	}
+	if err := d.fillDetails(series); err != nil {
+		return err
+	}
+	for i := range hits {
+		hits[i].Series = series[i]
+	}
+	return nil
+}
+
+// renderHighlight 转义 HTML 并将高亮标记替换为 <mark>
+func renderHighlight(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, highlightOpen, "<mark>")
+	return strings.ReplaceAll(s, highlightClose, "</mark>")
+}
+
+// highlightSubstring 高亮第一个不区分大小写的子串
+func highlightSubstring(s, q string) string {
+	start, end := indexFold(s, q)
+	if start < 0 {
+		return html.EscapeString(s)
+	}
+	return renderHighlight(s[:start] + highlightOpen + s[start:end] + highlightClose + s[end:])
+}
+
+// indexFold 返回 q 在 s 中第一次出现（不区分大小写）的字节范围，没有时返回 -1, -1
+// 逐个字符比较，不依赖转换大小写后的字节位置（如 İ、K 转为小写后字节数会变化）
+func indexFold(s, q string) (int, int) {
+	if q == "" {
+		return -1, -1
+	}
+	for start := range s {
+		end, rest := start, q
+		for rest != "" && end < len(s) {
+			sr, ssize := utf8.DecodeRuneInString(s[end:])
+			qr, qsize := utf8.DecodeRuneInString(rest)
+			if !strings.EqualFold(string(sr), string(qr)) {
+				break
+			}
+			end += ssize
+			rest = rest[qsize:]
+		}
+		if rest == "" {
+			return start, end
+		}
+	}
+	return -1, -1
+}
@@ -0,0 +1,23 @@
+package database
+
+import "testing"
+
+func TestHighlightSubstring(t *testing.T) {
+	tests := []struct {
+		s, q, want string
+	}{
+		{"Breaking Bad", "bad", "Breaking <mark>Bad</mark>"},
+		{"Ärzte Serie", "ä", "<mark>Ä</mark>rzte Serie"},
+		// İ 和开尔文符号 K 转为小写后字节数变化
+		{"İİİabc", "abc", "İİİ<mark>abc</mark>"},
+		{"Kelvin İstanbul", "kel", "<mark>Kel</mark>vin İstanbul"},
+		{"İstanbul", "stan", "İ<mark>stan</mark>bul"},
+		{"Dark", "DARK 2", "Dark"},
+		{"<b>", "x", "&lt;b&gt;"},
+	}
+	for _, tt := range tests {
+		if got := highlightSubstring(tt.s, tt.q); got != tt.want {
+			t.Errorf("highlightSubstring(%q, %q) = %q，期望 %q", tt.s, tt.q, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"time"
+)
+
+// Session 登录会话，令牌只保存 SHA-256 摘要，过期时间随使用顺延
+type Session struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	TokenHash  string    `json:"-"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+const sessionColumns = `id, user_id, token_hash, user_agent, ip, created_at, last_used_at, expires_at`
+
+func scanSession(row rowScanner) (*Session, error) {
+	var s Session
+	if err := row.Scan(&s.ID, &s.UserID, &s.TokenHash, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// CreateSession 创建会话，时间精确到秒
+func (d *Database) CreateSession(session *Session) (*Session, error) {
+	result, err := d.db.Exec(`
+		INSERT INTO sessions (user_id, token_hash, user_agent, ip, created_at, last_used_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, session.UserID, session.TokenHash, session.UserAgent, session.IP,
+		sqliteTime(session.CreatedAt), sqliteTime(session.LastUsedAt), sqliteTime(session.ExpiresAt))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return scanSession(d.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = ?", id))
+}
+
+// GetSessionByToken 根据令牌摘要获取会话，不检查是否过期
+func (d *Database) GetSessionByToken(tokenHash string) (*Session, error) {
+	return scanSession(d.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE token_hash = ?", tokenHash))
+}
+
+// GetSessions 获取用户在 now 时未过期的会话，最近使用的在前
+func (d *Database) GetSessions(userID int64, now time.Time) ([]Session, error) {
+	rows, err := d.db.Query(`
+		SELECT `+sessionColumns+` FROM sessions
+		WHERE user_id = ? AND expires_at > ?
+		ORDER BY last_used_at DESC, id DESC
+	`, userID, sqliteTime(now))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *s)
+	}
+	return sessions, rows.Err()
+}
+
+// RenewSession 更新会话的最近使用时间和过期时间，会话不存在时返回 sql.ErrNoRows
+func (d *Database) RenewSession(id int64, lastUsedAt, expiresAt time.Time) error {
+	result, err := d.db.Exec("UPDATE sessions SET last_used_at = ?, expires_at = ? WHERE id = ?",
+		sqliteTime(lastUsedAt), sqliteTime(expiresAt), id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// DeleteSession 注销用户的会话，会话不存在或不属于该用户时返回 sql.ErrNoRows
+func (d *Database) DeleteSession(userID, id int64) error {
+	result, err := d.db.Exec("DELETE FROM sessions WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// DeleteUserSessions 注销用户除 exceptID 以外的所有会话，返回注销的数量
+func (d *Database) DeleteUserSessions(userID, exceptID int64) (int64, error) {
+	result, err := d.db.Exec("DELETE FROM sessions WHERE user_id = ? AND id != ?", userID, exceptID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PurgeSessions 删除在 before 之前过期的会话
+func (d *Database) PurgeSessions(before time.Time) (int64, error) {
+	result, err := d.db.Exec("DELETE FROM sessions WHERE expires_at <= ?", sqliteTime(before))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
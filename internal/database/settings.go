@@ -0,0 +1,375 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 配置值类型，所有配置均以字符串保存，类型决定校验规则和界面上的输入方式
+const (
+	SettingTypeString = "string"
+	SettingTypeInt    = "int"
+	SettingTypeBool   = "bool"
+	SettingTypeTime   = "time" // HH:mm
+	SettingTypeURL    = "url"
+)
+
+// 配置键
+const (
+	SettingCrawlerStartTime    = "crawler_start_time"
+	SettingCrawlerEndTime      = "crawler_end_time"
+	SettingSlackWebhookURL     = "slack_webhook_url"
+	SettingTrashRetentionDays  = "trash_retention_days"
+	SettingFinishedRecheckDays = "finished_recheck_days"
+)
+
+// DefaultTrashRetentionDays 回收站默认保留天数
+const DefaultTrashRetentionDays = 30
+
+// DefaultFinishedRecheckDays 已完结剧集默认的抓取间隔天数
+const DefaultFinishedRecheckDays = 7
+
+// SettingDef 配置项定义
+type SettingDef struct {
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	Default     string `json:"default"` // 未设置时使用的值
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+	Secret      bool   `json:"secret"` // 接口返回时以 SecretMask 代替实际值
+
+	validate func(value string) error // 类型校验之外的额外校验
+}
+
+// SecretMask 敏感配置在接口中的占位值，提交该值表示保持不变
+const SecretMask = "********"
+
+// settingDefs 配置注册表，新增配置只需在此追加定义，接口和界面按定义生成
+var settingDefs = []SettingDef{
+	{
+		Key:         SettingCrawlerStartTime,
+		Type:        SettingTypeTime,
+		Label:       "工作开始时间",
+		Description: "北京时间，开始和结束时间都设置后才限制爬虫工作时间",
+	},
+	{
+		Key:         SettingCrawlerEndTime,
+		Type:        SettingTypeTime,
+		Label:       "工作结束时间",
+		Description: "早于开始时间时视为跨天范围（例如 22:00 - 02:00）",
+	},
+	{
+		Key:    SettingSlackWebhookURL,
+		Type:   SettingTypeURL,
+		Label:  "Slack Webhook URL",
+		Secret: true,
+	},
+	{
+		Key:         SettingTrashRetentionDays,
+		Type:        SettingTypeInt,
+		Default:     strconv.Itoa(DefaultTrashRetentionDays),
+		Label:       "回收站保留天数",
+		Description: "0 表示不自动清理",
+		validate:    nonNegative,
+	},
+	{
+		Key:         SettingFinishedRecheckDays,
+		Type:        SettingTypeInt,
+		Default:     strconv.Itoa(DefaultFinishedRecheckDays),
+		Label:       "已完结剧集抓取间隔天数",
+		Description: "已完结的剧集每隔多少天抓取一次以发现新季，0 表示不再抓取",
+		validate:    nonNegative,
+	},
+}
+
+// SettingDefs 返回所有配置项定义，顺序即界面展示顺序
+func SettingDefs() []SettingDef {
+	defs := make([]SettingDef, len(settingDefs))
+	copy(defs, settingDefs)
+	return defs
+}
+
+// LookupSetting 按键查找配置项定义
+func LookupSetting(key string) (SettingDef, bool) {
+	for _, def := range settingDefs {
+		if def.Key == key {
+			return def, true
+		}
+	}
+	return SettingDef{}, false
+}
+
+// Normalize 校验并规范化配置值，空值表示恢复默认值
+func (def SettingDef) Normalize(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+
+	switch def.Type {
+	case SettingTypeInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", fmt.Errorf("%s 必须是整数", def.Label)
+		}
+		value = strconv.Itoa(n)
+	case SettingTypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%s 必须是 true 或 false", def.Label)
+		}
+		value = strconv.FormatBool(b)
+	case SettingTypeTime:
+		t, err := time.Parse("15:04", value)
+		if err != nil {
+			return "", fmt.Errorf("%s 格式不正确，请使用 HH:mm 格式", def.Label)
+		}
+		value = t.Format("15:04")
+	case SettingTypeURL:
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("%s 必须是 http 或 https 地址", def.Label)
+		}
+	}
+
+	if def.validate != nil {
+		if err := def.validate(value); err != nil {
+			return "", fmt.Errorf("%s %v", def.Label, err)
+		}
+	}
+	return value, nil
+}
+
+func nonNegative(value string) error {
+	if n, _ := strconv.Atoi(value); n < 0 {
+		return errors.New("不能为负数")
+	}
+	return nil
+}
+
+// Settings 全局配置值，只包含已保存的配置，未保存的键读取时使用默认值
+type Settings struct {
+	values map[string]string
+}
+
+// NewSettings 由已保存的配置值创建 Settings
+func NewSettings(values map[string]string) *Settings {
+	copied := make(map[string]string, len(values))
+	for key, value := range values {
+		if value != "" {
+			copied[key] = value
+		}
+	}
+	return &Settings{values: copied}
+}
+
+// Get 返回配置值，未设置时返回默认值
+func (s *Settings) Get(key string) string {
+	if value, ok := s.values[key]; ok {
+		return value
+	}
+	def, _ := LookupSetting(key)
+	return def.Default
+}
+
+// IsSet 配置是否已保存（而非使用默认值）
+func (s *Settings) IsSet(key string) bool {
+	_, ok := s.values[key]
+	return ok
+}
+
+// Int 返回整数配置，无法解析时使用默认值
+func (s *Settings) Int(key string) int {
+	if n, err := strconv.Atoi(s.Get(key)); err == nil {
+		return n
+	}
+	def, _ := LookupSetting(key)
+	n, _ := strconv.Atoi(def.Default)
+	return n
+}
+
+// Bool 返回布尔配置，无法解析时使用默认值
+func (s *Settings) Bool(key string) bool {
+	if b, err := strconv.ParseBool(s.Get(key)); err == nil {
+		return b
+	}
+	def, _ := LookupSetting(key)
+	b, _ := strconv.ParseBool(def.Default)
+	return b
+}
+
+// Values 返回所有已保存的配置值
+func (s *Settings) Values() map[string]string {
+	values := make(map[string]string, len(s.values))
+	for key, value := range s.values {
+		values[key] = value
+	}
+	return values
+}
+
+// TrashRetention 回收站保留天数，0 表示不自动清理
+func (s *Settings) TrashRetention() int {
+	days := s.Int(SettingTrashRetentionDays)
+	if days < 0 {
+		return DefaultTrashRetentionDays
+	}
+	return days
+}
+
+// FinishedRecheckDays 已完结剧集的抓取间隔天数，0 表示不再抓取
+func (s *Settings) FinishedRecheckDays() int {
+	days := s.Int(SettingFinishedRecheckDays)
+	if days < 0 {
+		return DefaultFinishedRecheckDays
+	}
+	return days
+}
+
+// SettingChange 一次配置变更记录，空值表示使用默认值
+type SettingChange struct {
+	ID        int64     `json:"id"`
+	Key       string    `json:"key"`
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
+	ChangedBy string    `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// sortedKeys 按键排序，保证变更记录的顺序稳定
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetSettings 获取全局配置
+func (d *Database) GetSettings() (*Settings, error) {
+	rows, err := d.db.Query("SELECT key, value FROM settings")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return NewSettings(values), nil
+}
+
+// UpdateSettings 保存配置并记录变更，值需已通过 SettingDef.Normalize 校验，空值删除配置以恢复默认值
+// 只有实际发生变化的键会被记录，返回本次的变更记录
+func (d *Database) UpdateSettings(values map[string]string, changedBy string) ([]SettingChange, error) {
+	tx, err := d.begin()
+	if err != nil {
+		return nil, err
+	}
+
+	changedAt := time.Now().UTC().Truncate(time.Second)
+	changes := []SettingChange{}
+	for _, key := range sortedKeys(values) {
+		value := values[key]
+
+		var old string
+		err := tx.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&old)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			tx.Rollback()
+			return nil, err
+		}
+		if old == value {
+			continue
+		}
+
+		if value == "" {
+			_, err = tx.Exec("DELETE FROM settings WHERE key = ?", key)
+		} else {
+			_, err = tx.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value)
+		}
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		result, err := tx.Exec(`
+			INSERT INTO settings_history (key, old_value, new_value, changed_by, changed_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, key, old, value, changedBy, sqliteTime(changedAt))
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		changes = append(changes, SettingChange{
+			ID:        id,
+			Key:       key,
+			OldValue:  old,
+			NewValue:  value,
+			ChangedBy: changedBy,
+			ChangedAt: changedAt,
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// GetSettingsHistory 获取配置变更记录，最新的在前，key 为空时返回所有配置的记录
+func (d *Database) GetSettingsHistory(key string, limit int) ([]SettingChange, error) {
+	rows, err := d.db.Query(`
+		SELECT id, key, old_value, new_value, changed_by, changed_at
+		FROM settings_history
+		WHERE ? = '' OR key = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, key, key, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []SettingChange{}
+	for rows.Next() {
+		var c SettingChange
+		if err := rows.Scan(&c.ID, &c.Key, &c.OldValue, &c.NewValue, &c.ChangedBy, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// GetSettingChange 获取单条配置变更记录
+func (d *Database) GetSettingChange(id int64) (*SettingChange, error) {
+	var c SettingChange
+	err := d.db.QueryRow(`
+		SELECT id, key, old_value, new_value, changed_by, changed_at
+		FROM settings_history WHERE id = ?
+	`, id).Scan(&c.ID, &c.Key, &c.OldValue, &c.NewValue, &c.ChangedBy, &c.ChangedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// 剧集状态，按用户保存，空值表示该剧集不在用户的片单中
+const (
+	StatePlanned   = "planned"   // 想看
+	StateWatching  = "watching"  // 在看
+	StatePaused    = "paused"    // 暂停
+	StateWaiting   = "waiting"   // 等待新季
+	StateCompleted = "completed" // 看完
+	StateDropped   = "dropped"   // 弃剧
+)
+
+// SeriesStates 所有剧集状态，顺序即界面展示顺序
+var SeriesStates = []string{StatePlanned, StateWatching, StatePaused, StateWaiting, StateCompleted, StateDropped}
+
+// TrackingStates 需要爬虫抓取更新、发送通知的状态，即界面上的“正在追踪”
+var TrackingStates = []string{StateWatching, StateWaiting, StatePlanned}
+
+// IsValidSeriesState 检查状态是否受支持，空值表示移出片单，同样有效
+func IsValidSeriesState(state string) bool {
+	return state == "" || containsState(SeriesStates, state)
+}
+
+// IsTrackingState 该状态是否需要爬虫抓取
+func IsTrackingState(state string) bool {
+	return containsState(TrackingStates, state)
+}
+
+func containsState(states []string, state string) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// NonTrackingStates 不需要爬虫抓取的状态，包括不在片单中的空状态
+func NonTrackingStates() []string {
+	states := []string{""}
+	for _, s := range SeriesStates {
+		if !IsTrackingState(s) {
+			states = append(states, s)
+		}
+	}
+	return states
+}
+
+// LegacyTrackingState 旧版本追踪开关对应的状态，与迁移 13 的转换规则一致
+func LegacyTrackingState(tracking bool) string {
+	if tracking {
+		return StateWatching
+	}
+	return StatePaused
+}
+
+// stateColumn 当前用户的剧集状态
+const stateColumn = `COALESCE((SELECT sub.state FROM subscriptions sub
+	WHERE sub.series_id = series.id AND sub.user_id = %d), '')`
+
+// stateListSQL 生成状态列表的 SQL 字面量，状态均为固定常量，可直接拼接
+func stateListSQL(states []string) string {
+	quoted := make([]string, len(states))
+	for i, s := range states {
+		quoted[i] = "'" + s + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// SetSeriesState 设置当前用户的剧集状态，空值移出片单，剧集不存在时返回 sql.ErrNoRows
+func (d *Database) SetSeriesState(id int64, state string) error {
+	if state == "" {
+		var exists bool
+		if err := d.db.QueryRow("SELECT EXISTS (SELECT 1 FROM series WHERE id = ?)", id).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+		_, err := d.db.Exec("DELETE FROM subscriptions WHERE user_id = ? AND series_id = ?", d.user(), id)
+		return err
+	}
+
+	result, err := d.db.Exec(`
+		INSERT INTO subscriptions (user_id, series_id, state, state_changed_at)
+		SELECT ?, id, ?, CURRENT_TIMESTAMP FROM series WHERE id = ?
+		ON CONFLICT (user_id, series_id) DO UPDATE SET
+			state = excluded.state,
+			state_changed_at = CASE WHEN subscriptions.state != excluded.state
+				THEN CURRENT_TIMESTAMP ELSE subscriptions.state_changed_at END
+	`, d.user(), state, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
@@ -0,0 +1,287 @@
+package database
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// statsTopSeries 最活跃剧集的返回数量
+const statsTopSeries = 10
+
+// Stats 统计数据，剧集状态和未看集数按当前用户统计，其余为所有用户共享的数据
+type Stats struct {
+	SeriesCount       int               `json:"series_count"`       // 剧集库中的剧集数（不含回收站）
+	States            map[string]int    `json:"states"`             // 各状态的剧集数，none 为不在片单中
+	UnwatchedEpisodes int               `json:"unwatched_episodes"` // 正在追踪的剧集中未看的集数
+	UnwatchedSeries   int               `json:"unwatched_series"`   // 有未看集数的正在追踪的剧集数
+	WeeklyEpisodes    []WeeklyCount     `json:"weekly_episodes"`    // 最近 N 周每周发现的集数，最早的一周在前
+	MostActive        []SeriesActivity  `json:"most_active"`        // 最近 N 周发现集数最多的剧集
+	EpisodeIntervals  []EpisodeInterval `json:"episode_intervals"`  // 相邻两集首次出现的平均间隔，更新最快的在前
+	Crawler           CrawlerStats      `json:"crawler"`            // 最近 N 周的爬虫记录
+
+	now time.Time // 统计周期的结束时间，精确到秒
+}
+
+// WeeklyCount 一周内发现的集数，一周从 WeekStart 起算 7 天
+type WeeklyCount struct {
+	WeekStart time.Time `json:"week_start"`
+	Count     int       `json:"count"`
+}
+
+// SeriesActivity 剧集在统计周期内发现的集数
+type SeriesActivity struct {
+	SeriesID int64  `json:"series_id"`
+	Name     string `json:"name"`
+	Episodes int    `json:"episodes"`
+}
+
+// EpisodeInterval 剧集的平均更新间隔，只统计至少有两集且首次出现时间不同的剧集
+type EpisodeInterval struct {
+	SeriesID    int64   `json:"series_id"`
+	Name        string  `json:"name"`
+	Episodes    int     `json:"episodes"`
+	AverageDays float64 `json:"average_days"` // 保留一位小数
+}
+
+// CrawlerStats 爬虫回调及 URL 处理结果统计
+type CrawlerStats struct {
+	Runs        int     `json:"runs"`
+	FailedRuns  int     `json:"failed_runs"` // 爬虫上报失败的回调
+	Items       int     `json:"items"`
+	Succeeded   int     `json:"succeeded"`    // 新集数、摘要变化或没有变化
+	SuccessRate float64 `json:"success_rate"` // Succeeded / Items，没有记录时为 0
+}
+
+// statsEpisode 统计用的集数信息
+type statsEpisode struct {
+	seriesID    int64
+	name        string
+	firstSeenAt time.Time
+}
+
+// isCrawlSuccess 该 URL 是否爬取并处理成功
+func isCrawlSuccess(outcome string) bool {
+	switch outcome {
+	case CrawlOutcomeNewEpisodes, CrawlOutcomeStatusChanged, CrawlOutcomeUnchanged:
+		return true
+	}
+	return false
+}
+
+// newStats 创建统计结果，按 now 往前划分 weeks 个 7 天的区间
+func newStats(now time.Time, weeks int) *Stats {
+	stats := &Stats{
+		States:           map[string]int{"none": 0},
+		WeeklyEpisodes:   make([]WeeklyCount, weeks),
+		MostActive:       []SeriesActivity{},
+		EpisodeIntervals: []EpisodeInterval{},
+		now:              now.UTC().Truncate(time.Second),
+	}
+	for _, state := range SeriesStates {
+		stats.States[state] = 0
+	}
+
+	for i := range stats.WeeklyEpisodes {
+		stats.WeeklyEpisodes[i].WeekStart = stats.now.AddDate(0, 0, -7*(weeks-i))
+	}
+	return stats
+}
+
+// since 统计周期的开始时间
+func (s *Stats) since() time.Time {
+	if len(s.WeeklyEpisodes) == 0 {
+		return s.now
+	}
+	return s.WeeklyEpisodes[0].WeekStart
+}
+
+// inPeriod 时间是否在统计周期内
+func (s *Stats) inPeriod(t time.Time) bool {
+	return !t.Before(s.since()) && !t.After(s.now)
+}
+
+// finishStates 由剧集总数和片单中的剧集数得出不在片单中的剧集数
+func (s *Stats) finishStates() {
+	inList := 0
+	for state, count := range s.States {
+		if state != "none" {
+			inList += count
+		}
+	}
+	s.States["none"] = s.SeriesCount - inList
+}
+
+// addEpisodes 汇总集数的周发现数、最活跃剧集和平均更新间隔
+func (s *Stats) addEpisodes(episodes []statsEpisode) {
+	since := s.since()
+
+	type span struct {
+		name        string
+		count       int
+		first, last time.Time
+		recent      int
+	}
+	spans := make(map[int64]*span)
+	for _, e := range episodes {
+		sp := spans[e.seriesID]
+		if sp == nil {
+			sp = &span{name: e.name, first: e.firstSeenAt, last: e.firstSeenAt}
+			spans[e.seriesID] = sp
+		}
+		sp.count++
+		if e.firstSeenAt.Before(sp.first) {
+			sp.first = e.firstSeenAt
+		}
+		if e.firstSeenAt.After(sp.last) {
+			sp.last = e.firstSeenAt
+		}
+
+		if len(s.WeeklyEpisodes) == 0 || !s.inPeriod(e.firstSeenAt) {
+			continue
+		}
+		week := min(int(e.firstSeenAt.Sub(since)/(7*24*time.Hour)), len(s.WeeklyEpisodes)-1)
+		s.WeeklyEpisodes[week].Count++
+		sp.recent++
+	}
+
+	for id, sp := range spans {
+		if sp.recent > 0 {
+			s.MostActive = append(s.MostActive, SeriesActivity{SeriesID: id, Name: sp.name, Episodes: sp.recent})
+		}
+		if sp.count >= 2 && sp.last.After(sp.first) {
+			days := sp.last.Sub(sp.first).Hours() / 24 / float64(sp.count-1)
+			s.EpisodeIntervals = append(s.EpisodeIntervals, EpisodeInterval{
+				SeriesID:    id,
+				Name:        sp.name,
+				Episodes:    sp.count,
+				AverageDays: math.Round(days*10) / 10,
+			})
+		}
+	}
+
+	sort.Slice(s.MostActive, func(i, j int) bool {
+		a, b := s.MostActive[i], s.MostActive[j]
+		if a.Episodes != b.Episodes {
+			return a.Episodes > b.Episodes
+		}
+		return a.SeriesID < b.SeriesID
+	})
+	if len(s.MostActive) > statsTopSeries {
+		s.MostActive = s.MostActive[:statsTopSeries]
+	}
+	sort.Slice(s.EpisodeIntervals, func(i, j int) bool {
+		a, b := s.EpisodeIntervals[i], s.EpisodeIntervals[j]
+		if a.AverageDays != b.AverageDays {
+			return a.AverageDays < b.AverageDays
+		}
+		return a.SeriesID < b.SeriesID
+	})
+}
+
+// addCrawlItems 累加 URL 处理结果
+func (s *Stats) addCrawlItems(outcome string, count int) {
+	s.Crawler.Items += count
+	if isCrawlSuccess(outcome) {
+		s.Crawler.Succeeded += count
+	}
+	if s.Crawler.Items > 0 {
+		s.Crawler.SuccessRate = math.Round(float64(s.Crawler.Succeeded)/float64(s.Crawler.Items)*1000) / 1000
+	}
+}
+
+// GetStats 统计剧集库和爬虫数据，周期为 now 之前的 weeks 周
+func (d *Database) GetStats(now time.Time, weeks int) (*Stats, error) {
+	stats := newStats(now, weeks)
+	since, until := sqliteTime(stats.since()), sqliteTime(stats.now)
+
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM series WHERE deleted_at IS NULL").Scan(&stats.SeriesCount); err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.Query(`
+		SELECT sub.state, COUNT(*)
+		FROM subscriptions sub JOIN series ON series.id = sub.series_id
+		WHERE sub.user_id = ? AND series.deleted_at IS NULL
+		GROUP BY sub.state
+	`, d.user())
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var state string
+		var count int
+		if err := rows.Scan(&state, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.States[state] = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	stats.finishStates()
+
+	err = d.db.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT e.series_id)
+		FROM episodes e
+		JOIN series ON series.id = e.series_id
+		JOIN subscriptions sub ON sub.series_id = e.series_id AND sub.user_id = ?
+		WHERE series.deleted_at IS NULL AND sub.state IN (`+stateListSQL(TrackingStates)+`)
+			AND NOT EXISTS (SELECT 1 FROM episode_watches w WHERE w.episode_id = e.id AND w.user_id = ?)
+	`, d.user(), d.user()).Scan(&stats.UnwatchedEpisodes, &stats.UnwatchedSeries)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = d.db.Query(`
+		SELECT e.series_id, series.name, e.first_seen_at
+		FROM episodes e JOIN series ON series.id = e.series_id
+		WHERE series.deleted_at IS NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var episodes []statsEpisode
+	for rows.Next() {
+		var e statsEpisode
+		if err := rows.Scan(&e.seriesID, &e.name, &e.firstSeenAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		episodes = append(episodes, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	stats.addEpisodes(episodes)
+
+	err = d.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(status < 0), 0) FROM crawl_runs WHERE created_at BETWEEN ? AND ?
+	`, since, until).Scan(&stats.Crawler.Runs, &stats.Crawler.FailedRuns)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = d.db.Query(`
+		SELECT i.outcome, COUNT(*)
+		FROM crawl_run_items i JOIN crawl_runs r ON r.id = i.run_id
+		WHERE r.created_at BETWEEN ? AND ?
+		GROUP BY i.outcome
+	`, since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var outcome string
+		var count int
+		if err := rows.Scan(&outcome, &count); err != nil {
+			return nil, err
+		}
+		stats.addCrawlItems(outcome, count)
+	}
+	return stats, rows.Err()
+}
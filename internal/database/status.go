@@ -0,0 +1,64 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// SeriesStatus 由爬虫上报的状态文本（如“已更新到 S02E08”“已完结”）解析出的结构化信息
+type SeriesStatus struct {
+	LatestEpisode string `json:"latest_episode"` // 最新一集 "S02E08"，无法解析时为空
+	TotalEpisodes int    `json:"total_episodes"` // 总集数，页面未提供时为 0
+	Finished      bool   `json:"is_finished"`    // 是否已完结
+}
+
+var (
+	statusEpisodeRegex  = regexp.MustCompile(`(?i)S(\d+)\s*E(\d+)`)
+	statusNumberRegex   = regexp.MustCompile(`(?:第|更新至|更新到)\s*(\d+)\s*集`)
+	statusTotalRegex    = regexp.MustCompile(`([共全])\s*(\d+)\s*集`)
+	statusFinishedRegex = regexp.MustCompile(`(?i)已完结|^\s*完结|全剧终|大结局|\bended\b|\bcompleted\b`)
+)
+
+// ParseStatus 解析状态文本，只有“第 8 集”时视为第一季
+//
+// 文本包含“已完结”“全剧终”等字样或以“完结”开头，或最新一集已达到总集数时视为已完结，
+// “未完结”“即将完结”“下周完结”等不算；
+// 只有“全 12 集”而没有最新一集时，也视为已完结。
+func ParseStatus(text string) SeriesStatus {
+	var status SeriesStatus
+	number := 0
+	if m := statusEpisodeRegex.FindStringSubmatch(text); m != nil {
+		season, _ := strconv.Atoi(m[1])
+		number, _ = strconv.Atoi(m[2])
+		status.LatestEpisode = fmt.Sprintf("S%02dE%02d", season, number)
+	} else if m := statusNumberRegex.FindStringSubmatch(text); m != nil {
+		number, _ = strconv.Atoi(m[1])
+		status.LatestEpisode = fmt.Sprintf("S01E%02d", number)
+	}
+
+	allReleased := false
+	if m := statusTotalRegex.FindStringSubmatch(text); m != nil {
+		status.TotalEpisodes, _ = strconv.Atoi(m[2])
+		allReleased = m[1] == "全" && status.LatestEpisode == ""
+	}
+
+	status.Finished = statusFinishedRegex.MatchString(text) || allReleased ||
+		(status.TotalEpisodes > 0 && number >= status.TotalEpisodes)
+	return status
+}
+
+// statusColumns 状态查询字段，顺序与 scanSeries 一致
+const statusColumns = `series.latest_episode, series.total_episodes, series.finished_at`
+
+// setSeriesStatus 按状态文本更新解析结果：已完结时保留首次完结的时间，未完结时清空
+func setSeriesStatus(tx conn, id int64, current string) error {
+	status := ParseStatus(current)
+	_, err := tx.Exec(`
+		UPDATE series
+		SET latest_episode = ?, total_episodes = ?,
+			finished_at = CASE WHEN ? THEN COALESCE(finished_at, CURRENT_TIMESTAMP) ELSE NULL END
+		WHERE id = ?
+	`, status.LatestEpisode, status.TotalEpisodes, status.Finished, id)
+	return err
+}
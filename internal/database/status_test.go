@@ -0,0 +1,29 @@
+package database
+
+import "testing"
+
+func TestParseStatusFinished(t *testing.T) {
+	tests := []struct {
+		text     string
+		finished bool
+	}{
+		{"已完结", true},
+		{"完结", true},
+		{"完结 全 12 集", true},
+		{"更新至 12 集 已完结", true},
+		{"全剧终", true},
+		{"Ended", true},
+		{"未完结", false},
+		{"即将完结", false},
+		{"将完结", false},
+		{"下周完结", false},
+		{"更新至 8 集，即将完结", false},
+		{"已更新到 S02E08", false},
+		{"更新至 12 集 共 12 集", true},
+	}
+	for _, tt := range tests {
+		if got := ParseStatus(tt.text).Finished; got != tt.finished {
+			t.Errorf("ParseStatus(%q).Finished = %v，期望 %v", tt.text, got, tt.finished)
+		}
+	}
+}
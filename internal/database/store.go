@@ -0,0 +1,117 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// Store 数据存储接口，*Database 为 SQLite 实现，*MemoryStore 为内存实现
+//
+// 实现需遵循相同的约定：记录不存在时返回 sql.ErrNoRows，唯一约束冲突可由 IsUniqueViolation 识别，
+// storetest 包提供了两者共用的一致性测试。
+//
+// 追踪状态和观看进度按用户保存，通过 ForUser 获取以某个用户身份操作的存储，未指定时为管理员。
+type Store interface {
+	ForUser(userID int64) Store
+	Transaction(fn func(tx Store) error) error
+
+	// 剧集
+	CreateSeries(name, url string) (*Series, error)
+	GetSeriesByID(id int64) (*Series, error)
+	GetSeriesByURL(url string) (*Series, error)
+	GetAllSeries() ([]Series, error)
+	ListSeries(q SeriesQuery) ([]Series, int, error)
+	UpdateSeries(id int64, name, url string) error
+	DeleteSeries(id int64) error
+	MarkAsWatched(id int64) error
+	MarkAsUnwatched(id int64) error
+	SetSeriesState(id int64, state string) error
+	ClearSeriesHistory(id int64) error
+	UpdateSeriesMetadata(id int64, patch SeriesMetadataPatch) error
+	UpdateSeriesNotes(id int64, notes string, rating int) error
+	MergeSeries(targetID, sourceID int64) error
+
+	// 爬虫
+	GetAllTrackingURLs(recheckFinishedBefore time.Time) ([]string, error)
+	UpdateSeriesInfo(url string, current string, episodes []FetchEpisode) error
+	UpdateSeriesCrawlerLastSeen(url string, lastSeen time.Time) error
+	UpdateSeriesCrawlerMetadata(url, posterURL, originalTitle string) error
+	CreateCrawlRun(run *CrawlRun) (int64, error)
+	GetCrawlRuns(limit int) ([]CrawlRun, error)
+	GetCrawlRun(id int64) (*CrawlRun, error)
+
+	// 集数
+	GetEpisodes(seriesID int64) ([]Episode, error)
+	MarkWatchedUntil(seriesID int64, code string) error
+	SetEpisodeWatched(seriesID int64, code string, watched bool) error
+	TouchEpisodes(seriesID int64, episodes []FetchEpisode, seenAt time.Time) error
+
+	// 事件
+	CreateSeriesEvent(seriesID int64, eventType, source string, detail interface{}) error
+	GetSeriesEvents(seriesID int64, limit int) ([]SeriesEvent, error)
+	GetEvents(since time.Time, limit int) ([]SeriesEvent, error)
+
+	// 回收站
+	GetTrashedSeries() ([]Series, error)
+	RestoreSeries(id int64) error
+	PurgeSeries(id int64) error
+	PurgeTrash(before time.Time) (int64, error)
+
+	// 标签
+	GetAllTags() ([]Tag, error)
+	GetTagByID(id int64) (*Tag, error)
+	CreateTag(name, color string) (*Tag, error)
+	UpdateTag(id int64, name, color string) error
+	DeleteTag(id int64) error
+	SetSeriesTags(seriesID int64, names []string) error
+
+	// 导入
+	ImportSeries(record SeriesRecord) (int64, error)
+
+	// 搜索
+	SearchSeries(q string, limit int) ([]SearchHit, error)
+
+	// 统计
+	GetStats(now time.Time, weeks int) (*Stats, error)
+
+	// 用户
+	GetUsers() ([]User, error)
+	GetUserByID(id int64) (*User, error)
+	GetUserByName(username string) (*User, error)
+	CreateUser(user *User) (*User, error)
+	UpdateUser(user *User) error
+	DeleteUser(id int64) error
+	GetSubscribers(seriesID int64) ([]User, error)
+
+	// 会话
+	CreateSession(session *Session) (*Session, error)
+	GetSessionByToken(tokenHash string) (*Session, error)
+	GetSessions(userID int64, now time.Time) ([]Session, error)
+	RenewSession(id int64, lastUsedAt, expiresAt time.Time) error
+	DeleteSession(userID, id int64) error
+	DeleteUserSessions(userID, exceptID int64) (int64, error)
+	PurgeSessions(before time.Time) (int64, error)
+
+	// API 密钥
+	CreateAPIKey(key *APIKey) (*APIKey, error)
+	GetAPIKeys() ([]APIKey, error)
+	GetAPIKeyByHash(keyHash string) (*APIKey, error)
+	TouchAPIKey(id int64, usedAt time.Time) error
+	RevokeAPIKey(id int64, revokedAt time.Time) error
+
+	// 配置
+	GetSettings() (*Settings, error)
+	UpdateSettings(values map[string]string, changedBy string) ([]SettingChange, error)
+	GetSettingsHistory(key string, limit int) ([]SettingChange, error)
+	GetSettingChange(id int64) (*SettingChange, error)
+
+	Close() error
+}
+
+var (
+	_ Store = (*Database)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+// ErrUniqueViolation 唯一约束冲突，供非 SQLite 实现使用
+var ErrUniqueViolation = errors.New("唯一约束冲突")
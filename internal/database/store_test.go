@@ -0,0 +1,26 @@
+//go:build sqlite_fts5
+
+package database_test
+
+import (
+	"testing"
+
+	"mini-catch/internal/database"
+	"mini-catch/internal/database/storetest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) database.Store {
+		return database.NewMemoryStore()
+	})
+}
+
+func TestSQLiteStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) database.Store {
+		db, err := database.NewDatabase(t.TempDir() + "/test.db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
@@ -0,0 +1,1394 @@
+// Package storetest 提供 database.Store 的一致性测试，SQLite 与内存实现共用同一套用例
+//
+// 使用方式：
+//
+//	func TestMemoryStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) database.Store {
+//			return database.NewMemoryStore()
+//		})
+//	}
+package storetest
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"mini-catch/internal/database"
+)
+
+// Run 运行全部一致性测试，每个子测试都会调用 newStore 创建一个空的存储
+func Run(t *testing.T, newStore func(t *testing.T) database.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s database.Store)
+	}{
+		{"Series", testSeries},
+		{"FetchUpdates", testFetchUpdates},
+		{"WatchProgress", testWatchProgress},
+		{"Trash", testTrash},
+		{"Tags", testTags},
+		{"ListSeries", testListSeries},
+		{"Events", testEvents},
+		{"CrawlRuns", testCrawlRuns},
+		{"Import", testImport},
+		{"Search", testSearch},
+		{"Settings", testSettings},
+		{"Users", testUsers},
+		{"PerUserState", testPerUserState},
+		{"Metadata", testMetadata},
+		{"States", testStates},
+		{"SeriesStatus", testSeriesStatus},
+		{"Stats", testStats},
+		{"Transaction", testTransaction},
+		{"Version", testVersion},
+		{"URLNormalization", testURLNormalization},
+		{"MergeSeries", testMergeSeries},
+		{"Sessions", testSessions},
+		{"APIKeys", testAPIKeys},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(t)
+			t.Cleanup(func() { s.Close() })
+			tt.fn(t, s)
+		})
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustNoRows(t *testing.T, err error) {
+	t.Helper()
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("期望 sql.ErrNoRows，实际为 %v", err)
+	}
+}
+
+func mustCreate(t *testing.T, s database.Store, name, url string) *database.Series {
+	t.Helper()
+	series, err := s.CreateSeries(name, url)
+	must(t, err)
+	return series
+}
+
+func mustGet(t *testing.T, s database.Store, id int64) *database.Series {
+	t.Helper()
+	series, err := s.GetSeriesByID(id)
+	must(t, err)
+	return series
+}
+
+func episodes(codes ...string) []database.FetchEpisode {
+	var eps []database.FetchEpisode
+	for _, code := range codes {
+		eps = append(eps, database.FetchEpisode{Code: code})
+	}
+	return eps
+}
+
+func names(series []database.Series) []string {
+	result := []string{}
+	for _, s := range series {
+		result = append(result, s.Name)
+	}
+	return result
+}
+
+func statePtr(state string) *string {
+	return &state
+}
+
+func equal(t *testing.T, what string, got, want interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("%s: 期望 %v，实际为 %v", what, want, got)
+	}
+}
+
+func testSeries(t *testing.T, s database.Store) {
+	created := mustCreate(t, s, "黑镜", "https://example.com/1")
+	if created.ID == 0 || created.State != database.StateWatching || created.IsWatched || created.Current != "" {
+		t.Fatalf("新建剧集字段不正确: %+v", created)
+	}
+	equal(t, "history", created.History, []string{})
+	equal(t, "tags", created.Tags, []string{})
+
+	byURL, err := s.GetSeriesByURL("https://example.com/1")
+	must(t, err)
+	equal(t, "id", byURL.ID, created.ID)
+
+	_, err = s.CreateSeries("重复", "https://example.com/1")
+	if !database.IsUniqueViolation(err) {
+		t.Fatalf("重复 URL 应返回唯一约束冲突，实际为 %v", err)
+	}
+
+	_, err = s.GetSeriesByID(created.ID + 100)
+	mustNoRows(t, err)
+	_, err = s.GetSeriesByURL("https://example.com/none")
+	mustNoRows(t, err)
+
+	other := mustCreate(t, s, "西部世界", "https://example.com/2")
+	must(t, s.UpdateSeries(created.ID, "黑镜 第六季", "https://example.com/1a"))
+	got := mustGet(t, s, created.ID)
+	equal(t, "name", got.Name, "黑镜 第六季")
+	equal(t, "url", got.URL, "https://example.com/1a")
+
+	err = s.UpdateSeries(created.ID, "黑镜", other.URL)
+	if !database.IsUniqueViolation(err) {
+		t.Fatalf("更新为已存在的 URL 应返回唯一约束冲突，实际为 %v", err)
+	}
+
+	must(t, s.SetSeriesState(other.ID, database.StateDropped))
+	equal(t, "state", mustGet(t, s, other.ID).State, database.StateDropped)
+	urls, err := s.GetAllTrackingURLs(time.Time{})
+	must(t, err)
+	equal(t, "tracking urls", urls, []string{"https://example.com/1a"})
+}
+
+func testFetchUpdates(t *testing.T, s database.Store) {
+	series := mustCreate(t, s, "三体", "https://example.com/1")
+
+	err := s.UpdateSeriesInfo("https://example.com/none", "S01E01", episodes("S01E01"))
+	mustNoRows(t, err)
+
+	must(t, s.UpdateSeriesInfo(series.URL, "更新至第 10 集", []database.FetchEpisode{
+		{Code: "S01E10", Title: "三体.S01E10.4K"},
+		{Code: "S01E02", Title: "三体.S01E02.4K"},
+		{Code: "S01E01"},
+	}))
+	got := mustGet(t, s, series.ID)
+	equal(t, "current", got.Current, "更新至第 10 集")
+	equal(t, "history", got.History, []string{"S01E01", "S01E02", "S01E10"})
+	equal(t, "unwatched", got.UnwatchedCount, 3)
+	equal(t, "next unwatched", got.NextUnwatched, "S01E01")
+	if got.CrawlerLastSeen == nil {
+		t.Fatal("更新后应记录爬虫最后更新时间")
+	}
+
+	// 再次出现的集数不重复插入，空标题不覆盖已有标题
+	must(t, s.TouchEpisodes(series.ID, []database.FetchEpisode{
+		{Code: "S01E02"},
+		{Code: "S01E11", Title: "三体.S01E11.4K"},
+	}, time.Now()))
+	eps, err := s.GetEpisodes(series.ID)
+	must(t, err)
+	equal(t, "episode count", len(eps), 4)
+	equal(t, "title", eps[1].Title, "三体.S01E02.4K")
+	equal(t, "season", eps[3].Season, 1)
+	equal(t, "number", eps[3].Number, 11)
+	if eps[1].LastSeenAt.Before(eps[1].FirstSeenAt) {
+		t.Fatal("last_seen_at 不应早于 first_seen_at")
+	}
+
+	lastSeen := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	must(t, s.UpdateSeriesCrawlerLastSeen(series.URL, lastSeen))
+	got = mustGet(t, s, series.ID)
+	if got.CrawlerLastSeen == nil || !got.CrawlerLastSeen.Equal(lastSeen) {
+		t.Fatalf("crawler_last_seen: 期望 %v，实际为 %v", lastSeen, got.CrawlerLastSeen)
+	}
+}
+
+func testWatchProgress(t *testing.T, s database.Store) {
+	series := mustCreate(t, s, "漫长的季节", "https://example.com/1")
+	must(t, s.UpdateSeriesInfo(series.URL, "", episodes("S01E01", "S01E02", "S01E03", "S02E01")))
+
+	must(t, s.MarkWatchedUntil(series.ID, "S01E02"))
+	got := mustGet(t, s, series.ID)
+	equal(t, "unwatched", got.UnwatchedCount, 2)
+	equal(t, "next unwatched", got.NextUnwatched, "S01E03")
+
+	// 进度回退时之后的集数变为未看
+	must(t, s.MarkWatchedUntil(series.ID, "S01E01"))
+	equal(t, "next unwatched", mustGet(t, s, series.ID).NextUnwatched, "S01E02")
+
+	if err := s.MarkWatchedUntil(series.ID, "第一集"); err == nil {
+		t.Fatal("无效的集数编号应返回错误")
+	}
+	mustNoRows(t, s.MarkWatchedUntil(series.ID, "S09E09"))
+	mustNoRows(t, s.MarkWatchedUntil(series.ID+100, "S01E01"))
+	equal(t, "unchanged progress", mustGet(t, s, series.ID).NextUnwatched, "S01E02")
+
+	must(t, s.SetEpisodeWatched(series.ID, "S02E01", true))
+	mustNoRows(t, s.SetEpisodeWatched(series.ID, "S09E09", true))
+	eps, err := s.GetEpisodes(series.ID)
+	must(t, err)
+	if eps[3].WatchedAt == nil || eps[2].WatchedAt != nil {
+		t.Fatal("单集观看状态不正确")
+	}
+
+	must(t, s.MarkAsWatched(series.ID))
+	got = mustGet(t, s, series.ID)
+	if !got.IsWatched || got.UnwatchedCount != 0 || got.NextUnwatched != "" {
+		t.Fatalf("全部标记为已看后状态不正确: %+v", got)
+	}
+
+	must(t, s.MarkAsUnwatched(series.ID))
+	got = mustGet(t, s, series.ID)
+	equal(t, "next unwatched", got.NextUnwatched, "S02E01")
+	if got.IsWatched {
+		t.Fatal("最新一集未看时不应为已看")
+	}
+
+	must(t, s.ClearSeriesHistory(series.ID))
+	got = mustGet(t, s, series.ID)
+	equal(t, "history", got.History, []string{})
+	if got.IsWatched {
+		t.Fatal("没有集数时应视为未看")
+	}
+}
+
+func testTrash(t *testing.T, s database.Store) {
+	kept := mustCreate(t, s, "保留", "https://example.com/1")
+	trashed := mustCreate(t, s, "删除", "https://example.com/2")
+	must(t, s.UpdateSeriesInfo(trashed.URL, "", episodes("S01E01")))
+	must(t, s.CreateSeriesEvent(trashed.ID, database.EventDeleted, database.EventSourceUser, nil))
+
+	must(t, s.DeleteSeries(trashed.ID))
+	all, err := s.GetAllSeries()
+	must(t, err)
+	equal(t, "series", names(all), []string{"保留"})
+
+	urls, err := s.GetAllTrackingURLs(time.Time{})
+	must(t, err)
+	equal(t, "tracking urls", urls, []string{kept.URL})
+
+	_, err = s.GetSeriesByURL(trashed.URL)
+	mustNoRows(t, err)
+	mustNoRows(t, s.UpdateSeriesInfo(trashed.URL, "", nil))
+	if mustGet(t, s, trashed.ID).DeletedAt == nil {
+		t.Fatal("回收站中的剧集应有删除时间")
+	}
+
+	// 回收站中的剧集仍占用 URL
+	if _, err := s.CreateSeries("重新添加", trashed.URL); !database.IsUniqueViolation(err) {
+		t.Fatalf("回收站中的 URL 应返回唯一约束冲突，实际为 %v", err)
+	}
+
+	list, err := s.GetTrashedSeries()
+	must(t, err)
+	equal(t, "trash", names(list), []string{"删除"})
+
+	mustNoRows(t, s.RestoreSeries(kept.ID))
+	mustNoRows(t, s.PurgeSeries(kept.ID))
+
+	must(t, s.RestoreSeries(trashed.ID))
+	all, err = s.GetAllSeries()
+	must(t, err)
+	equal(t, "restored count", len(all), 2)
+
+	must(t, s.DeleteSeries(trashed.ID))
+	purged, err := s.PurgeTrash(time.Now().Add(-time.Hour))
+	must(t, err)
+	equal(t, "purged before deletion", purged, int64(0))
+
+	must(t, s.PurgeSeries(trashed.ID))
+	_, err = s.GetSeriesByID(trashed.ID)
+	mustNoRows(t, err)
+	eps, err := s.GetEpisodes(trashed.ID)
+	must(t, err)
+	equal(t, "purged episodes", len(eps), 0)
+
+	// 彻底删除后事件保留，剧集 ID 置空
+	events, err := s.GetEvents(time.Now().Add(-time.Hour), 10)
+	must(t, err)
+	if len(events) != 1 || events[0].SeriesID != nil || events[0].SeriesName != "删除" {
+		t.Fatalf("彻底删除后的事件不正确: %+v", events)
+	}
+
+	must(t, s.DeleteSeries(kept.ID))
+	purged, err = s.PurgeTrash(time.Now().Add(time.Hour))
+	must(t, err)
+	equal(t, "purged", purged, int64(1))
+}
+
+func testTags(t *testing.T, s database.Store) {
+	a := mustCreate(t, s, "葬送的芙莉莲", "https://example.com/1")
+	b := mustCreate(t, s, "地球脉动", "https://example.com/2")
+
+	must(t, s.SetSeriesTags(a.ID, []string{"Anime", " 日本 ", ""}))
+	must(t, s.SetSeriesTags(b.ID, []string{"anime", "Documentary"}))
+	equal(t, "tags", mustGet(t, s, a.ID).Tags, []string{"Anime", "日本"})
+	// 已存在的标签不区分大小写复用，保留原名称
+	equal(t, "tags", mustGet(t, s, b.ID).Tags, []string{"Anime", "Documentary"})
+
+	tags, err := s.GetAllTags()
+	must(t, err)
+	equal(t, "tag count", len(tags), 3)
+	equal(t, "first tag", tags[0].Name, "Anime")
+	equal(t, "series count", tags[0].SeriesCount, 2)
+
+	list, _, err := s.ListSeries(database.SeriesQuery{Tag: "ANIME", Sort: "name"})
+	must(t, err)
+	equal(t, "tag filter", len(list), 2)
+
+	must(t, s.DeleteSeries(b.ID))
+	tag, err := s.GetTagByID(tags[0].ID)
+	must(t, err)
+	equal(t, "series count without trash", tag.SeriesCount, 1)
+
+	if _, err := s.CreateTag("DOCUMENTARY", ""); !database.IsUniqueViolation(err) {
+		t.Fatalf("重复标签应返回唯一约束冲突，实际为 %v", err)
+	}
+	created, err := s.CreateTag("美剧", "#ff0000")
+	must(t, err)
+	equal(t, "color", created.Color, "#ff0000")
+
+	if err := s.UpdateTag(created.ID, "anime", ""); !database.IsUniqueViolation(err) {
+		t.Fatalf("重命名为已存在的标签应返回唯一约束冲突，实际为 %v", err)
+	}
+	must(t, s.UpdateTag(created.ID, "US Drama", "#00ff00"))
+	mustNoRows(t, s.UpdateTag(created.ID+100, "x", ""))
+
+	must(t, s.DeleteTag(tags[0].ID))
+	mustNoRows(t, s.DeleteTag(tags[0].ID))
+	equal(t, "tags after delete", mustGet(t, s, a.ID).Tags, []string{"日本"})
+	_, err = s.GetTagByID(tags[0].ID)
+	mustNoRows(t, err)
+}
+
+func testListSeries(t *testing.T, s database.Store) {
+	a := mustCreate(t, s, "banshee", "https://example.com/1")
+	b := mustCreate(t, s, "Arcane", "https://example.com/2")
+	c := mustCreate(t, s, "Chernobyl", "https://example.com/3")
+	mustCreate(t, s, "dark", "https://example.com/4")
+
+	must(t, s.SetSeriesState(b.ID, database.StatePaused))
+	must(t, s.UpdateSeriesInfo(c.URL, "", episodes("S01E01")))
+	must(t, s.MarkAsWatched(c.ID))
+	must(t, s.UpdateSeriesCrawlerLastSeen(a.URL, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	// 默认排序：追踪中的在前，同一秒内按 id
+	all, err := s.GetAllSeries()
+	must(t, err)
+	equal(t, "default order", names(all)[3], "Arcane")
+
+	list, total, err := s.ListSeries(database.SeriesQuery{Sort: "name"})
+	must(t, err)
+	equal(t, "total", total, 4)
+	equal(t, "name order", names(list), []string{"Arcane", "banshee", "Chernobyl", "dark"})
+
+	list, _, err = s.ListSeries(database.SeriesQuery{Sort: "name", Desc: true, Limit: 2, After: database.NewSeriesCursor(list[3], "name")})
+	must(t, err)
+	equal(t, "paged desc", names(list), []string{"Chernobyl", "banshee"})
+
+	list, total, err = s.ListSeries(database.SeriesQuery{Sort: "name", Limit: 10, After: database.NewSeriesCursor(list[0], "name")})
+	must(t, err)
+	equal(t, "after cursor", names(list), []string{"dark"})
+	equal(t, "after cursor total", total, 4)
+
+	// 空值始终排在最后
+	for _, desc := range []bool{false, true} {
+		list, _, err = s.ListSeries(database.SeriesQuery{Sort: "crawler_last_seen", Desc: desc, Limit: 1})
+		must(t, err)
+		if desc {
+			equal(t, "crawler_last_seen desc", names(list), []string{"Chernobyl"})
+		} else {
+			equal(t, "crawler_last_seen asc", names(list), []string{"banshee"})
+		}
+	}
+
+	watched := true
+	list, total, err = s.ListSeries(database.SeriesQuery{States: database.NonTrackingStates()})
+	must(t, err)
+	equal(t, "state filter", names(list), []string{"Arcane"})
+	equal(t, "state total", total, 1)
+
+	list, _, err = s.ListSeries(database.SeriesQuery{Watched: &watched})
+	must(t, err)
+	equal(t, "watched filter", names(list), []string{"Chernobyl"})
+
+	list, _, err = s.ListSeries(database.SeriesQuery{Q: "AR", Sort: "name"})
+	must(t, err)
+	equal(t, "name filter", names(list), []string{"Arcane", "dark"})
+
+	if _, _, err := s.ListSeries(database.SeriesQuery{Sort: "rating"}); err == nil {
+		t.Fatal("不支持的排序字段应返回错误")
+	}
+
+	// 翻页期间前一页的剧集被删除或有新剧集排到前面，下一页不受影响
+	for _, sortKey := range []string{"", "name", "created_at", "crawler_last_seen"} {
+		page, _, err := s.ListSeries(database.SeriesQuery{Sort: sortKey, Limit: 2})
+		must(t, err)
+		after := database.NewSeriesCursor(page[1], sortKey)
+		rest, _, err := s.ListSeries(database.SeriesQuery{Sort: sortKey, After: after})
+		must(t, err)
+		equal(t, "rest "+sortKey, len(rest), 2)
+
+		must(t, s.DeleteSeries(page[0].ID))
+		list, _, err := s.ListSeries(database.SeriesQuery{Sort: sortKey, Limit: 10, After: after})
+		must(t, err)
+		equal(t, "stable page "+sortKey, names(list), names(rest))
+		must(t, s.RestoreSeries(page[0].ID))
+	}
+
+	page, _, err := s.ListSeries(database.SeriesQuery{Sort: "name", Limit: 2})
+	must(t, err)
+	added := mustCreate(t, s, "Aaa", "https://example.com/5")
+	list, _, err = s.ListSeries(database.SeriesQuery{Sort: "name", After: database.NewSeriesCursor(page[1], "name")})
+	must(t, err)
+	equal(t, "stable after insert", names(list), []string{"Chernobyl", "dark"})
+	must(t, s.DeleteSeries(added.ID))
+}
+
+func testEvents(t *testing.T, s database.Store) {
+	a := mustCreate(t, s, "请回答1988", "https://example.com/1")
+	b := mustCreate(t, s, "机智的医生生活", "https://example.com/2")
+
+	must(t, s.CreateSeriesEvent(a.ID, database.EventNewEpisodes, database.EventSourceCrawler,
+		map[string]interface{}{"episodes": []string{"S01E01"}}))
+	must(t, s.CreateSeriesEvent(b.ID, database.EventWatched, database.EventSourceUser, nil))
+	must(t, s.CreateSeriesEvent(a.ID, database.EventRenamed, database.EventSourceUser,
+		map[string]string{"from": "请回答", "to": "请回答1988"}))
+
+	events, err := s.GetSeriesEvents(a.ID, 10)
+	must(t, err)
+	equal(t, "series events", len(events), 2)
+	equal(t, "newest first", events[0].Type, database.EventRenamed)
+	equal(t, "series name", events[0].SeriesName, "请回答1988")
+	equal(t, "detail", string(events[1].Detail), `{"episodes":["S01E01"]}`)
+
+	events, err = s.GetSeriesEvents(a.ID, 1)
+	must(t, err)
+	equal(t, "limit", len(events), 1)
+
+	events, err = s.GetEvents(time.Now().Add(-time.Minute), 10)
+	must(t, err)
+	equal(t, "all events", len(events), 3)
+	equal(t, "empty detail", string(events[1].Detail), `{}`)
+
+	events, err = s.GetEvents(time.Now().Add(time.Minute), 10)
+	must(t, err)
+	equal(t, "future events", len(events), 0)
+
+	// 用户事件只对操作的用户可见，爬虫事件对所有用户可见
+	bob, err := s.CreateUser(&database.User{Username: "bob"})
+	must(t, err)
+	other := s.ForUser(bob.ID)
+	must(t, other.CreateSeriesEvent(a.ID, database.EventWatched, database.EventSourceUser, nil))
+	events, err = other.GetSeriesEvents(a.ID, 10)
+	must(t, err)
+	equal(t, "other user's events", len(events), 2)
+	equal(t, "own event user", *events[0].UserID, bob.ID)
+	equal(t, "crawler event user", events[1].UserID == nil, true)
+	events, err = s.GetEvents(time.Now().Add(-time.Minute), 10)
+	must(t, err)
+	equal(t, "hidden from admin", len(events), 3)
+	equal(t, "admin event user", *events[0].UserID, database.AdminUserID)
+
+	must(t, s.DeleteUser(bob.ID))
+	events, err = other.GetSeriesEvents(a.ID, 10)
+	must(t, err)
+	equal(t, "deleted user's events", len(events), 1)
+}
+
+func testCrawlRuns(t *testing.T, s database.Store) {
+	series := mustCreate(t, s, "风骚律师", "https://example.com/1")
+
+	first, err := s.CreateCrawlRun(&database.CrawlRun{
+		Tasks:       []string{series.URL, "https://example.com/none"},
+		ResultCount: 1,
+		Status:      1,
+		Message:     "ok",
+		DurationMs:  1500,
+		Items: []database.CrawlRunItem{
+			{URL: series.URL, SeriesID: &series.ID, Outcome: database.CrawlOutcomeNewEpisodes, Detail: "S01E01"},
+			{URL: "https://example.com/none", Outcome: database.CrawlOutcomeMissing},
+		},
+	})
+	must(t, err)
+	second, err := s.CreateCrawlRun(&database.CrawlRun{Tasks: []string{}, Status: -1, Message: "失败"})
+	must(t, err)
+
+	runs, err := s.GetCrawlRuns(10)
+	must(t, err)
+	equal(t, "run count", len(runs), 2)
+	equal(t, "newest first", runs[0].ID, second)
+	equal(t, "task count", runs[1].TaskCount, 2)
+	if runs[1].Items != nil {
+		t.Fatal("列表不应包含明细")
+	}
+
+	run, err := s.GetCrawlRun(first)
+	must(t, err)
+	equal(t, "tasks", run.Tasks, []string{series.URL, "https://example.com/none"})
+	equal(t, "duration", run.DurationMs, int64(1500))
+	equal(t, "item count", len(run.Items), 2)
+	if run.Items[0].SeriesID == nil || *run.Items[0].SeriesID != series.ID || run.Items[1].SeriesID != nil {
+		t.Fatalf("明细剧集 ID 不正确: %+v", run.Items)
+	}
+	equal(t, "outcome", run.Items[1].Outcome, database.CrawlOutcomeMissing)
+
+	run, err = s.GetCrawlRun(second)
+	must(t, err)
+	equal(t, "empty items", run.Items, []database.CrawlRunItem{})
+
+	_, err = s.GetCrawlRun(second + 100)
+	mustNoRows(t, err)
+}
+
+func testImport(t *testing.T, s database.Store) {
+	createdAt := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	watchedAt := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+	record := database.SeriesRecord{
+		Name:      "奥本海默",
+		URL:       "https://example.com/1",
+		Current:   "S01E02",
+		State:     statePtr(database.StateCompleted),
+		Tags:      []string{"电影"},
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+		Episodes: []database.EpisodeRecord{
+			{Code: "S01E01", Title: "第一集", FirstSeenAt: createdAt, WatchedAt: &watchedAt},
+			{Code: "S01E02", FirstSeenAt: createdAt},
+		},
+		SeriesMetadata: database.SeriesMetadata{OriginalTitle: "Oppenheimer", Year: 2023},
+		Notes:          "诺兰",
+		Rating:         9,
+	}
+
+	id, err := s.ImportSeries(record)
+	must(t, err)
+	got := mustGet(t, s, id)
+	equal(t, "history", got.History, []string{"S01E01", "S01E02"})
+	equal(t, "next unwatched", got.NextUnwatched, "S01E02")
+	equal(t, "tags", got.Tags, []string{"电影"})
+	if got.State != database.StateCompleted || !got.CreatedAt.Equal(createdAt) {
+		t.Fatalf("导入的剧集字段不正确: %+v", got)
+	}
+	equal(t, "metadata", got.SeriesMetadata, record.SeriesMetadata)
+	equal(t, "notes", got.Notes, "诺兰")
+	equal(t, "rating", got.Rating, 9)
+
+	// 用户修改过的元数据、备注和评分不被导入覆盖，为空的字段才补充
+	year := 2024
+	must(t, s.UpdateSeriesMetadata(id, database.SeriesMetadataPatch{Year: &year}))
+	must(t, s.UpdateSeriesNotes(id, "", 7))
+
+	// 再次导入时合并：集数只追加、只标记已看、标签只添加、空进度不覆盖
+	must(t, s.MarkAsWatched(id))
+	again, err := s.ImportSeries(database.SeriesRecord{
+		Name:  "Oppenheimer",
+		URL:   record.URL,
+		State: statePtr(database.StateWatching),
+		Tags:  []string{"IMAX"},
+		Episodes: []database.EpisodeRecord{
+			{Code: "S01E01", Title: "覆盖"},
+			{Code: "S01E03", Title: "第三集"},
+		},
+		SeriesMetadata: database.SeriesMetadata{OriginalTitle: "覆盖", Year: 2023, IMDbID: "tt15398776"},
+		Notes:          "再次导入",
+		Rating:         5,
+	})
+	must(t, err)
+	equal(t, "same series", again, id)
+	got = mustGet(t, s, id)
+	equal(t, "user edits win", got.SeriesMetadata, database.SeriesMetadata{
+		OriginalTitle: "Oppenheimer",
+		Year:          2024,
+		IMDbID:        "tt15398776",
+	})
+	equal(t, "empty notes filled", got.Notes, "再次导入")
+	equal(t, "user rating wins", got.Rating, 7)
+	equal(t, "name", got.Name, "Oppenheimer")
+	equal(t, "state", got.State, database.StateWatching)
+	equal(t, "current", got.Current, "S01E02")
+	equal(t, "history", got.History, []string{"S01E01", "S01E02", "S01E03"})
+	equal(t, "next unwatched", got.NextUnwatched, "S01E03")
+	equal(t, "tags", got.Tags, []string{"IMAX", "电影"})
+	eps, err := s.GetEpisodes(id)
+	must(t, err)
+	equal(t, "title", eps[0].Title, "第一集")
+	if !eps[0].WatchedAt.Equal(watchedAt) {
+		t.Fatalf("watched_at: 期望 %v，实际为 %v", watchedAt, eps[0].WatchedAt)
+	}
+
+	// 没有剧集状态时保持不变，空值移出片单
+	_, err = s.ImportSeries(database.SeriesRecord{Name: "Oppenheimer", URL: record.URL})
+	must(t, err)
+	equal(t, "state unchanged", mustGet(t, s, id).State, database.StateWatching)
+	_, err = s.ImportSeries(database.SeriesRecord{Name: "Oppenheimer", URL: record.URL, State: statePtr("")})
+	must(t, err)
+	equal(t, "state cleared", mustGet(t, s, id).State, "")
+
+	must(t, s.DeleteSeries(id))
+	if _, err := s.ImportSeries(record); !database.IsUniqueViolation(err) {
+		t.Fatalf("URL 被回收站占用时应返回唯一约束冲突，实际为 %v", err)
+	}
+}
+
+func testSearch(t *testing.T, s database.Store) {
+	a := mustCreate(t, s, "Breaking Bad", "https://example.com/1")
+	b := mustCreate(t, s, "绝命律师", "https://example.com/2")
+	c := mustCreate(t, s, "Bad Sisters", "https://example.com/3")
+	must(t, s.UpdateSeriesInfo(b.URL, "", []database.FetchEpisode{
+		{Code: "S01E01", Title: "Better.Call.Saul.S01E01.1080p"},
+	}))
+
+	hits, err := s.SearchSeries("  ", 10)
+	must(t, err)
+	equal(t, "empty query", len(hits), 0)
+
+	hits, err = s.SearchSeries("bad", 10)
+	must(t, err)
+	equal(t, "name hits", len(hits), 2)
+	for _, hit := range hits {
+		if hit.Series.ID != a.ID && hit.Series.ID != c.ID {
+			t.Fatalf("意外的搜索结果: %+v", hit)
+		}
+	}
+
+	hits, err = s.SearchSeries("call.saul", 10)
+	must(t, err)
+	equal(t, "title hits", len(hits), 1)
+	equal(t, "title hit", hits[0].Series.ID, b.ID)
+	if hits[0].Snippet == "" {
+		t.Fatal("集数标题命中时应返回片段")
+	}
+
+	hits, err = s.SearchSeries("律师", 10)
+	must(t, err)
+	equal(t, "short query", len(hits), 1)
+	equal(t, "highlight", hits[0].Name, "绝命<mark>律师</mark>")
+
+	hits, err = s.SearchSeries("bad", 1)
+	must(t, err)
+	equal(t, "limit", len(hits), 1)
+
+	// 原名和备注也参与搜索，备注只匹配当前用户的
+	must(t, s.UpdateSeriesCrawlerMetadata(b.URL, "", "Better Call Saul"))
+	hits, err = s.SearchSeries("better call", 10)
+	must(t, err)
+	equal(t, "original title hits", len(hits), 1)
+	equal(t, "original title hit", hits[0].Series.ID, b.ID)
+
+	must(t, s.UpdateSeriesNotes(c.ID, "Sharon Horgan 主演", 8))
+	for _, q := range []string{"horgan", "主演"} {
+		hits, err = s.SearchSeries(q, 10)
+		must(t, err)
+		equal(t, "notes hits "+q, len(hits), 1)
+		equal(t, "notes hit "+q, hits[0].Series.ID, c.ID)
+	}
+
+	bob, err := s.CreateUser(&database.User{Username: "bob"})
+	must(t, err)
+	other := s.ForUser(bob.ID)
+	hits, err = other.SearchSeries("horgan", 10)
+	must(t, err)
+	equal(t, "other user's notes", len(hits), 0)
+	must(t, other.UpdateSeriesNotes(c.ID, "Horgan again", 0))
+	hits, err = other.SearchSeries("horgan", 10)
+	must(t, err)
+	equal(t, "own notes", len(hits), 1)
+
+	must(t, s.UpdateSeriesNotes(c.ID, "", 8))
+	hits, err = s.SearchSeries("主演", 10)
+	must(t, err)
+	equal(t, "cleared notes", len(hits), 0)
+
+	must(t, s.DeleteSeries(a.ID))
+	hits, err = s.SearchSeries("breaking", 10)
+	must(t, err)
+	equal(t, "trashed", len(hits), 0)
+}
+
+func testSettings(t *testing.T, s database.Store) {
+	settings, err := s.GetSettings()
+	must(t, err)
+	equal(t, "empty settings", settings.Values(), map[string]string{})
+	equal(t, "default", settings.TrashRetention(), database.DefaultTrashRetentionDays)
+
+	changes, err := s.UpdateSettings(map[string]string{
+		database.SettingCrawlerStartTime:   "08:00",
+		database.SettingCrawlerEndTime:     "23:00",
+		database.SettingTrashRetentionDays: "7",
+	}, "admin")
+	must(t, err)
+	equal(t, "change count", len(changes), 3)
+	equal(t, "changed by", changes[0].ChangedBy, "admin")
+
+	settings, err = s.GetSettings()
+	must(t, err)
+	equal(t, "start", settings.Get(database.SettingCrawlerStartTime), "08:00")
+	equal(t, "retention", settings.TrashRetention(), 7)
+	if settings.IsSet(database.SettingSlackWebhookURL) {
+		t.Fatal("未保存的配置不应视为已设置")
+	}
+
+	// 值未变化的键不记录，空值恢复默认值
+	changes, err = s.UpdateSettings(map[string]string{
+		database.SettingCrawlerStartTime:   "08:00",
+		database.SettingTrashRetentionDays: "",
+	}, "bob")
+	must(t, err)
+	equal(t, "changes", len(changes), 1)
+	equal(t, "old value", changes[0].OldValue, "7")
+	equal(t, "new value", changes[0].NewValue, "")
+	settings, err = s.GetSettings()
+	must(t, err)
+	equal(t, "reset", settings.TrashRetention(), database.DefaultTrashRetentionDays)
+
+	history, err := s.GetSettingsHistory("", 10)
+	must(t, err)
+	equal(t, "history count", len(history), 4)
+	equal(t, "newest first", history[0].ID, changes[0].ID)
+
+	history, err = s.GetSettingsHistory(database.SettingTrashRetentionDays, 1)
+	must(t, err)
+	equal(t, "key history", len(history), 1)
+	equal(t, "key history by", history[0].ChangedBy, "bob")
+
+	change, err := s.GetSettingChange(changes[0].ID)
+	must(t, err)
+	equal(t, "change", *change, history[0])
+	_, err = s.GetSettingChange(changes[0].ID + 100)
+	mustNoRows(t, err)
+}
+
+func testUsers(t *testing.T, s database.Store) {
+	admin, err := s.GetUserByID(database.AdminUserID)
+	must(t, err)
+	if !admin.IsAdmin {
+		t.Fatal("初始用户应为管理员")
+	}
+
+	alice, err := s.CreateUser(&database.User{Username: "Alice", PasswordHash: "hash"})
+	must(t, err)
+	if alice.ID == 0 || alice.IsAdmin || alice.PasswordHash != "hash" {
+		t.Fatalf("新建用户字段不正确: %+v", alice)
+	}
+	if _, err := s.CreateUser(&database.User{Username: "alice"}); !database.IsUniqueViolation(err) {
+		t.Fatalf("用户名不区分大小写唯一，实际为 %v", err)
+	}
+
+	byName, err := s.GetUserByName("ALICE")
+	must(t, err)
+	equal(t, "user by name", byName.ID, alice.ID)
+	_, err = s.GetUserByName("bob")
+	mustNoRows(t, err)
+
+	alice.Username = "admin"
+	if err := s.UpdateUser(alice); !database.IsUniqueViolation(err) {
+		t.Fatalf("重命名为已存在的用户名应返回唯一约束冲突，实际为 %v", err)
+	}
+	alice.Username = "alice"
+	alice.SlackWebhookURL = "https://hooks.slack.com/services/a"
+	must(t, s.UpdateUser(alice))
+	got, err := s.GetUserByID(alice.ID)
+	must(t, err)
+	equal(t, "webhook", got.SlackWebhookURL, alice.SlackWebhookURL)
+	mustNoRows(t, s.UpdateUser(&database.User{ID: alice.ID + 100, Username: "x"}))
+
+	users, err := s.GetUsers()
+	must(t, err)
+	equal(t, "user count", len(users), 2)
+
+	must(t, s.DeleteUser(alice.ID))
+	mustNoRows(t, s.DeleteUser(alice.ID))
+}
+
+func testPerUserState(t *testing.T, s database.Store) {
+	bob, err := s.CreateUser(&database.User{Username: "bob"})
+	must(t, err)
+	other := s.ForUser(bob.ID)
+
+	a := mustCreate(t, s, "风骚律师", "https://example.com/1")
+	b := mustCreate(t, other, "绝命毒师", "https://example.com/2")
+	c := mustCreate(t, s, "停止追踪", "https://example.com/3")
+	must(t, s.SetSeriesState(c.ID, database.StateCompleted))
+	must(t, s.UpdateSeriesInfo(a.URL, "", episodes("S01E01", "S01E02")))
+
+	// 剧集共享，剧集状态按用户区分
+	equal(t, "shared series", len(mustList(t, other)), 3)
+	if mustGet(t, other, a.ID).State != "" || mustGet(t, other, b.ID).State != database.StateWatching {
+		t.Fatal("剧集状态应按用户区分")
+	}
+	list, _, err := other.ListSeries(database.SeriesQuery{States: database.TrackingStates})
+	must(t, err)
+	equal(t, "state filter", names(list), []string{"绝命毒师"})
+
+	// 爬虫任务为所有用户追踪的并集
+	urls, err := s.GetAllTrackingURLs(time.Time{})
+	must(t, err)
+	equal(t, "tracking urls", urls, []string{a.URL, b.URL})
+
+	must(t, other.SetSeriesState(a.ID, database.StateWaiting))
+	subscribers, err := s.GetSubscribers(a.ID)
+	must(t, err)
+	equal(t, "subscribers", len(subscribers), 2)
+
+	// 观看状态按用户区分
+	must(t, s.MarkWatchedUntil(a.ID, "S01E01"))
+	must(t, other.MarkAsWatched(a.ID))
+	equal(t, "own progress", mustGet(t, s, a.ID).NextUnwatched, "S01E02")
+	if !mustGet(t, other, a.ID).IsWatched {
+		t.Fatal("其他用户应为全部已看")
+	}
+	eps, err := other.GetEpisodes(a.ID)
+	must(t, err)
+	if eps[1].WatchedAt == nil {
+		t.Fatal("其他用户的单集观看状态不正确")
+	}
+	watched := true
+	list, _, err = s.ListSeries(database.SeriesQuery{Watched: &watched})
+	must(t, err)
+	equal(t, "watched filter", len(list), 0)
+
+	must(t, other.SetEpisodeWatched(a.ID, "S01E01", false))
+	equal(t, "own progress unchanged", mustGet(t, s, a.ID).NextUnwatched, "S01E02")
+
+	// 删除用户时订阅和观看状态一并删除
+	must(t, s.DeleteUser(bob.ID))
+	subscribers, err = s.GetSubscribers(a.ID)
+	must(t, err)
+	equal(t, "subscribers after delete", len(subscribers), 1)
+	urls, err = s.GetAllTrackingURLs(time.Time{})
+	must(t, err)
+	equal(t, "tracking urls after delete", urls, []string{a.URL})
+}
+
+func testMetadata(t *testing.T, s database.Store) {
+	a := mustCreate(t, s, "绝命毒师", "https://example.com/1")
+	equal(t, "empty metadata", mustGet(t, s, a.ID).SeriesMetadata, database.SeriesMetadata{})
+
+	// 爬虫的值在用户未填写时生效，空值不覆盖已有的值
+	must(t, s.UpdateSeriesCrawlerMetadata(a.URL, "https://img.example.com/1.jpg", "Breaking Bad"))
+	must(t, s.UpdateSeriesCrawlerMetadata(a.URL, "", ""))
+	got := mustGet(t, s, a.ID)
+	equal(t, "crawler poster", got.PosterURL, "https://img.example.com/1.jpg")
+	equal(t, "crawler original title", got.OriginalTitle, "Breaking Bad")
+
+	poster, year, imdb := "https://img.example.com/user.jpg", 2008, "tt0903747"
+	must(t, s.UpdateSeriesMetadata(a.ID, database.SeriesMetadataPatch{PosterURL: &poster, Year: &year}))
+	must(t, s.UpdateSeriesMetadata(a.ID, database.SeriesMetadataPatch{IMDbID: &imdb}))
+	must(t, s.UpdateSeriesCrawlerMetadata(a.URL, "https://img.example.com/2.jpg", ""))
+	equal(t, "user metadata wins", mustGet(t, s, a.ID).SeriesMetadata, database.SeriesMetadata{
+		PosterURL:     poster,
+		OriginalTitle: "Breaking Bad",
+		Year:          2008,
+		IMDbID:        "tt0903747",
+	})
+
+	// 用户清空后恢复使用爬虫的值
+	empty := ""
+	must(t, s.UpdateSeriesMetadata(a.ID, database.SeriesMetadataPatch{PosterURL: &empty}))
+	equal(t, "fallback poster", mustGet(t, s, a.ID).PosterURL, "https://img.example.com/2.jpg")
+	mustNoRows(t, s.UpdateSeriesMetadata(a.ID+100, database.SeriesMetadataPatch{Year: &year}))
+
+	list, _, err := s.ListSeries(database.SeriesQuery{Q: "breaking"})
+	must(t, err)
+	equal(t, "original title filter", names(list), []string{"绝命毒师"})
+
+	// 备注和评分按用户保存
+	user, err := s.CreateUser(&database.User{Username: "alice"})
+	must(t, err)
+	alice := s.ForUser(user.ID)
+	must(t, s.UpdateSeriesNotes(a.ID, "第五季最好看", 9))
+	must(t, alice.UpdateSeriesNotes(a.ID, "", 6))
+	mustNoRows(t, s.UpdateSeriesNotes(a.ID+100, "", 1))
+
+	got = mustGet(t, s, a.ID)
+	equal(t, "notes", got.Notes, "第五季最好看")
+	equal(t, "rating", got.Rating, 9)
+	got = mustGet(t, alice, a.ID)
+	equal(t, "alice notes", got.Notes, "")
+	equal(t, "alice rating", got.Rating, 6)
+
+	must(t, s.UpdateSeriesNotes(a.ID, "", 0))
+	got = mustGet(t, s, a.ID)
+	equal(t, "cleared rating", got.Rating, 0)
+	equal(t, "alice rating unchanged", mustGet(t, alice, a.ID).Rating, 6)
+}
+
+func mustList(t *testing.T, s database.Store) []database.Series {
+	t.Helper()
+	series, err := s.GetAllSeries()
+	must(t, err)
+	return series
+}
+
+func testStates(t *testing.T, s database.Store) {
+	bob, err := s.CreateUser(&database.User{Username: "bob"})
+	must(t, err)
+	other := s.ForUser(bob.ID)
+
+	a := mustCreate(t, s, "想看", "https://example.com/1")
+	b := mustCreate(t, s, "暂停", "https://example.com/2")
+	c := mustCreate(t, s, "看完", "https://example.com/3")
+	must(t, s.SetSeriesState(a.ID, database.StatePlanned))
+	must(t, s.SetSeriesState(b.ID, database.StatePaused))
+	must(t, s.SetSeriesState(c.ID, database.StateCompleted))
+	mustNoRows(t, s.SetSeriesState(c.ID+100, database.StateWatching))
+	mustNoRows(t, s.SetSeriesState(c.ID+100, ""))
+
+	// 只有 TrackingStates 中的状态需要爬虫抓取和通知
+	urls, err := s.GetAllTrackingURLs(time.Time{})
+	must(t, err)
+	equal(t, "tracking urls", urls, []string{a.URL})
+	subscribers, err := s.GetSubscribers(b.ID)
+	must(t, err)
+	equal(t, "paused subscribers", len(subscribers), 0)
+
+	must(t, other.SetSeriesState(b.ID, database.StateWaiting))
+	urls, err = s.GetAllTrackingURLs(time.Time{})
+	must(t, err)
+	equal(t, "tracking urls with other user", urls, []string{a.URL, b.URL})
+	subscribers, err = s.GetSubscribers(b.ID)
+	must(t, err)
+	equal(t, "waiting subscribers", len(subscribers), 1)
+
+	list, total, err := s.ListSeries(database.SeriesQuery{States: []string{database.StatePaused, database.StateCompleted}, Sort: "name"})
+	must(t, err)
+	equal(t, "state filter", names(list), []string{"暂停", "看完"})
+	equal(t, "state total", total, 2)
+
+	// 空状态移出片单，按空状态筛选可找到
+	must(t, s.SetSeriesState(c.ID, ""))
+	equal(t, "removed", mustGet(t, s, c.ID).State, "")
+	list, _, err = s.ListSeries(database.SeriesQuery{States: []string{""}})
+	must(t, err)
+	equal(t, "not in list", names(list), []string{"看完"})
+	list, _, err = other.ListSeries(database.SeriesQuery{States: []string{""}, Sort: "name"})
+	must(t, err)
+	equal(t, "other not in list", names(list), []string{"想看", "看完"})
+
+	// 默认排序：需要追踪的在前
+	all, err := s.GetAllSeries()
+	must(t, err)
+	equal(t, "default order first", names(all)[0], "想看")
+}
+
+func testSeriesStatus(t *testing.T, s database.Store) {
+	for text, want := range map[string]database.SeriesStatus{
+		"":                   {},
+		"已更新到 S02E08":        {LatestEpisode: "S02E08"},
+		"更新至第 10 集":          {LatestEpisode: "S01E10"},
+		"更新至第 10 集 / 共 12 集": {LatestEpisode: "S01E10", TotalEpisodes: 12},
+		"第 12 集 / 共 12 集":    {LatestEpisode: "S01E12", TotalEpisodes: 12, Finished: true},
+		"全 12 集":             {TotalEpisodes: 12, Finished: true},
+		"已完结":                {Finished: true},
+		"s01e24 已完结":         {LatestEpisode: "S01E24", Finished: true},
+		"未完结":                {},
+	} {
+		equal(t, "parse "+text, database.ParseStatus(text), want)
+	}
+
+	a := mustCreate(t, s, "漫长的季节", "https://example.com/1")
+	b := mustCreate(t, s, "繁花", "https://example.com/2")
+	must(t, s.UpdateSeriesInfo(a.URL, "已更新到 S01E08", episodes("S01E08")))
+	got := mustGet(t, s, a.ID)
+	equal(t, "latest", got.LatestEpisode, "S01E08")
+	if got.Finished || got.FinishedAt != nil {
+		t.Fatalf("未完结的剧集不应有完结时间: %+v", got.SeriesStatus)
+	}
+
+	must(t, s.UpdateSeriesInfo(a.URL, "已完结", episodes("S01E08")))
+	got = mustGet(t, s, a.ID)
+	if !got.Finished || got.FinishedAt == nil {
+		t.Fatalf("应标记为已完结: %+v", got.SeriesStatus)
+	}
+	finishedAt := *got.FinishedAt
+
+	// 完结时间保留首次发现的时间
+	must(t, s.UpdateSeriesInfo(a.URL, "已完结 共 8 集", episodes("S01E08")))
+	got = mustGet(t, s, a.ID)
+	equal(t, "total", got.TotalEpisodes, 8)
+	if got.FinishedAt == nil || !got.FinishedAt.Equal(finishedAt) {
+		t.Fatalf("finished_at: 期望 %v，实际为 %v", finishedAt, got.FinishedAt)
+	}
+
+	// 已完结的剧集只在上次抓取早于指定时间时返回
+	urls, err := s.GetAllTrackingURLs(time.Time{})
+	must(t, err)
+	equal(t, "skip finished", urls, []string{b.URL})
+	urls, err = s.GetAllTrackingURLs(time.Now().Add(-time.Hour))
+	must(t, err)
+	equal(t, "recently crawled", urls, []string{b.URL})
+	must(t, s.UpdateSeriesCrawlerLastSeen(a.URL, time.Now().AddDate(0, 0, -10)))
+	urls, err = s.GetAllTrackingURLs(time.Now().AddDate(0, 0, -7))
+	must(t, err)
+	equal(t, "recheck finished", urls, []string{a.URL, b.URL})
+
+	// 发现新季后恢复为未完结
+	must(t, s.UpdateSeriesInfo(a.URL, "已更新到 S02E01", episodes("S01E08", "S02E01")))
+	got = mustGet(t, s, a.ID)
+	if got.Finished || got.FinishedAt != nil {
+		t.Fatalf("发现新季后应恢复为未完结: %+v", got.SeriesStatus)
+	}
+	equal(t, "new season", got.LatestEpisode, "S02E01")
+
+	must(t, s.UpdateSeriesInfo(b.URL, "全 40 集", nil))
+	must(t, s.ClearSeriesHistory(b.ID))
+	equal(t, "cleared", mustGet(t, s, b.ID).SeriesStatus, database.SeriesStatus{})
+	equal(t, "cleared finished_at", mustGet(t, s, b.ID).FinishedAt, (*time.Time)(nil))
+
+	_, err = s.ImportSeries(database.SeriesRecord{Name: "繁花", URL: b.URL, Current: "已完结", State: statePtr(database.StateWatching)})
+	must(t, err)
+	if got := mustGet(t, s, b.ID); !got.Finished || got.FinishedAt == nil {
+		t.Fatalf("导入的状态应解析为已完结: %+v", got.SeriesStatus)
+	}
+}
+
+func testStats(t *testing.T, s database.Store) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	watched := now.Add(-day)
+
+	// 每 3 天一集，最后两集在最近一周
+	_, err := s.ImportSeries(database.SeriesRecord{
+		Name: "间谍过家家", URL: "https://example.com/1", State: statePtr(database.StateWatching),
+		Episodes: []database.EpisodeRecord{
+			{Code: "S01E01", FirstSeenAt: now.Add(-9 * day), WatchedAt: &watched},
+			{Code: "S01E02", FirstSeenAt: now.Add(-6 * day)},
+			{Code: "S01E03", FirstSeenAt: now.Add(-3 * day)},
+			{Code: "S01E04", FirstSeenAt: now.Add(-100 * day)},
+		},
+	})
+	must(t, err)
+	_, err = s.ImportSeries(database.SeriesRecord{
+		Name: "请回答1988", URL: "https://example.com/2", State: statePtr(database.StateCompleted),
+		Episodes: []database.EpisodeRecord{
+			{Code: "S01E01", FirstSeenAt: now.Add(-2 * day)},
+		},
+	})
+	must(t, err)
+	mustCreate(t, s, "回收站", "https://example.com/3")
+	other := mustCreate(t, s, "不在片单", "https://example.com/4")
+	must(t, s.SetSeriesState(other.ID, ""))
+	trashed, err := s.GetSeriesByURL("https://example.com/3")
+	must(t, err)
+	must(t, s.DeleteSeries(trashed.ID))
+
+	_, err = s.CreateCrawlRun(&database.CrawlRun{Status: 1, Items: []database.CrawlRunItem{
+		{URL: "https://example.com/1", Outcome: database.CrawlOutcomeUnchanged},
+		{URL: "https://example.com/2", Outcome: database.CrawlOutcomeNewEpisodes},
+		{URL: "https://example.com/5", Outcome: database.CrawlOutcomeMissing},
+		{URL: "https://example.com/6", Outcome: database.CrawlOutcomeStatusChanged},
+	}})
+	must(t, err)
+	_, err = s.CreateCrawlRun(&database.CrawlRun{Status: -1})
+	must(t, err)
+
+	stats, err := s.GetStats(now, 2)
+	must(t, err)
+	equal(t, "series count", stats.SeriesCount, 3)
+	equal(t, "watching", stats.States[database.StateWatching], 1)
+	equal(t, "completed", stats.States[database.StateCompleted], 1)
+	equal(t, "none", stats.States["none"], 1)
+	equal(t, "dropped", stats.States[database.StateDropped], 0)
+
+	// 看完的剧集不计入未看
+	equal(t, "unwatched episodes", stats.UnwatchedEpisodes, 3)
+	equal(t, "unwatched series", stats.UnwatchedSeries, 1)
+
+	equal(t, "weeks", len(stats.WeeklyEpisodes), 2)
+	equal(t, "first week", stats.WeeklyEpisodes[0].WeekStart, now.Add(-14*day))
+	equal(t, "first week count", stats.WeeklyEpisodes[0].Count, 1)
+	equal(t, "last week count", stats.WeeklyEpisodes[1].Count, 3)
+
+	equal(t, "most active", stats.MostActive, []database.SeriesActivity{
+		{SeriesID: 1, Name: "间谍过家家", Episodes: 3},
+		{SeriesID: 2, Name: "请回答1988", Episodes: 1},
+	})
+	equal(t, "intervals", len(stats.EpisodeIntervals), 1)
+	equal(t, "average days", stats.EpisodeIntervals[0].AverageDays, 32.3)
+
+	// 爬虫记录按当前时间保存，不在 2024 年的统计周期内
+	equal(t, "old crawler runs", stats.Crawler.Runs, 0)
+	stats, err = s.GetStats(time.Now().Add(time.Minute), 1)
+	must(t, err)
+	equal(t, "crawler", stats.Crawler, database.CrawlerStats{
+		Runs: 2, FailedRuns: 1, Items: 4, Succeeded: 3, SuccessRate: 0.75,
+	})
+}
+
+func testTransaction(t *testing.T, s database.Store) {
+	series := mustCreate(t, s, "狂飙", "https://example.com/1")
+	errAbort := errors.New("abort")
+
+	// 出错时回滚事务中的所有修改，包括嵌套的写事务
+	err := s.Transaction(func(tx database.Store) error {
+		must(t, tx.UpdateSeriesInfo(series.URL, "更新至第 2 集", episodes("S01E01", "S01E02")))
+		_, err := tx.CreateCrawlRun(&database.CrawlRun{Status: 1})
+		must(t, err)
+		equal(t, "inside", mustGet(t, tx, series.ID).History, []string{"S01E01", "S01E02"})
+		return errAbort
+	})
+	equal(t, "error", err, errAbort)
+	got := mustGet(t, s, series.ID)
+	equal(t, "rolled back history", got.History, []string{})
+	equal(t, "rolled back current", got.Current, "")
+	runs, err := s.GetCrawlRuns(10)
+	must(t, err)
+	equal(t, "rolled back runs", len(runs), 0)
+
+	// 嵌套事务失败只撤销自身的修改
+	must(t, s.Transaction(func(tx database.Store) error {
+		must(t, tx.UpdateSeriesInfo(series.URL, "更新至第 1 集", episodes("S01E01")))
+		err := tx.Transaction(func(inner database.Store) error {
+			must(t, inner.UpdateSeriesInfo(series.URL, "更新至第 3 集", episodes("S01E01", "S01E03")))
+			return errAbort
+		})
+		equal(t, "nested error", err, errAbort)
+		return tx.ForUser(database.AdminUserID).MarkAsWatched(series.ID)
+	}))
+	got = mustGet(t, s, series.ID)
+	equal(t, "committed history", got.History, []string{"S01E01"})
+	equal(t, "committed current", got.Current, "更新至第 1 集")
+	equal(t, "committed watched", got.IsWatched, true)
+}
+
+func testVersion(t *testing.T, s database.Store) {
+	series := mustCreate(t, s, "狂飙", "https://example.com/1")
+	other := mustCreate(t, s, "漫长的季节", "https://example.com/2")
+	alice, err := s.CreateUser(&database.User{Username: "alice"})
+	must(t, err)
+	version := series.Version
+	if version <= 0 {
+		t.Fatalf("新剧集的版本应为正数，实际为 %d", version)
+	}
+
+	// 每次修改剧集返回的数据都递增版本，读取不影响版本
+	changes := []struct {
+		what   string
+		change func() error
+	}{
+		{"rename", func() error { return s.UpdateSeries(series.ID, "狂飙 2023", series.URL) }},
+		{"fetch", func() error {
+			return s.UpdateSeriesInfo(series.URL, "更新至第 2 集", episodes("S01E01", "S01E02"))
+		}},
+		{"watch", func() error { return s.MarkAsWatched(series.ID) }},
+		{"unwatch", func() error { return s.MarkAsUnwatched(series.ID) }},
+		{"state", func() error { return s.SetSeriesState(series.ID, database.StatePaused) }},
+		{"notes", func() error { return s.UpdateSeriesNotes(series.ID, "好看", 9) }},
+		{"other user", func() error { return s.ForUser(alice.ID).SetSeriesState(series.ID, database.StateWatching) }},
+		{"tags", func() error { return s.SetSeriesTags(series.ID, []string{"国产"}) }},
+		{"rename tag", func() error {
+			tags, err := s.GetAllTags()
+			if err != nil {
+				return err
+			}
+			return s.UpdateTag(tags[0].ID, "国剧", "")
+		}},
+		{"delete tag", func() error {
+			tags, err := s.GetAllTags()
+			if err != nil {
+				return err
+			}
+			return s.DeleteTag(tags[0].ID)
+		}},
+		{"delete", func() error { return s.DeleteSeries(series.ID) }},
+		{"restore", func() error { return s.RestoreSeries(series.ID) }},
+		{"clear history", func() error { return s.ClearSeriesHistory(series.ID) }},
+	}
+	for _, c := range changes {
+		must(t, c.change())
+		got := mustGet(t, s, series.ID)
+		if got.Version <= version {
+			t.Fatalf("%s: 版本应大于 %d，实际为 %d", c.what, version, got.Version)
+		}
+		version = got.Version
+		equal(t, c.what+" unchanged on read", mustGet(t, s, series.ID).Version, version)
+	}
+
+	// 其他剧集的版本不受影响
+	equal(t, "other series", mustGet(t, s, other.ID).Version, other.Version)
+}
+
+func testURLNormalization(t *testing.T, s database.Store) {
+	series := mustCreate(t, s, "狂飙", "http://mini4k.com/shows/123/?utm_source=share#top")
+	equal(t, "canonical", series.URL, "https://www.mini4k.com/shows/123")
+
+	// 同一剧集的不同写法视为重复
+	for _, url := range []string{"https://www.mini4k.com/shows/123", "https://MINI4K.com/shows/123/"} {
+		if _, err := s.CreateSeries("重复", url); !database.IsUniqueViolation(err) {
+			t.Fatalf("%s 应返回唯一约束冲突，实际为 %v", url, err)
+		}
+	}
+
+	// 爬虫回调按规范形式匹配
+	byURL, err := s.GetSeriesByURL("http://www.mini4k.com/shows/123/")
+	must(t, err)
+	equal(t, "match", byURL.ID, series.ID)
+	must(t, s.UpdateSeriesInfo("http://mini4k.com/shows/123", "更新至第 1 集", episodes("S01E01")))
+	equal(t, "history", mustGet(t, s, series.ID).History, []string{"S01E01"})
+
+	must(t, s.UpdateSeries(series.ID, series.Name, "https://example.com/a/?b=2&a=1&fbclid=x"))
+	equal(t, "updated", mustGet(t, s, series.ID).URL, "https://example.com/a?a=1&b=2")
+
+	id, err := s.ImportSeries(database.SeriesRecord{Name: "狂飙", URL: "https://example.com/a?a=1&b=2&utm_medium=x"})
+	must(t, err)
+	equal(t, "import merged", id, series.ID)
+}
+
+func testMergeSeries(t *testing.T, s database.Store) {
+	target := mustCreate(t, s, "狂飙", "https://example.com/1")
+	source := mustCreate(t, s, "狂飙（重复）", "https://example.com/2")
+	alice, err := s.CreateUser(&database.User{Username: "alice"})
+	must(t, err)
+	aliceStore := s.ForUser(alice.ID)
+
+	must(t, s.UpdateSeriesInfo(target.URL, "更新至第 2 集", episodes("S01E01", "S01E02")))
+	must(t, s.UpdateSeriesInfo(source.URL, "更新至第 3 集", episodes("S01E02", "S01E03")))
+	must(t, s.SetEpisodeWatched(source.ID, "S01E02", true))
+	must(t, aliceStore.SetSeriesState(source.ID, database.StatePlanned))
+	must(t, aliceStore.SetEpisodeWatched(source.ID, "S01E03", true))
+	must(t, s.UpdateSeriesNotes(source.ID, "重复的备注", 5))
+	must(t, s.SetSeriesTags(source.ID, []string{"国产"}))
+	imdb := "tt1"
+	must(t, s.UpdateSeriesMetadata(source.ID, database.SeriesMetadataPatch{IMDbID: &imdb}))
+	must(t, s.CreateSeriesEvent(source.ID, database.EventRenamed, database.EventSourceUser, nil))
+
+	if err := s.MergeSeries(target.ID, target.ID); err == nil {
+		t.Fatal("合并到自身应返回错误")
+	}
+	mustNoRows(t, s.MergeSeries(target.ID, source.ID+100))
+
+	must(t, s.MergeSeries(target.ID, source.ID))
+	_, err = s.GetSeriesByID(source.ID)
+	mustNoRows(t, err)
+
+	got := mustGet(t, s, target.ID)
+	equal(t, "name", got.Name, "狂飙")
+	equal(t, "url", got.URL, target.URL)
+	equal(t, "current", got.Current, "更新至第 2 集")
+	equal(t, "history", got.History, []string{"S01E01", "S01E02", "S01E03"})
+	equal(t, "next unwatched", got.NextUnwatched, "S01E01")
+	equal(t, "unwatched", got.UnwatchedCount, 2)
+	equal(t, "state", got.State, database.StateWatching)
+	equal(t, "notes", got.Notes, "重复的备注")
+	equal(t, "tags", got.Tags, []string{"国产"})
+	equal(t, "imdb", got.IMDbID, "tt1")
+
+	gotAlice, err := aliceStore.GetSeriesByID(target.ID)
+	must(t, err)
+	equal(t, "alice state", gotAlice.State, database.StatePlanned)
+	equal(t, "alice unwatched", gotAlice.UnwatchedCount, 2)
+
+	events, err := s.GetSeriesEvents(target.ID, 10)
+	must(t, err)
+	equal(t, "events", len(events), 1)
+}
+
+func testSessions(t *testing.T, s database.Store) {
+	alice, err := s.CreateUser(&database.User{Username: "alice"})
+	must(t, err)
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	newSession := func(userID int64, token string, expiresAt time.Time) *database.Session {
+		t.Helper()
+		session, err := s.CreateSession(&database.Session{
+			UserID: userID, TokenHash: token, UserAgent: "curl", IP: "127.0.0.1",
+			CreatedAt: now.Add(-time.Hour), LastUsedAt: now.Add(-time.Hour), ExpiresAt: expiresAt,
+		})
+		must(t, err)
+		return session
+	}
+	first := newSession(database.AdminUserID, "hash-1", now.Add(time.Hour))
+	second := newSession(database.AdminUserID, "hash-2", now.Add(2*time.Hour))
+	newSession(database.AdminUserID, "hash-3", now)
+	newSession(alice.ID, "hash-4", now.Add(time.Hour))
+	if _, err := s.CreateSession(&database.Session{UserID: alice.ID, TokenHash: "hash-1", ExpiresAt: now}); !database.IsUniqueViolation(err) {
+		t.Fatalf("令牌摘要应唯一，实际为 %v", err)
+	}
+
+	got, err := s.GetSessionByToken("hash-2")
+	must(t, err)
+	equal(t, "session by token", got.ID, second.ID)
+	equal(t, "session expires", got.ExpiresAt.Equal(now.Add(2*time.Hour)), true)
+	equal(t, "session user agent", got.UserAgent, "curl")
+	_, err = s.GetSessionByToken("missing")
+	mustNoRows(t, err)
+
+	// 最近使用的在前，不含已过期的会话
+	must(t, s.RenewSession(first.ID, now, now.Add(24*time.Hour)))
+	sessions, err := s.GetSessions(database.AdminUserID, now)
+	must(t, err)
+	var ids []int64
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+	}
+	equal(t, "sessions", ids, []int64{first.ID, second.ID})
+	mustNoRows(t, s.RenewSession(first.ID+100, now, now))
+
+	mustNoRows(t, s.DeleteSession(alice.ID, first.ID))
+	must(t, s.DeleteSession(database.AdminUserID, first.ID))
+	_, err = s.GetSessionByToken("hash-1")
+	mustNoRows(t, err)
+
+	purged, err := s.PurgeSessions(now)
+	must(t, err)
+	equal(t, "purged sessions", purged, int64(1))
+	_, err = s.GetSessionByToken("hash-3")
+	mustNoRows(t, err)
+
+	newSession(database.AdminUserID, "hash-5", now.Add(time.Hour))
+	deleted, err := s.DeleteUserSessions(database.AdminUserID, second.ID)
+	must(t, err)
+	equal(t, "deleted sessions", deleted, int64(1))
+	_, err = s.GetSessionByToken("hash-2")
+	must(t, err)
+
+	// 删除用户时会话随之删除
+	must(t, s.DeleteUser(alice.ID))
+	_, err = s.GetSessionByToken("hash-4")
+	mustNoRows(t, err)
+}
+
+func testAPIKeys(t *testing.T, s database.Store) {
+	key, err := s.CreateAPIKey(&database.APIKey{
+		UserID: database.AdminUserID, Name: "crawler", Prefix: "mck_abcd", KeyHash: "hash-1",
+		Scopes: []string{"fetch:read", "fetch:write"},
+	})
+	must(t, err)
+	if key.ID == 0 || key.CreatedAt.IsZero() || key.LastUsedAt != nil || key.RevokedAt != nil {
+		t.Fatalf("新建密钥字段不正确: %+v", key)
+	}
+	equal(t, "scopes", key.Scopes, []string{"fetch:read", "fetch:write"})
+	if _, err := s.CreateAPIKey(&database.APIKey{UserID: database.AdminUserID, KeyHash: "hash-1", Scopes: []string{}}); !database.IsUniqueViolation(err) {
+		t.Fatalf("密钥摘要应唯一，实际为 %v", err)
+	}
+
+	usedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	must(t, s.TouchAPIKey(key.ID, usedAt))
+	mustNoRows(t, s.TouchAPIKey(key.ID+100, usedAt))
+	got, err := s.GetAPIKeyByHash("hash-1")
+	must(t, err)
+	equal(t, "key by hash", got.ID, key.ID)
+	if got.LastUsedAt == nil || !got.LastUsedAt.Equal(usedAt) {
+		t.Fatalf("最近使用时间不正确: %v", got.LastUsedAt)
+	}
+	_, err = s.GetAPIKeyByHash("missing")
+	mustNoRows(t, err)
+
+	// 吊销后保留记录
+	must(t, s.RevokeAPIKey(key.ID, usedAt.Add(time.Hour)))
+	mustNoRows(t, s.RevokeAPIKey(key.ID, usedAt.Add(time.Hour)))
+	got, err = s.GetAPIKeyByHash("hash-1")
+	must(t, err)
+	if got.RevokedAt == nil || !got.RevokedAt.Equal(usedAt.Add(time.Hour)) {
+		t.Fatalf("吊销时间不正确: %v", got.RevokedAt)
+	}
+
+	alice, err := s.CreateUser(&database.User{Username: "alice"})
+	must(t, err)
+	second, err := s.CreateAPIKey(&database.APIKey{UserID: alice.ID, Name: "script", KeyHash: "hash-2", Scopes: []string{"series:read"}})
+	must(t, err)
+	keys, err := s.GetAPIKeys()
+	must(t, err)
+	equal(t, "key count", len(keys), 2)
+	equal(t, "newest first", keys[0].ID, second.ID)
+
+	// 删除用户时密钥随之删除
+	must(t, s.DeleteUser(alice.ID))
+	_, err = s.GetAPIKeyByHash("hash-2")
+	mustNoRows(t, err)
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"strings"
+	"time"
+)
+
+// Tag 剧集标签
+type Tag struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Color       string    `json:"color"`
+	SeriesCount int       `json:"series_count"` // 不含回收站中的剧集
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NormalizeTagName 去除标签名首尾空白
+func NormalizeTagName(name string) string {
+	return strings.TrimSpace(name)
+}
+
+// tagColumns 标签查询字段，顺序需与 scanTag 一致
+const tagColumns = `tags.id, tags.name, tags.color, tags.created_at,
+	(SELECT COUNT(*) FROM series_tags st JOIN series s ON s.id = st.series_id
+		WHERE st.tag_id = tags.id AND s.deleted_at IS NULL) AS series_count`
+
+// scanTag 扫描一行标签
+func scanTag(row rowScanner) (*Tag, error) {
+	var t Tag
+	if err := row.Scan(&t.ID, &t.Name, &t.Color, &t.CreatedAt, &t.SeriesCount); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetAllTags 获取所有标签
+func (d *Database) GetAllTags() ([]Tag, error) {
+	rows, err := d.db.Query("SELECT " + tagColumns + " FROM tags ORDER BY tags.name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		t, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, *t)
+	}
+	return tags, rows.Err()
+}
+
+// GetTagByID 根据ID获取标签
+func (d *Database) GetTagByID(id int64) (*Tag, error) {
+	return scanTag(d.db.QueryRow("SELECT "+tagColumns+" FROM tags WHERE tags.id = ?", id))
+}
+
+// CreateTag 创建标签
+func (d *Database) CreateTag(name, color string) (*Tag, error) {
+	result, err := d.db.Exec("INSERT INTO tags (name, color) VALUES (?, ?)", name, color)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return d.GetTagByID(id)
+}
+
+// UpdateTag 更新标签，标签不存在时返回 sql.ErrNoRows
+func (d *Database) UpdateTag(id int64, name, color string) error {
+	result, err := d.db.Exec("UPDATE tags SET name = ?, color = ? WHERE id = ?", name, color, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// DeleteTag 删除标签及其与剧集的关联，标签不存在时返回 sql.ErrNoRows
+func (d *Database) DeleteTag(id int64) error {
+	result, err := d.db.Exec("DELETE FROM tags WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// SetSeriesTags 替换剧集的标签，不存在的标签会自动创建
+func (d *Database) SetSeriesTags(seriesID int64, names []string) error {
+	tx, err := d.begin()
+	if err != nil {
+		return err
+	}
+
+	if err := setSeriesTags(tx, seriesID, names); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setSeriesTags 在事务中替换剧集的标签
+func setSeriesTags(tx conn, seriesID int64, names []string) error {
+	if _, err := tx.Exec("DELETE FROM series_tags WHERE series_id = ?", seriesID); err != nil {
+		return err
+	}
+
+	return addSeriesTags(tx, seriesID, names)
+}
+
+// addSeriesTags 在事务中为剧集添加标签，不存在的标签会自动创建
+func addSeriesTags(tx conn, seriesID int64, names []string) error {
+	for _, name := range names {
+		name = NormalizeTagName(name)
+		if name == "" {
+			continue
+		}
+
+		if _, err := tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", name); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO series_tags (series_id, tag_id)
+			SELECT ?, id FROM tags WHERE name = ?
+		`, seriesID, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillTags 一次查询填充多个剧集的标签
+func (d *Database) fillTags(series []Series, index map[int64]*Series) error {
+	placeholders, args := seriesIDArgs(series)
+	rows, err := d.db.Query(`
+		SELECT st.series_id, t.name
+		FROM series_tags st JOIN tags t ON t.id = st.tag_id
+		WHERE st.series_id IN (`+placeholders+`)
+		ORDER BY t.name`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var seriesID int64
+		var name string
+		if err := rows.Scan(&seriesID, &name); err != nil {
+			return err
+		}
+		if s, ok := index[seriesID]; ok {
+			s.Tags = append(s.Tags, name)
+		}
+	}
+	return rows.Err()
+}
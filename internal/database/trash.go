@@ -0,0 +1,46 @@
+package database
+
+import (
+	"time"
+)
+
+// GetTrashedSeries 获取回收站中的剧集，按删除时间倒序
+func (d *Database) GetTrashedSeries() ([]Series, error) {
+	return d.querySeries(`
+		SELECT ` + d.seriesColumns() + `
+		FROM series
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`)
+}
+
+// RestoreSeries 从回收站恢复剧集，剧集不在回收站时返回 sql.ErrNoRows
+func (d *Database) RestoreSeries(id int64) error {
+	result, err := d.db.Exec(`
+		UPDATE series 
+		SET deleted_at = NULL
+		WHERE id = ? AND deleted_at IS NOT NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// PurgeSeries 彻底删除回收站中的剧集，集数随之级联删除
+func (d *Database) PurgeSeries(id int64) error {
+	result, err := d.db.Exec("DELETE FROM series WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// PurgeTrash 彻底删除在指定时间之前移至回收站的剧集，返回删除数量
+func (d *Database) PurgeTrash(before time.Time) (int64, error) {
+	result, err := d.db.Exec("DELETE FROM series WHERE deleted_at IS NOT NULL AND deleted_at < ?", sqliteTime(before))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
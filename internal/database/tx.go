@@ -0,0 +1,70 @@
+package database
+
+import "database/sql"
+
+// conn 执行语句的数据库连接，*sql.DB 与 *sql.Tx 均满足该接口
+type conn interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+// txConn 可提交或回滚的事务
+type txConn interface {
+	conn
+	Commit() error
+	Rollback() error
+}
+
+// savepoint 事务中的嵌套事务，回滚只撤销 SAVEPOINT 之后的修改
+type savepoint struct {
+	*sql.Tx
+}
+
+func (s savepoint) Commit() error {
+	_, err := s.Exec("RELEASE nested")
+	return err
+}
+
+func (s savepoint) Rollback() error {
+	if _, err := s.Exec("ROLLBACK TO nested"); err != nil {
+		return err
+	}
+	_, err := s.Exec("RELEASE nested")
+	return err
+}
+
+// begin 开始事务，已在 Transaction 中时使用 SAVEPOINT
+func (d *Database) begin() (txConn, error) {
+	tx, ok := d.db.(*sql.Tx)
+	if !ok {
+		return d.pool.Begin()
+	}
+	if _, err := tx.Exec("SAVEPOINT nested"); err != nil {
+		return nil, err
+	}
+	return savepoint{tx}, nil
+}
+
+// Transaction 在单个事务中执行 fn，fn 返回错误时回滚所有修改，否则提交
+//
+// fn 中只能通过传入的 tx 读写，使用原存储写入会等待事务结束。
+func (d *Database) Transaction(fn func(tx Store) error) error {
+	tx, err := d.begin()
+	if err != nil {
+		return err
+	}
+
+	// 嵌套调用时语句仍在外层事务上执行
+	db := d.db
+	if sqlTx, ok := tx.(*sql.Tx); ok {
+		db = sqlTx
+	}
+
+	if err := fn(&Database{pool: d.pool, db: db, userID: d.userID}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"net/url"
+	"strings"
+)
+
+// siteURLRule 来源站点的 URL 规范化规则
+type siteURLRule struct {
+	host      string // 规范的主机名
+	keepQuery bool   // 查询参数是否用于定位剧集，为 false 时全部去除
+}
+
+// siteURLRules 已知来源站点的规则，按去掉 www. 的主机名匹配
+var siteURLRules = map[string]siteURLRule{
+	"mini4k.com": {host: "www.mini4k.com"},
+}
+
+// trackingParams 其他站点需要去除的跟踪参数，utm_ 前缀的参数同样去除
+var trackingParams = map[string]bool{
+	"fbclid": true,
+	"gclid":  true,
+	"spm":    true,
+	"from":   true,
+	"share":  true,
+}
+
+// NormalizeSeriesURL 生成剧集 URL 的规范形式，同一剧集的不同写法得到相同的结果
+//
+// 统一使用小写的主机名，去除默认端口、片段、跟踪参数和路径末尾的斜杠；
+// 已知来源站点（如 mini4k）统一为 https 和规范的主机名，并去除全部查询参数。
+// 无法解析为 http(s) URL 的值只去除首尾空白。
+func NormalizeSeriesURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (!strings.EqualFold(u.Scheme, "http") && !strings.EqualFold(u.Scheme, "https")) {
+		return raw
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	u.Host = host
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	rule, known := siteURLRules[strings.TrimPrefix(u.Hostname(), "www.")]
+	if known {
+		u.Scheme = "https"
+		u.Host = rule.host
+	}
+
+	if known && !rule.keepQuery {
+		u.RawQuery = ""
+	} else if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			if trackingParams[strings.ToLower(key)] || strings.HasPrefix(strings.ToLower(key), "utm_") {
+				query.Del(key)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	u.ForceQuery = false
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+	return u.String()
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"time"
+)
+
+// AdminUserID config.json 中配置的管理员对应的用户，未指定用户的存储操作均以该用户身份进行
+const AdminUserID int64 = 1
+
+// User 用户，追踪订阅和观看状态按用户保存
+type User struct {
+	ID              int64     `json:"id"`
+	Username        string    `json:"username"`
+	PasswordHash    string    `json:"-"` // 为空时不能使用密码登录（管理员使用 config.json 中的密码）
+	IsAdmin         bool      `json:"is_admin"`
+	SlackWebhookURL string    `json:"slack_webhook_url"` // 个人通知地址，为空时使用全局配置
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// ForUser 返回以指定用户身份操作的存储，与原存储共用同一个数据库连接（及事务）
+func (d *Database) ForUser(userID int64) Store {
+	return &Database{pool: d.pool, db: d.db, userID: userID}
+}
+
+// user 当前操作的用户
+func (d *Database) user() int64 {
+	if d.userID == 0 {
+		return AdminUserID
+	}
+	return d.userID
+}
+
+const userColumns = `id, username, password_hash, is_admin, slack_webhook_url, created_at`
+
+func scanUser(row rowScanner) (*User, error) {
+	var u User
+	if err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.IsAdmin, &u.SlackWebhookURL, &u.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (d *Database) queryUsers(query string, args ...interface{}) ([]User, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+// GetUsers 获取所有用户
+func (d *Database) GetUsers() ([]User, error) {
+	return d.queryUsers("SELECT " + userColumns + " FROM users ORDER BY id")
+}
+
+// GetUserByID 根据 ID 获取用户
+func (d *Database) GetUserByID(id int64) (*User, error) {
+	return scanUser(d.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id))
+}
+
+// GetUserByName 根据用户名获取用户，不区分大小写
+func (d *Database) GetUserByName(username string) (*User, error) {
+	return scanUser(d.db.QueryRow("SELECT "+userColumns+" FROM users WHERE username = ?", username))
+}
+
+// CreateUser 创建用户
+func (d *Database) CreateUser(user *User) (*User, error) {
+	result, err := d.db.Exec(`
+		INSERT INTO users (username, password_hash, is_admin, slack_webhook_url)
+		VALUES (?, ?, ?, ?)
+	`, user.Username, user.PasswordHash, user.IsAdmin, user.SlackWebhookURL)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return d.GetUserByID(id)
+}
+
+// UpdateUser 更新用户名、密码、管理员权限和通知地址，用户不存在时返回 sql.ErrNoRows
+func (d *Database) UpdateUser(user *User) error {
+	result, err := d.db.Exec(`
+		UPDATE users
+		SET username = ?, password_hash = ?, is_admin = ?, slack_webhook_url = ?
+		WHERE id = ?
+	`, user.Username, user.PasswordHash, user.IsAdmin, user.SlackWebhookURL, user.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// DeleteUser 删除用户，其追踪订阅和观看状态随之级联删除
+func (d *Database) DeleteUser(id int64) error {
+	result, err := d.db.Exec("DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// GetSubscribers 获取正在追踪该剧集的用户（状态属于 TrackingStates）
+func (d *Database) GetSubscribers(seriesID int64) ([]User, error) {
+	return d.queryUsers(`
+		SELECT `+userColumns+` FROM users
+		WHERE id IN (
+			SELECT user_id FROM subscriptions
+			WHERE series_id = ? AND state IN (`+stateListSQL(TrackingStates)+`))
+		ORDER BY id
+	`, seriesID)
+}
@@ -0,0 +1,473 @@
+package library
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"mini-catch/internal/database"
+)
+
+// 观看记录来源
+const (
+	SourceTrakt  = "trakt"
+	SourceTVTime = "tvtime"
+)
+
+// WatchedShow 外部平台导出的单个剧集的观看记录
+type WatchedShow struct {
+	Title    string
+	Year     int
+	Episodes map[string]time.Time // 集数编号 "S01E02" -> 观看时间
+}
+
+// episodeCode 生成与爬虫一致的集数编号
+func episodeCode(season, number int) string {
+	return fmt.Sprintf("S%02dE%02d", season, number)
+}
+
+// watchHistory 按剧集名称汇总观看记录
+type watchHistory struct {
+	shows map[string]*WatchedShow
+	order []string
+}
+
+func newWatchHistory() *watchHistory {
+	return &watchHistory{shows: make(map[string]*WatchedShow)}
+}
+
+// add 记录一次观看，同一集多次观看时保留最早的时间
+func (h *watchHistory) add(title string, year, season, number int, watchedAt time.Time) {
+	title = strings.TrimSpace(title)
+	if title == "" || season <= 0 || number <= 0 {
+		return
+	}
+
+	show, ok := h.shows[title]
+	if !ok {
+		show = &WatchedShow{Title: title, Episodes: make(map[string]time.Time)}
+		h.shows[title] = show
+		h.order = append(h.order, title)
+	}
+	if year > 0 {
+		show.Year = year
+	}
+
+	code := episodeCode(season, number)
+	if existing, ok := show.Episodes[code]; !ok || (!watchedAt.IsZero() && watchedAt.Before(existing)) || existing.IsZero() {
+		show.Episodes[code] = watchedAt
+	}
+}
+
+func (h *watchHistory) result() []WatchedShow {
+	shows := make([]WatchedShow, 0, len(h.order))
+	for _, title := range h.order {
+		shows = append(shows, *h.shows[title])
+	}
+	return shows
+}
+
+// ParseWatchHistory 解析 Trakt 或 TV Time 的导出文件，支持单个文件或导出的 zip 压缩包
+//
+// Trakt 支持 watched-shows.json 和观看历史（history）JSON；TV Time 支持 seen_episode.csv 等包含剧名、季数、集数的 CSV。
+func ParseWatchHistory(source string, data []byte) ([]WatchedShow, error) {
+	if source != SourceTrakt && source != SourceTVTime {
+		return nil, fmt.Errorf("不支持的来源: %s", source)
+	}
+
+	history := newWatchHistory()
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		if err := parseArchive(source, data, history); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if source == SourceTrakt {
+			err = parseTrakt(data, history)
+		} else {
+			err = parseTVTime(data, history)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	shows := history.result()
+	if len(shows) == 0 {
+		return nil, errors.New("导出文件中没有找到剧集观看记录")
+	}
+	return shows, nil
+}
+
+// parseArchive 解析 zip 压缩包中与观看记录相关的文件
+func parseArchive(source string, data []byte, history *watchHistory) error {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("解析压缩包失败: %w", err)
+	}
+
+	for _, file := range reader.File {
+		name := strings.ToLower(path.Base(file.Name))
+		var parse func([]byte, *watchHistory) error
+		switch {
+		// 评分、收藏等文件结构相似但不是观看记录
+		case source == SourceTrakt && strings.HasSuffix(name, ".json") &&
+			(strings.Contains(name, "watched") || strings.Contains(name, "history")):
+			parse = parseTrakt
+		case source == SourceTVTime && strings.HasSuffix(name, ".csv"):
+			parse = parseTVTime
+		default:
+			continue
+		}
+
+		content, err := readZipFile(file)
+		if err != nil {
+			return err
+		}
+		if err := parse(content, history); err != nil {
+			// TV Time 压缩包中有大量无关的 CSV，跳过无法识别的文件
+			log.Printf("跳过导出文件 %s: %v", file.Name, err)
+		}
+	}
+	return nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// traktItem 兼容 watched-shows 和 history 两种格式
+type traktItem struct {
+	WatchedAt *time.Time `json:"watched_at"`
+	Show      *struct {
+		Title string `json:"title"`
+		Year  int    `json:"year"`
+	} `json:"show"`
+	Episode *struct {
+		Season int `json:"season"`
+		Number int `json:"number"`
+	} `json:"episode"`
+	Seasons []struct {
+		Number   int `json:"number"`
+		Episodes []struct {
+			Number        int        `json:"number"`
+			LastWatchedAt *time.Time `json:"last_watched_at"`
+		} `json:"episodes"`
+	} `json:"seasons"`
+}
+
+// parseTrakt 解析 Trakt 导出的 JSON
+func parseTrakt(data []byte, history *watchHistory) error {
+	var items []traktItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return fmt.Errorf("解析 Trakt JSON 失败: %w", err)
+	}
+
+	for _, item := range items {
+		if item.Show == nil {
+			continue
+		}
+		// history 格式：每条记录是一次观看
+		if item.Episode != nil && item.WatchedAt != nil {
+			history.add(item.Show.Title, item.Show.Year, item.Episode.Season, item.Episode.Number, *item.WatchedAt)
+			continue
+		}
+		// watched-shows 格式：按季列出看过的集数
+		for _, season := range item.Seasons {
+			for _, ep := range season.Episodes {
+				var watchedAt time.Time
+				if ep.LastWatchedAt != nil {
+					watchedAt = *ep.LastWatchedAt
+				}
+				history.add(item.Show.Title, item.Show.Year, season.Number, ep.Number, watchedAt)
+			}
+		}
+	}
+	return nil
+}
+
+// TV Time 不同版本的导出文件列名不同
+var (
+	tvTimeShowColumns    = []string{"tv_show_name", "series_name", "show_name"}
+	tvTimeSeasonColumns  = []string{"episode_season_number", "season_number", "season"}
+	tvTimeEpisodeColumns = []string{"episode_number", "number", "episode"}
+	tvTimeTimeColumns    = []string{"created_at", "watched_at", "updated_at"}
+)
+
+// tvTimeTimeLayouts TV Time 导出的时间格式
+var tvTimeTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+// parseTVTime 解析 TV Time 导出的 CSV
+func parseTVTime(data []byte, history *watchHistory) error {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("解析 TV Time CSV 失败: %w", err)
+	}
+
+	find := func(names []string) int {
+		for _, name := range names {
+			for i, column := range header {
+				if strings.EqualFold(strings.TrimSpace(column), name) {
+					return i
+				}
+			}
+		}
+		return -1
+	}
+	showCol, seasonCol, episodeCol, timeCol := find(tvTimeShowColumns), find(tvTimeSeasonColumns), find(tvTimeEpisodeColumns), find(tvTimeTimeColumns)
+	if showCol < 0 || seasonCol < 0 || episodeCol < 0 {
+		return errors.New("CSV 中没有剧名、季数和集数列")
+	}
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("解析 TV Time CSV 失败: %w", err)
+		}
+
+		get := func(i int) string {
+			if i >= 0 && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		// 关注、评分等记录没有季数和集数，add 会忽略
+		season, _ := strconv.Atoi(get(seasonCol))
+		number, _ := strconv.Atoi(get(episodeCol))
+
+		var watchedAt time.Time
+		for _, layout := range tvTimeTimeLayouts {
+			if t, err := time.Parse(layout, get(timeCol)); err == nil {
+				watchedAt = t
+				break
+			}
+		}
+		history.add(get(showCol), 0, season, number, watchedAt)
+	}
+	return nil
+}
+
+// 剧集匹配结果
+const (
+	MatchMatched   = "matched"
+	MatchUnmatched = "unmatched"
+	MatchAmbiguous = "ambiguous"
+)
+
+// SeriesRef 候选剧集
+type SeriesRef struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// ShowReport 单个外部剧集的导入结果
+type ShowReport struct {
+	Title        string      `json:"title"`
+	Year         int         `json:"year,omitempty"`
+	Action       string      `json:"action"`
+	MatchedBy    string      `json:"matched_by,omitempty"` // mapping、name 或 partial
+	Series       *SeriesRef  `json:"series,omitempty"`
+	Candidates   []SeriesRef `json:"candidates,omitempty"` // 匹配到多个剧集时的候选
+	Reason       string      `json:"reason,omitempty"`
+	Watched      int         `json:"watched"`           // 导出文件中看过的集数
+	NewlyWatched int         `json:"newly_watched"`     // 本次新标记为已看的集数
+	Missing      []string    `json:"missing,omitempty"` // 看过但爬虫尚未发现的集数，不会被导入
+}
+
+// HistoryReport 观看记录导入报告
+type HistoryReport struct {
+	DryRun       bool         `json:"dry_run"`
+	Source       string       `json:"source"`
+	Matched      int          `json:"matched"`
+	Unmatched    int          `json:"unmatched"`
+	Ambiguous    int          `json:"ambiguous"`
+	NewlyWatched int          `json:"newly_watched"`
+	Shows        []ShowReport `json:"shows"`
+}
+
+// normalizeTitle 去除大小写、空白和标点后用于比较剧名
+func normalizeTitle(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// minPartialTitleLength 剧名过短时不做包含匹配，避免误匹配
+const minPartialTitleLength = 4
+
+// matchShow 按映射、完整剧名、剧名包含的顺序匹配剧集
+func matchShow(show WatchedShow, series []database.Series, mapping map[string]int64, report *ShowReport) {
+	if id, ok := lookupMapping(mapping, show.Title); ok {
+		for _, s := range series {
+			if s.ID == id {
+				report.Action, report.MatchedBy = MatchMatched, "mapping"
+				report.Series = &SeriesRef{ID: s.ID, Name: s.Name}
+				return
+			}
+		}
+		report.Action, report.Reason = MatchUnmatched, fmt.Sprintf("映射的剧集 %d 不存在", id)
+		return
+	}
+
+	title := normalizeTitle(show.Title)
+	var exact, partial []SeriesRef
+	for _, s := range series {
+		name := normalizeTitle(s.Name)
+		switch {
+		case name == title:
+			exact = append(exact, SeriesRef{ID: s.ID, Name: s.Name})
+		case len([]rune(title)) >= minPartialTitleLength && strings.Contains(name, title):
+			// 剧集名称常为 "中文名 英文名" 形式
+			partial = append(partial, SeriesRef{ID: s.ID, Name: s.Name})
+		}
+	}
+
+	candidates, matchedBy := exact, "name"
+	if len(candidates) == 0 {
+		candidates, matchedBy = partial, "partial"
+	}
+	switch len(candidates) {
+	case 0:
+		report.Action, report.Reason = MatchUnmatched, "没有名称匹配的剧集"
+	case 1:
+		report.Action, report.MatchedBy = MatchMatched, matchedBy
+		report.Series = &candidates[0]
+	default:
+		report.Action, report.Reason = MatchAmbiguous, "匹配到多个剧集，请通过映射指定"
+		report.Candidates = candidates
+	}
+}
+
+// lookupMapping 按剧名查找映射，忽略大小写和标点
+func lookupMapping(mapping map[string]int64, title string) (int64, bool) {
+	if id, ok := mapping[title]; ok {
+		return id, true
+	}
+	normalized := normalizeTitle(title)
+	for key, id := range mapping {
+		if normalizeTitle(key) == normalized {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// ImportWatchHistory 将外部观看记录匹配到已有剧集并标记已看的集数，dryRun 时只生成报告
+//
+// mapping 为外部剧名到剧集 ID 的映射，优先于按名称匹配。只会标记爬虫已发现的集数，不会新增集数或取消已看状态。
+func ImportWatchHistory(store database.Store, source string, shows []WatchedShow, mapping map[string]int64, dryRun bool) (*HistoryReport, error) {
+	series, err := store.GetAllSeries()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &HistoryReport{DryRun: dryRun, Source: source, Shows: []ShowReport{}}
+	for _, show := range shows {
+		item := ShowReport{Title: show.Title, Year: show.Year, Watched: len(show.Episodes)}
+		matchShow(show, series, mapping, &item)
+
+		switch item.Action {
+		case MatchUnmatched:
+			report.Unmatched++
+		case MatchAmbiguous:
+			report.Ambiguous++
+		case MatchMatched:
+			report.Matched++
+			if err := applyWatchHistory(store, source, show, &item, dryRun); err != nil {
+				return nil, err
+			}
+			report.NewlyWatched += item.NewlyWatched
+		}
+		report.Shows = append(report.Shows, item)
+	}
+
+	return report, nil
+}
+
+// applyWatchHistory 标记匹配剧集中看过的集数
+func applyWatchHistory(store database.Store, source string, show WatchedShow, item *ShowReport, dryRun bool) error {
+	existing, err := store.GetSeriesByID(item.Series.ID)
+	if err != nil {
+		return err
+	}
+	episodes, err := store.GetEpisodes(existing.ID)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(episodes))
+	for _, e := range episodes {
+		known[e.Code] = e.WatchedAt != nil
+	}
+
+	var newlyWatched []database.EpisodeRecord
+	for code, watchedAt := range show.Episodes {
+		watched, ok := known[code]
+		if !ok {
+			item.Missing = append(item.Missing, code)
+			continue
+		}
+		if watched {
+			continue
+		}
+		if watchedAt.IsZero() {
+			watchedAt = time.Now()
+		}
+		at := watchedAt
+		newlyWatched = append(newlyWatched, database.EpisodeRecord{Code: code, WatchedAt: &at})
+	}
+	sort.Strings(item.Missing)
+	sort.Slice(newlyWatched, func(i, j int) bool { return newlyWatched[i].Code < newlyWatched[j].Code })
+	item.NewlyWatched = len(newlyWatched)
+
+	if dryRun || len(newlyWatched) == 0 {
+		return nil
+	}
+
+	// 复用合并导入：名称和剧集状态保持不变，已有集数只补充观看时间
+	_, err = store.ImportSeries(database.SeriesRecord{
+		Name:     existing.Name,
+		URL:      existing.URL,
+		Episodes: newlyWatched,
+	})
+	if err != nil {
+		return fmt.Errorf("导入 %s 的观看记录失败: %w", existing.Name, err)
+	}
+
+	codes := make([]string, len(newlyWatched))
+	for i, e := range newlyWatched {
+		codes[i] = e.Code
+	}
+	err = store.CreateSeriesEvent(existing.ID, database.EventWatched, database.EventSourceUser, map[string]interface{}{
+		"import":   source,
+		"episodes": codes,
+	})
+	if err != nil {
+		log.Printf("记录剧集事件失败 [%d %s]: %v", existing.ID, database.EventWatched, err)
+	}
+	return nil
+}
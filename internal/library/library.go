@@ -0,0 +1,548 @@
+package library
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"mini-catch/internal/database"
+)
+
+// FormatVersion 导出文件格式版本，版本 2 以剧集状态代替追踪开关
+const FormatVersion = 2
+
+// Document JSON 导出文件
+type Document struct {
+	Version    int                     `json:"version"`
+	ExportedAt time.Time               `json:"exported_at"`
+	Series     []database.SeriesRecord `json:"series"`
+}
+
+// Export 导出所有剧集（不含回收站）的完整记录
+func Export(store database.Store) ([]database.SeriesRecord, error) {
+	series, err := store.GetAllSeries()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]database.SeriesRecord, 0, len(series))
+	for _, s := range series {
+		episodes, err := store.GetEpisodes(s.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		state := s.State
+		record := database.SeriesRecord{
+			Name:            s.Name,
+			URL:             s.URL,
+			Current:         s.Current,
+			State:           &state,
+			Tags:            s.Tags,
+			CreatedAt:       s.CreatedAt,
+			UpdatedAt:       s.UpdatedAt,
+			CrawlerLastSeen: s.CrawlerLastSeen,
+			Episodes:        make([]database.EpisodeRecord, 0, len(episodes)),
+			SeriesMetadata:  s.SeriesMetadata,
+			Notes:           s.Notes,
+			Rating:          s.Rating,
+		}
+		for _, e := range episodes {
+			record.Episodes = append(record.Episodes, database.EpisodeRecord{
+				Code:        e.Code,
+				Title:       e.Title,
+				FirstSeenAt: e.FirstSeenAt,
+				WatchedAt:   e.WatchedAt,
+			})
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// WriteJSON 以 JSON 格式写出导出文件
+func WriteJSON(w io.Writer, records []database.SeriesRecord) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(Document{
+		Version:    FormatVersion,
+		ExportedAt: time.Now().UTC(),
+		Series:     records,
+	})
+}
+
+// ReadJSON 读取 JSON 导出文件
+func ReadJSON(r io.Reader) ([]database.SeriesRecord, error) {
+	var doc Document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("解析 JSON 失败: %w", err)
+	}
+	if doc.Version > FormatVersion {
+		return nil, fmt.Errorf("不支持的导出文件版本: %d", doc.Version)
+	}
+	for i := range doc.Series {
+		if err := normalizeState(&doc.Series[i]); err != nil {
+			return nil, fmt.Errorf("剧集 %s: %w", doc.Series[i].URL, err)
+		}
+	}
+	return doc.Series, nil
+}
+
+// normalizeState 校验剧集状态，旧版本文件的追踪开关转换为对应状态；两者都没有时剧集状态保持不变
+func normalizeState(record *database.SeriesRecord) error {
+	if record.State == nil && record.LegacyTracking != nil {
+		state := database.LegacyTrackingState(*record.LegacyTracking)
+		record.State = &state
+	}
+	record.LegacyTracking = nil
+	if record.State != nil && !database.IsValidSeriesState(*record.State) {
+		return fmt.Errorf("无效的状态: %s", *record.State)
+	}
+	return nil
+}
+
+// csvHeader CSV 列，每行一个剧集；多个值以 | 分隔，watched 为已看的集数编号
+var csvHeader = []string{
+	"name", "url", "current", "state", "tags", "history", "watched",
+	"created_at", "updated_at", "crawler_last_seen",
+	"poster_url", "original_title", "year", "douban_id", "imdb_id", "tmdb_id", "notes", "rating",
+}
+
+// csvListSeparator CSV 中列表字段的分隔符
+const csvListSeparator = "|"
+
+// WriteCSV 以 CSV 格式写出导出文件，CSV 不包含集数标题和各集的时间
+func WriteCSV(w io.Writer, records []database.SeriesRecord) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		var history, watched []string
+		for _, e := range record.Episodes {
+			history = append(history, e.Code)
+			if e.WatchedAt != nil {
+				watched = append(watched, e.Code)
+			}
+		}
+		state := ""
+		if record.State != nil {
+			state = *record.State
+		}
+		crawlerLastSeen := ""
+		if record.CrawlerLastSeen != nil {
+			crawlerLastSeen = record.CrawlerLastSeen.UTC().Format(time.RFC3339)
+		}
+
+		err := writer.Write([]string{
+			record.Name,
+			record.URL,
+			record.Current,
+			state,
+			strings.Join(record.Tags, csvListSeparator),
+			strings.Join(history, csvListSeparator),
+			strings.Join(watched, csvListSeparator),
+			record.CreatedAt.UTC().Format(time.RFC3339),
+			record.UpdatedAt.UTC().Format(time.RFC3339),
+			crawlerLastSeen,
+			record.PosterURL,
+			record.OriginalTitle,
+			formatCSVInt(record.Year),
+			record.DoubanID,
+			record.IMDbID,
+			record.TMDBID,
+			record.Notes,
+			formatCSVInt(record.Rating),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReadCSV 读取 CSV 导出文件，按表头定位列，缺少的列使用零值
+func ReadCSV(r io.Reader) ([]database.SeriesRecord, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析 CSV 失败: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, errors.New("CSV 缺少 url 列")
+	}
+	_, hasState := columns["state"]
+
+	var records []database.SeriesRecord
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析 CSV 失败: %w", err)
+		}
+
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		record, err := parseCSVRecord(get, hasState)
+		if err != nil {
+			return nil, fmt.Errorf("CSV 第 %d 行: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// parseCSVRecord 解析一行 CSV，state 列为空表示不在片单中；没有 state 列时按旧版本的 is_tracking 列转换，
+// 两者都没有时剧集状态保持不变（新建的剧集为在看，见 Import）
+func parseCSVRecord(get func(name string) string, hasState bool) (database.SeriesRecord, error) {
+	record := database.SeriesRecord{
+		Name:    get("name"),
+		URL:     get("url"),
+		Current: get("current"),
+		Tags:    splitList(get("tags")),
+		SeriesMetadata: database.SeriesMetadata{
+			PosterURL:     get("poster_url"),
+			OriginalTitle: get("original_title"),
+			DoubanID:      get("douban_id"),
+			IMDbID:        get("imdb_id"),
+			TMDBID:        get("tmdb_id"),
+		},
+		Notes: get("notes"),
+	}
+
+	if hasState {
+		state := get("state")
+		record.State = &state
+	} else if v := get("is_tracking"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return record, fmt.Errorf("无效的 is_tracking: %s", v)
+		}
+		state := database.LegacyTrackingState(b)
+		record.State = &state
+	}
+	if err := normalizeState(&record); err != nil {
+		return record, err
+	}
+
+	var err error
+	if record.Year, err = parseCSVInt("year", get("year")); err != nil {
+		return record, err
+	}
+	if record.Rating, err = parseCSVInt("rating", get("rating")); err != nil {
+		return record, err
+	}
+	if record.CreatedAt, err = parseCSVTime(get("created_at")); err != nil {
+		return record, err
+	}
+	if record.UpdatedAt, err = parseCSVTime(get("updated_at")); err != nil {
+		return record, err
+	}
+	if v := get("crawler_last_seen"); v != "" {
+		t, err := parseCSVTime(v)
+		if err != nil {
+			return record, err
+		}
+		record.CrawlerLastSeen = &t
+	}
+
+	// CSV 不记录观看时间，以更新时间作为已看时间
+	watchedAt := record.UpdatedAt
+	if watchedAt.IsZero() {
+		watchedAt = time.Now().UTC()
+	}
+	watched := make(map[string]bool)
+	for _, code := range splitList(get("watched")) {
+		watched[code] = true
+	}
+	for _, code := range splitList(get("history")) {
+		episode := database.EpisodeRecord{Code: code}
+		if watched[code] {
+			episode.WatchedAt = &watchedAt
+		}
+		record.Episodes = append(record.Episodes, episode)
+	}
+
+	return record, nil
+}
+
+func parseCSVTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无效的时间: %s", value)
+	}
+	return t, nil
+}
+
+// formatCSVInt 整数字段，0 表示未知，写为空
+func formatCSVInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// parseCSVInt 解析整数字段，空值为 0
+func parseCSVInt(name, value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("无效的 %s: %s", name, value)
+	}
+	return n, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, csvListSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// 导入结果
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionUnchanged = "unchanged"
+	ActionConflict  = "conflict"
+)
+
+// ReportItem 单个剧集的导入结果
+type ReportItem struct {
+	Name     string   `json:"name"`
+	URL      string   `json:"url"`
+	Action   string   `json:"action"`
+	SeriesID int64    `json:"series_id,omitempty"`
+	Changes  []string `json:"changes,omitempty"` // 更新的内容
+	Reason   string   `json:"reason,omitempty"`  // 冲突原因
+}
+
+// Report 导入报告
+type Report struct {
+	DryRun    bool         `json:"dry_run"`
+	Created   int          `json:"created"`
+	Updated   int          `json:"updated"`
+	Unchanged int          `json:"unchanged"`
+	Conflicts int          `json:"conflicts"`
+	Items     []ReportItem `json:"items"`
+}
+
+// Import 按 URL 合并导入剧集，dryRun 时只生成报告不写入
+//
+// 冲突的记录会被跳过：缺少名称或 URL、元数据或评分无效、文件内 URL 重复、URL 已被回收站中的剧集占用。
+// 所有记录在同一事务中导入，任一记录写入失败时不做任何修改。
+// 记录没有剧集状态时已有剧集的状态保持不变，新建的剧集为在看。
+func Import(store database.Store, records []database.SeriesRecord, dryRun bool) (*Report, error) {
+	if dryRun {
+		return importRecords(store, records, true)
+	}
+
+	var report *Report
+	err := store.Transaction(func(tx database.Store) error {
+		var err error
+		report, err = importRecords(tx, records, false)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// importRecords 逐条对比并导入记录，见 Import
+func importRecords(store database.Store, records []database.SeriesRecord, dryRun bool) (*Report, error) {
+	trashed, err := store.GetTrashedSeries()
+	if err != nil {
+		return nil, err
+	}
+	trashedURLs := make(map[string]bool, len(trashed))
+	for _, s := range trashed {
+		trashedURLs[database.NormalizeSeriesURL(s.URL)] = true
+	}
+
+	report := &Report{DryRun: dryRun, Items: []ReportItem{}}
+	seen := make(map[string]bool, len(records))
+	for _, record := range records {
+		record.Name = strings.TrimSpace(record.Name)
+		record.URL = database.NormalizeSeriesURL(record.URL)
+		item := ReportItem{Name: record.Name, URL: record.URL}
+		invalid := validateMetadata(&record)
+
+		switch {
+		case record.Name == "" || record.URL == "":
+			item.Action, item.Reason = ActionConflict, "缺少名称或 URL"
+		case invalid != nil:
+			item.Action, item.Reason = ActionConflict, invalid.Error()
+		case seen[record.URL]:
+			item.Action, item.Reason = ActionConflict, "文件中 URL 重复"
+		case trashedURLs[record.URL]:
+			item.Action, item.Reason = ActionConflict, "URL 已被回收站中的剧集占用"
+		default:
+			if err := plan(store, record, &item); err != nil {
+				return nil, err
+			}
+		}
+		seen[record.URL] = true
+
+		if item.Action == ActionCreate && record.State == nil {
+			state := database.StateWatching
+			record.State = &state
+		}
+		if !dryRun && (item.Action == ActionCreate || item.Action == ActionUpdate) {
+			id, err := store.ImportSeries(record)
+			if err != nil {
+				return nil, fmt.Errorf("导入 %s 失败: %w", record.URL, err)
+			}
+			item.SeriesID = id
+		}
+
+		switch item.Action {
+		case ActionCreate:
+			report.Created++
+		case ActionUpdate:
+			report.Updated++
+		case ActionUnchanged:
+			report.Unchanged++
+		case ActionConflict:
+			report.Conflicts++
+		}
+		report.Items = append(report.Items, item)
+	}
+
+	return report, nil
+}
+
+// validateMetadata 去除元数据的首尾空白，并按用户编辑时的规则校验元数据和评分
+func validateMetadata(record *database.SeriesRecord) error {
+	meta := &record.SeriesMetadata
+	patch := database.SeriesMetadataPatch{
+		PosterURL:     &meta.PosterURL,
+		OriginalTitle: &meta.OriginalTitle,
+		Year:          &meta.Year,
+		DoubanID:      &meta.DoubanID,
+		IMDbID:        &meta.IMDbID,
+		TMDBID:        &meta.TMDBID,
+	}
+	if err := patch.Normalize(); err != nil {
+		return err
+	}
+	record.Notes = strings.TrimSpace(record.Notes)
+	return database.ValidateRating(record.Rating)
+}
+
+// plan 对比已有剧集，确定导入动作和变更内容
+func plan(store database.Store, record database.SeriesRecord, item *ReportItem) error {
+	existing, err := store.GetSeriesByURL(record.URL)
+	if errors.Is(err, sql.ErrNoRows) {
+		item.Action = ActionCreate
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	item.SeriesID = existing.ID
+
+	episodes, err := store.GetEpisodes(existing.ID)
+	if err != nil {
+		return err
+	}
+	watched := make(map[string]bool, len(episodes))
+	for _, e := range episodes {
+		watched[e.Code] = e.WatchedAt != nil
+	}
+	tags := make(map[string]bool, len(existing.Tags))
+	for _, t := range existing.Tags {
+		tags[strings.ToLower(t)] = true
+	}
+
+	var changes []string
+	if record.Name != existing.Name {
+		changes = append(changes, "name")
+	}
+	if record.State != nil && *record.State != existing.State {
+		changes = append(changes, "state")
+	}
+	if record.Current != "" && record.Current != existing.Current {
+		changes = append(changes, "current")
+	}
+	// 与 ImportSeries 一致，只补充为空的字段
+	meta, current := record.SeriesMetadata, existing.SeriesMetadata
+	if (meta.PosterURL != "" && current.PosterURL == "") ||
+		(meta.OriginalTitle != "" && current.OriginalTitle == "") ||
+		(meta.Year != 0 && current.Year == 0) ||
+		(meta.DoubanID != "" && current.DoubanID == "") ||
+		(meta.IMDbID != "" && current.IMDbID == "") ||
+		(meta.TMDBID != "" && current.TMDBID == "") {
+		changes = append(changes, "metadata")
+	}
+	if record.Notes != "" && existing.Notes == "" {
+		changes = append(changes, "notes")
+	}
+	if record.Rating != 0 && existing.Rating == 0 {
+		changes = append(changes, "rating")
+	}
+
+	var newEpisodes, newWatched, newTags int
+	for _, e := range record.Episodes {
+		isWatched, exists := watched[e.Code]
+		if !exists {
+			newEpisodes++
+		}
+		if e.WatchedAt != nil && !isWatched {
+			newWatched++
+		}
+	}
+	for _, t := range record.Tags {
+		if t = database.NormalizeTagName(t); t != "" && !tags[strings.ToLower(t)] {
+			newTags++
+		}
+	}
+	if newEpisodes > 0 {
+		changes = append(changes, fmt.Sprintf("episodes +%d", newEpisodes))
+	}
+	if newWatched > 0 {
+		changes = append(changes, fmt.Sprintf("watched +%d", newWatched))
+	}
+	if newTags > 0 {
+		changes = append(changes, fmt.Sprintf("tags +%d", newTags))
+	}
+
+	item.Changes = changes
+	item.Action = ActionUnchanged
+	if len(changes) > 0 {
+		item.Action = ActionUpdate
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package library
+
+import (
+	"strings"
+	"testing"
+
+	"mini-catch/internal/database"
+)
+
+func TestImportCSVWithoutStateKeepsState(t *testing.T) {
+	store := database.NewMemoryStore()
+	existing, err := store.CreateSeries("漫长的季节", "https://example.com/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetSeriesState(existing.ID, database.StatePaused); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadCSV(strings.NewReader("name,url,history\n" +
+		"漫长的季节,https://example.com/1,S01E01\n" +
+		"繁花,https://example.com/2,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Import(store, records, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 1 || report.Updated != 1 {
+		t.Fatalf("导入结果不正确: %+v", report)
+	}
+
+	got, err := store.GetSeriesByID(existing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.State != database.StatePaused {
+		t.Fatalf("没有 state 列时已有剧集的状态应保持不变，实际为 %q", got.State)
+	}
+	created, err := store.GetSeriesByURL("https://example.com/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.State != database.StateWatching {
+		t.Fatalf("新建的剧集应为在看，实际为 %q", created.State)
+	}
+}
@@ -0,0 +1,63 @@
+// Package password 用户密码的哈希与校验
+package password
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinLength 密码最小长度
+const MinLength = 6
+
+// ErrTooShort 密码长度不足
+var ErrTooShort = errors.New("密码长度至少为 6 位")
+
+// Hash 使用 bcrypt 生成密码哈希
+func Hash(password string) (string, error) {
+	if len(password) < MinLength {
+		return "", ErrTooShort
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify 校验密码是否与哈希匹配，哈希为空时始终不匹配
+func Verify(hash, password string) bool {
+	if hash == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// dummyHash 用户不存在时用于校验的哈希，使响应时间与用户存在时一致
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("mini-catch"), bcrypt.DefaultCost)
+
+// VerifyDummy 执行一次与 Verify 耗时相同的校验，结果总是不匹配
+func VerifyDummy(password string) {
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// IsHash 是否为 bcrypt 哈希（$2a$、$2b$、$2y$ 开头）
+func IsHash(value string) bool {
+	return strings.HasPrefix(value, "$2a$") || strings.HasPrefix(value, "$2b$") || strings.HasPrefix(value, "$2y$")
+}
+
+// Matches 校验配置中的密码，stored 为 bcrypt 哈希时校验哈希，否则视为明文并以常量时间比较
+func Matches(stored, password string) bool {
+	if stored == "" {
+		return false
+	}
+	if IsHash(stored) {
+		return Verify(stored, password)
+	}
+	// 比较摘要，避免泄露明文密码的长度
+	a, b := sha256.Sum256([]byte(stored)), sha256.Sum256([]byte(password))
+	return subtle.ConstantTimeCompare(a[:], b[:]) == 1
+}
@@ -35,9 +35,15 @@ type Field struct {
 	Short bool   `json:"short"`
 }
 
+// Store 读取全局配置和剧集的追踪用户，database.Store 均满足该接口
+type Store interface {
+	GetSettings() (*database.Settings, error)
+	GetSubscribers(seriesID int64) ([]database.User, error)
+}
+
 // Notifier Slack 通知器
 type Notifier struct {
-	Db *database.Database
+	Db Store
 }
 
 // getWebhookURL 从数据库获取 webhook URL
@@ -52,12 +58,66 @@ func (n *Notifier) getWebhookURL() string {
 		return ""
 	}
 
-	return settings.SlackWebhookURL
+	return settings.Get(database.SettingSlackWebhookURL)
 }
 
-// send 发送消息到配置的 webhook
+// send 发送消息到全局配置的 webhook
 func (n *Notifier) send(message SlackMessage) error {
-	webhookURL := n.getWebhookURL()
+	return n.sendTo(n.getWebhookURL(), message)
+}
+
+// subscriberWebhookURLs 追踪该剧集的用户的通知地址，去重后返回
+// 未设置个人地址的用户使用全局地址，没有用户追踪时不发送
+func (n *Notifier) subscriberWebhookURLs(seriesID int64) ([]string, error) {
+	if n.Db == nil {
+		return nil, nil
+	}
+
+	users, err := n.Db.GetSubscribers(seriesID)
+	if err != nil {
+		return nil, fmt.Errorf("获取追踪用户失败: %v", err)
+	}
+
+	var urls []string
+	seen := make(map[string]bool)
+	for _, user := range users {
+		webhookURL := user.SlackWebhookURL
+		if webhookURL == "" {
+			webhookURL = n.getWebhookURL()
+		}
+		if webhookURL == "" || seen[webhookURL] {
+			continue
+		}
+		seen[webhookURL] = true
+		urls = append(urls, webhookURL)
+	}
+	return urls, nil
+}
+
+// notifySubscribers 发送消息给追踪该剧集的所有用户
+func (n *Notifier) notifySubscribers(seriesID int64, message SlackMessage) error {
+	urls, err := n.subscriberWebhookURLs(seriesID)
+	if err != nil {
+		return err
+	}
+	if len(urls) == 0 {
+		return fmt.Errorf("没有可用的 Slack Webhook URL")
+	}
+
+	var errs []string
+	for _, webhookURL := range urls {
+		if err := n.sendTo(webhookURL, message); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// sendTo 发送消息到指定的 webhook
+func (n *Notifier) sendTo(webhookURL string, message SlackMessage) error {
 	if webhookURL == "" {
 		return fmt.Errorf("slack Webhook URL 未配置")
 	}
@@ -97,28 +157,39 @@ func (n *Notifier) SendTestNotification() error {
 	return n.send(message)
 }
 
-// SendNotification 发送剧集更新通知
-func (n *Notifier) SendNotification(seriesName string, newEpisodes []string, url string) {
+// SendNotification 发送剧集更新通知给追踪该剧集的用户
+func (n *Notifier) SendNotification(seriesID int64, seriesName string, newEpisodes []string, url string) {
 	message := n.buildEpisodeUpdateMessage(seriesName, newEpisodes, url)
 
-	if err := n.send(message); err != nil {
+	if err := n.notifySubscribers(seriesID, message); err != nil {
 		log.Printf("发送 Slack 通知失败: %v", err)
 	} else {
 		log.Printf("已发送 Slack 通知: %s 新增 %d 集", seriesName, len(newEpisodes))
 	}
 }
 
-// SendStatusUpdateNotification 发送剧集状态变更通知
-func (n *Notifier) SendStatusUpdateNotification(seriesName, oldStatus, newStatus, url string) {
+// SendStatusUpdateNotification 发送剧集状态变更通知给追踪该剧集的用户
+func (n *Notifier) SendStatusUpdateNotification(seriesID int64, seriesName, oldStatus, newStatus, url string) {
 	message := n.buildStatusChangeMessage(seriesName, oldStatus, newStatus, url)
 
-	if err := n.send(message); err != nil {
+	if err := n.notifySubscribers(seriesID, message); err != nil {
 		log.Printf("发送 Slack 更新状态通知失败: %v", err)
 	} else {
 		log.Printf("已发送 Slack 更新状态通知: %s %s → %s", seriesName, oldStatus, newStatus)
 	}
 }
 
+// SendFinishedNotification 发送剧集完结通知给追踪该剧集的用户
+func (n *Notifier) SendFinishedNotification(seriesID int64, seriesName, status, url string) {
+	message := n.buildFinishedMessage(seriesName, status, url)
+
+	if err := n.notifySubscribers(seriesID, message); err != nil {
+		log.Printf("发送 Slack 完结通知失败: %v", err)
+	} else {
+		log.Printf("已发送 Slack 完结通知: %s", seriesName)
+	}
+}
+
 // buildStatusChangeMessage 构建状态变更消息
 func (n *Notifier) buildStatusChangeMessage(seriesName, oldStatus, newStatus, url string) SlackMessage {
 	attachment := SlackAttachment{
@@ -157,3 +228,21 @@ func (n *Notifier) buildEpisodeUpdateMessage(seriesName string, newEpisodes []st
 
 	return SlackMessage{Attachments: []SlackAttachment{attachment}}
 }
+
+// buildFinishedMessage 构建剧集完结消息
+func (n *Notifier) buildFinishedMessage(seriesName, status, url string) SlackMessage {
+	attachment := SlackAttachment{
+		Color:     "#9B59B6", // 紫色
+		Title:     fmt.Sprintf("🏁 %s 已完结", seriesName),
+		TitleLink: url,
+		Text:      fmt.Sprintf("%s已完结，之后将降低抓取频率", seriesName),
+		Fields: []Field{
+			{Title: "剧集名称", Value: seriesName, Short: true},
+			{Title: "状态", Value: status, Short: true},
+		},
+		Footer: "MiniCatch 自动追踪",
+		Ts:     time.Now().Unix(),
+	}
+
+	return SlackMessage{Attachments: []SlackAttachment{attachment}}
+}